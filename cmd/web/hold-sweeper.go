@@ -0,0 +1,35 @@
+package main
+
+import (
+	"BookingProject/pkg/handlers"
+	"time"
+)
+
+// how often holds on rooms that guests never booked, and reservations they never paid for, are cleared away.
+// The waitlist is looked at again each time too, as links sent to it run out
+const holdSweepInterval = time.Minute
+
+func sweepExpiredHolds(repo *handlers.Repository) {
+	db := repo.DB
+	go func() {
+		for {
+			n, err := db.DeleteExpiredHolds()
+			if err != nil {
+				app.ErrorLog.Println(err)
+			} else if n > 0 {
+				app.InfoLog.Printf("released %d expired room holds", n)
+			}
+
+			n, err = db.CancelAbandonedReservations()
+			if err != nil {
+				app.ErrorLog.Println(err)
+			} else if n > 0 {
+				app.InfoLog.Printf("cancelled %d reservations that were never paid for", n)
+			}
+
+			repo.NotifyWaitlist()
+
+			time.Sleep(holdSweepInterval)
+		}
+	}()
+}
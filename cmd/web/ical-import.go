@@ -0,0 +1,18 @@
+package main
+
+import (
+	"BookingProject/pkg/icalsync"
+	"time"
+)
+
+// how often the feeds of other booking sites are imported
+const icalImportInterval = 15 * time.Minute
+
+func importICalFeeds(s *icalsync.Syncer) {
+	go func() {
+		for {
+			s.SyncAll()
+			time.Sleep(icalImportInterval)
+		}
+	}()
+}
@@ -5,7 +5,9 @@ import (
 	"BookingProject/pkg/driver"
 	"BookingProject/pkg/handlers"
 	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/icalsync"
 	"BookingProject/pkg/models"
+	"BookingProject/pkg/payments"
 	"BookingProject/pkg/render"
 	"encoding/gob"
 	"fmt"
@@ -38,6 +40,14 @@ func main() {
 	fmt.Println("Starting Mail Listener")
 	listenForMail()
 
+	fmt.Println("Starting iCal Importer")
+	syncer := icalsync.NewSyncer(&app, handlers.Repo.DB)
+	syncer.Freed = handlers.Repo.NotifyWaitlist
+	importICalFeeds(syncer)
+
+	fmt.Println("Starting Hold Sweeper")
+	sweepExpiredHolds(handlers.Repo)
+
 	srv := &http.Server{
 		Addr:    portNum,
 		Handler: routes(&app),
@@ -63,6 +73,33 @@ func run() (*driver.DB, error) {
 
 	app.InProd = false
 
+	app.SearchWindowDays = 7
+
+	app.BaseURL = os.Getenv("BASE_URL")
+	if app.BaseURL == "" {
+		app.BaseURL = "http://localhost" + portNum
+	}
+
+	//the fake gateway takes anyone's word that they paid, so it never runs in production.
+	//swap in a real provider here when there is one. until then production confirms
+	//bookings without taking payment and the guest pays at the front desk
+	if !app.InProd {
+		app.Payments = payments.NewFakeGateway()
+	} else {
+		log.Println("No payment provider is set up, bookings are confirmed without payment")
+	}
+
+	//links emailed to guests are signed with this, so it has to stay the same across restarts
+	app.SigningKey = []byte(os.Getenv("SIGNING_KEY"))
+	if len(app.SigningKey) == 0 {
+		key, err := helpers.RandomToken(32)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("SIGNING_KEY is not set, links sent to guests will stop working on restart")
+		app.SigningKey = []byte(key)
+	}
+
 	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	app.InfoLog = infoLog
 
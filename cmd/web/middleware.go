@@ -1,9 +1,15 @@
 package main
 
 import (
+	"BookingProject/pkg/handlers"
 	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/justinas/nosurf"
 )
@@ -28,6 +34,12 @@ func Nosurf(next http.Handler) http.Handler {
 		SameSite: http.SameSiteLaxMode,
 	})
 
+	//api clients and payment providers authenticate with keys and signatures instead of cookies,
+	//so they can't carry a csrf token
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/payments/webhook/")
+	})
+
 	return csrfHandler
 }
 
@@ -48,3 +60,76 @@ func Auth(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// APIAuth only lets through requests with an active api key in the Authorization: Bearer header
+func APIAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			unauthorized(w, "missing api key")
+			return
+		}
+
+		key, err := handlers.Repo.DB.GetAPIKeyByHash(helpers.HashAPIKey(token))
+		if errors.Is(err, sql.ErrNoRows) {
+			unauthorized(w, "invalid api key")
+			return
+		} else if err != nil {
+			helpers.ServerErrorJSON(w, err)
+			return
+		}
+
+		if key.Revoked() {
+			unauthorized(w, "api key has been revoked")
+			return
+		}
+
+		err = handlers.Repo.DB.UpdateAPIKeyLastUsed(key.ID)
+		if err != nil {
+			app.ErrorLog.Println(err)
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyCtxKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope rejects api requests whose key wasn't granted scope
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := r.Context().Value(apiKeyCtxKey).(models.APIKey)
+			if !ok || !key.HasScope(scope) {
+				helpers.ErrorJSON(w, http.StatusForbidden, helpers.APIError{
+					Code:    "forbidden",
+					Message: fmt.Sprintf("api key lacks the %s scope", scope),
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type contextKey string
+
+const apiKeyCtxKey contextKey = "api_key"
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	helpers.ErrorJSON(w, http.StatusUnauthorized, helpers.APIError{
+		Code:    "unauthorized",
+		Message: message,
+	})
+}
+
+// returns the token from an Authorization: Bearer header, or "" if there is none
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return ""
+	}
+
+	return strings.TrimSpace(header[7:])
+}
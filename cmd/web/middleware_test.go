@@ -1,7 +1,10 @@
 package main
 
 import (
+	"BookingProject/pkg/handlers"
+	"BookingProject/pkg/models"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -30,3 +33,39 @@ func TestSessionLoad(t *testing.T) {
 		t.Errorf("Type is not http.Handler but is %T", v)
 	}
 }
+
+func TestAPIAuth(t *testing.T) {
+	handlers.NewHandlers(handlers.NewTestRepo(&app))
+
+	var myH myHandler
+
+	tests := []struct {
+		name   string
+		header string
+		scope  string
+		status int
+	}{
+		{"no-header", "", models.ScopeRoomsRead, http.StatusUnauthorized},
+		{"wrong-scheme", "Basic read-key", models.ScopeRoomsRead, http.StatusUnauthorized},
+		{"unknown-key", "Bearer nope", models.ScopeRoomsRead, http.StatusUnauthorized},
+		{"revoked-key", "Bearer revoked-key", models.ScopeRoomsRead, http.StatusUnauthorized},
+		{"valid-key", "Bearer read-key", models.ScopeRoomsRead, http.StatusOK},
+		{"missing-scope", "Bearer read-key", models.ScopeReservationsWrite, http.StatusForbidden},
+	}
+
+	for _, e := range tests {
+		h := APIAuth(RequireScope(e.scope)(&myH))
+
+		req := httptest.NewRequest("GET", "/api/v1/rooms", nil)
+		if e.header != "" {
+			req.Header.Set("Authorization", e.header)
+		}
+		rr := httptest.NewRecorder()
+
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.status, rr.Code)
+		}
+	}
+}
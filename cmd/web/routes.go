@@ -3,6 +3,7 @@ package main
 import (
 	"BookingProject/pkg/config"
 	"BookingProject/pkg/handlers"
+	"BookingProject/pkg/models"
 	"net/http"
 
 	"github.com/go-chi/chi"
@@ -25,14 +26,32 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Get("/search-availability", handlers.Repo.Availability)
 	mux.Post("/search-availability", handlers.Repo.PostAvailability)
 	mux.Post("/search-availability-json", handlers.Repo.JSONAvailability)
+	mux.Get("/rooms/{id}/calendar.json", handlers.Repo.RoomCalendarJSON)
+	mux.Get("/api/openapi.json", handlers.Repo.OpenAPI)
+	mux.Get("/ical/rooms/{token}", handlers.Repo.RoomICalFeed)
+	mux.Get("/ical/staff/{token}", handlers.Repo.StaffICalFeed)
 	mux.Get("/choose-room/{id}", handlers.Repo.ChooseRoom)
 	mux.Get("/book-room", handlers.Repo.BookRoom)
 
+	mux.Get("/waitlist", handlers.Repo.Waitlist)
+	mux.Post("/waitlist", handlers.Repo.PostWaitlist)
+	mux.Get("/waitlist/{id}/book", handlers.Repo.WaitlistBook)
+
 	mux.Get("/contact", handlers.Repo.Contact)
 	mux.Get("/make-reservation", handlers.Repo.Reservation)
 	mux.Post("/make-reservation", handlers.Repo.PostReservation)
 
+	mux.Get("/checkout", handlers.Repo.Checkout)
+	mux.Post("/checkout", handlers.Repo.PostCheckout)
+	mux.Get("/payments/return", handlers.Repo.PaymentReturn)
+	mux.Post("/payments/webhook/{provider}", handlers.Repo.PaymentWebhook)
+	mux.Get("/payments/fake/{ref}", handlers.Repo.FakeGatewayPage)
+	mux.Post("/payments/fake/{ref}", handlers.Repo.PostFakeGateway)
+
 	mux.Get("/reservation-summary", handlers.Repo.ReservationSummary)
+	mux.Get("/reservations/{id}", handlers.Repo.GuestReservation)
+	mux.Get("/reservations/{id}/invoice", handlers.Repo.GuestInvoice)
+	mux.Get("/reservations/{id}/invoice.pdf", handlers.Repo.GuestInvoicePDF)
 	mux.Get("/user/login", handlers.Repo.ShowLogin)
 	mux.Post("/user/login", handlers.Repo.PostShowLogin)
 	mux.Get("/user/logout", handlers.Repo.Logout)
@@ -41,14 +60,33 @@ func routes(app *config.AppConfig) http.Handler {
 	fileServer := http.FileServer(http.Dir("./static/"))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
+	mux.Route("/api/v1", func(mux chi.Router) {
+		mux.Use(APIAuth)
+
+		mux.With(RequireScope(models.ScopeRoomsRead)).Get("/rooms", handlers.Repo.APIRooms)
+		mux.With(RequireScope(models.ScopeAvailabilityRead)).Get("/availability", handlers.Repo.APIAvailability)
+		mux.With(RequireScope(models.ScopeReservationsWrite)).Post("/reservations", handlers.Repo.APICreateReservation)
+		mux.With(RequireScope(models.ScopeReservationsRead)).Get("/reservations/{id}", handlers.Repo.APIReservation)
+		mux.With(RequireScope(models.ScopeReservationsWrite)).Put("/reservations/{id}", handlers.Repo.APIUpdateReservation)
+		mux.With(RequireScope(models.ScopeReservationsWrite)).Post("/reservations/{id}/cancel", handlers.Repo.APICancelReservation)
+	})
+
 	mux.Route("/admin", func(mux chi.Router) {
 		mux.Use(Auth)
 
 		//will actually have /admin preappended
 		mux.Get("/dashboard", handlers.Repo.AdminDashboard)
+		mux.Get("/today", handlers.Repo.AdminToday)
+		mux.Get("/check-in/{id}/do", handlers.Repo.AdminCheckIn)
+		mux.Get("/check-out/{id}/do", handlers.Repo.AdminCheckOut)
 
 		mux.Get("/reservations-new", handlers.Repo.AdminNewReservations)
+		mux.Get("/add-reservation", handlers.Repo.AdminAddReservation)
+		mux.Post("/add-reservation", handlers.Repo.AdminPostAddReservation)
+		mux.Get("/import-reservations", handlers.Repo.AdminImportReservations)
+		mux.Post("/import-reservations", handlers.Repo.AdminPostImportReservations)
 		mux.Get("/reservations-all", handlers.Repo.AdminAllReservations)
+		mux.Get("/export-reservations/{src}/{format}", handlers.Repo.AdminExportReservations)
 		mux.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
 		mux.Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
 		mux.Get("/process-reservation/{src}/{id}/do", handlers.Repo.AdminProcessReservation)
@@ -56,6 +94,39 @@ func routes(app *config.AppConfig) http.Handler {
 
 		mux.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservation)
 		mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
+		mux.Post("/reservations/{src}/{id}/notes", handlers.Repo.AdminPostReservationNote)
+		mux.Get("/reservations/{src}/{id}/invoice", handlers.Repo.AdminInvoice)
+		mux.Get("/reservations/{src}/{id}/invoice.pdf", handlers.Repo.AdminInvoicePDF)
+
+		mux.Get("/api-keys", handlers.Repo.AdminAPIKeys)
+		mux.Post("/api-keys", handlers.Repo.AdminPostAPIKey)
+		mux.Get("/revoke-api-key/{id}/do", handlers.Repo.AdminRevokeAPIKey)
+
+		mux.Get("/ical", handlers.Repo.AdminICal)
+		mux.Get("/regenerate-room-ical/{id}/do", handlers.Repo.AdminRegenerateRoomICal)
+		mux.Get("/regenerate-staff-ical/do", handlers.Repo.AdminRegenerateStaffICal)
+		mux.Post("/ical", handlers.Repo.AdminPostICalFeed)
+		mux.Get("/sync-ical-feed/{id}/do", handlers.Repo.AdminSyncICalFeed)
+		mux.Get("/delete-ical-feed/{id}/do", handlers.Repo.AdminDeleteICalFeed)
+
+		mux.Get("/rooms", handlers.Repo.AdminRooms)
+		mux.Post("/rooms/{id}", handlers.Repo.AdminPostRoom)
+		mux.Get("/taxes-fees", handlers.Repo.AdminCharges)
+		mux.Post("/taxes-fees", handlers.Repo.AdminPostCharge)
+		mux.Get("/delete-charge/{id}/do", handlers.Repo.AdminDeleteCharge)
+		mux.Get("/promo-codes", handlers.Repo.AdminPromoCodes)
+		mux.Post("/promo-codes", handlers.Repo.AdminPostPromoCode)
+		mux.Get("/delete-promo-code/{id}/do", handlers.Repo.AdminDeletePromoCode)
+
+		mux.Get("/blocks", handlers.Repo.AdminBlocks)
+		mux.Post("/blocks", handlers.Repo.AdminPostBlock)
+		mux.Get("/blocks/bulk", handlers.Repo.AdminBulkBlocks)
+		mux.Post("/blocks/bulk", handlers.Repo.AdminPostBulkBlocks)
+		mux.Get("/blocks/{id}", handlers.Repo.AdminShowBlock)
+		mux.Post("/blocks/{id}", handlers.Repo.AdminPostUpdateBlock)
+		mux.Get("/delete-block/{id}/do", handlers.Repo.AdminDeleteBlock)
+
+		mux.Get("/audit-log", handlers.Repo.AdminAuditLog)
 	})
 
 	return mux
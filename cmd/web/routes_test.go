@@ -2,6 +2,9 @@ package main
 
 import (
 	"BookingProject/pkg/config"
+	"BookingProject/pkg/handlers"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi"
@@ -19,3 +22,42 @@ func TestRoutes(t *testing.T) {
 		t.Errorf("Type is not *chi.mux but is %T", v)
 	}
 }
+
+// json routes are everything under /api, the old -json endpoints and anything else ending in .json
+func isJSONRoute(route string) bool {
+	return strings.HasPrefix(route, "/api/") || strings.HasSuffix(route, "-json") || strings.HasSuffix(route, ".json")
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var app config.AppConfig
+
+	mux := routes(&app).(*chi.Mux)
+	paths := handlers.OpenAPISpec()["paths"].(map[string]map[string]interface{})
+
+	routed := make(map[string]bool)
+
+	err := chi.Walk(mux, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/")
+		if !isJSONRoute(route) {
+			return nil
+		}
+
+		routed[method+" "+route] = true
+
+		if _, ok := paths[route][strings.ToLower(method)]; !ok {
+			t.Errorf("%s %s is in routes.go but missing from the openapi spec", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, ops := range paths {
+		for method := range ops {
+			if !routed[strings.ToUpper(method)+" "+path] {
+				t.Errorf("%s %s is in the openapi spec but not in routes.go", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
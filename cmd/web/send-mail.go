@@ -35,6 +35,10 @@ func sendMsg(m models.MailData) {
 	email.SetFrom(m.From).AddTo(m.To).SetSubject(m.Subject)
 	email.SetBody(mail.TextHTML, m.Content)
 
+	for _, a := range m.Attachments {
+		email.Attach(&mail.File{Name: a.Name, MimeType: a.MimeType, Data: a.Data})
+	}
+
 	err = email.Send(client)
 
 	if err != nil {
@@ -0,0 +1,75 @@
+// Package availability works out which stays a room is free for from the restrictions on it
+package availability
+
+import (
+	"BookingProject/pkg/models"
+	"time"
+)
+
+// Alternative is a stay on other dates, as long as the one asked for, that a room is free for
+type Alternative struct {
+	Room      models.Room
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// Free reports whether none of the restrictions on the room overlap the stay from start to end
+func Free(restrictions []models.RoomRestriction, roomID int, start, end time.Time) bool {
+	for _, r := range restrictions {
+		if r.RoomID == roomID && start.Before(r.EndDate) && end.After(r.StartDate) {
+			return false
+		}
+	}
+	return true
+}
+
+// Day says whether a room is free for the night starting on Date
+type Day struct {
+	Date      time.Time
+	Available bool
+}
+
+// Days lists the nights from start up to end, and whether the room is free for each of them
+func Days(restrictions []models.RoomRestriction, roomID int, start, end time.Time) []Day {
+	var days []Day
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, Day{Date: d, Available: Free(restrictions, roomID, d, d.AddDate(0, 0, 1))})
+	}
+	return days
+}
+
+// Nearby finds the stays as long as start to end that each room is free for, moved by up to window days
+// either way and starting no earlier than earliest. The closest come first, the earlier of two as close,
+// and no more than perRoom are given for a room. restrictions has to cover the whole window
+func Nearby(rooms []models.Room, restrictions []models.RoomRestriction, start, end, earliest time.Time, window, perRoom int) []Alternative {
+	var alternatives []Alternative
+
+	for _, room := range rooms {
+		found := 0
+		for _, days := range shifts(window) {
+			if found == perRoom {
+				break
+			}
+
+			s := start.AddDate(0, 0, days)
+			e := end.AddDate(0, 0, days)
+			if s.Before(earliest) || !Free(restrictions, room.ID, s, e) {
+				continue
+			}
+
+			alternatives = append(alternatives, Alternative{Room: room, StartDate: s, EndDate: e})
+			found++
+		}
+	}
+
+	return alternatives
+}
+
+// shifts lists the days a stay can be moved by, closest first: -1, 1, -2, 2 and so on
+func shifts(window int) []int {
+	var days []int
+	for d := 1; d <= window; d++ {
+		days = append(days, -d, d)
+	}
+	return days
+}
@@ -0,0 +1,108 @@
+package availability
+
+import (
+	"BookingProject/pkg/models"
+	"testing"
+	"time"
+)
+
+func day(d int) time.Time {
+	return time.Date(2050, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestFree(t *testing.T) {
+	restrictions := []models.RoomRestriction{
+		{RoomID: 1, StartDate: day(10), EndDate: day(12)},
+	}
+
+	var tests = []struct {
+		name     string
+		roomID   int
+		start    time.Time
+		end      time.Time
+		expected bool
+	}{
+		{"before", 1, day(8), day(10), true},
+		{"after", 1, day(12), day(14), true},
+		{"overlapping the start", 1, day(9), day(11), false},
+		{"overlapping the end", 1, day(11), day(13), false},
+		{"inside", 1, day(10), day(11), false},
+		{"around", 1, day(9), day(13), false},
+		{"another room", 2, day(10), day(12), true},
+	}
+
+	for _, e := range tests {
+		if got := Free(restrictions, e.roomID, e.start, e.end); got != e.expected {
+			t.Errorf("%s: got %t, wanted %t", e.name, got, e.expected)
+		}
+	}
+}
+
+func TestNearby(t *testing.T) {
+	rooms := []models.Room{{ID: 1, RoomName: "General's Quarters"}, {ID: 2, RoomName: "Major's Suite"}}
+
+	// room 1 is taken on the 9th to the 13th, room 2 from the 5th to the 14th
+	restrictions := []models.RoomRestriction{
+		{RoomID: 1, StartDate: day(9), EndDate: day(13)},
+		{RoomID: 2, StartDate: day(5), EndDate: day(14)},
+	}
+
+	alternatives := Nearby(rooms, restrictions, day(10), day(12), day(1), 3, 2)
+
+	expected := []Alternative{
+		{Room: rooms[0], StartDate: day(7), EndDate: day(9)},
+		{Room: rooms[0], StartDate: day(13), EndDate: day(15)},
+	}
+
+	if len(alternatives) != len(expected) {
+		t.Fatalf("got %d alternatives, wanted %d: %v", len(alternatives), len(expected), alternatives)
+	}
+
+	for i, a := range alternatives {
+		if a.Room.ID != expected[i].Room.ID || !a.StartDate.Equal(expected[i].StartDate) || !a.EndDate.Equal(expected[i].EndDate) {
+			t.Errorf("alternative %d: got room %d from %s to %s, wanted room %d from %s to %s", i, a.Room.ID,
+				a.StartDate.Format("2006-01-02"), a.EndDate.Format("2006-01-02"), expected[i].Room.ID,
+				expected[i].StartDate.Format("2006-01-02"), expected[i].EndDate.Format("2006-01-02"))
+		}
+	}
+
+	// nothing before the earliest date is offered
+	alternatives = Nearby(rooms, restrictions, day(10), day(12), day(10), 3, 2)
+	if len(alternatives) != 1 || !alternatives[0].StartDate.Equal(day(13)) {
+		t.Errorf("got %v, wanted only room 1 from the 13th", alternatives)
+	}
+
+	// the closest come first, the earlier of two as close
+	alternatives = Nearby(rooms[:1], nil, day(10), day(12), day(1), 2, 5)
+	var starts []int
+	for _, a := range alternatives {
+		starts = append(starts, a.StartDate.Day())
+	}
+	if len(starts) != 4 || starts[0] != 9 || starts[1] != 11 || starts[2] != 8 || starts[3] != 12 {
+		t.Errorf("got starts %v, wanted [9 11 8 12]", starts)
+	}
+}
+
+func TestDays(t *testing.T) {
+	restrictions := []models.RoomRestriction{
+		{RoomID: 1, StartDate: day(2), EndDate: day(4)},
+		{RoomID: 2, StartDate: day(1), EndDate: day(6)},
+	}
+
+	days := Days(restrictions, 1, day(1), day(6))
+
+	// the night the guest leaves on is free again
+	expected := []bool{true, false, false, true, true}
+	if len(days) != len(expected) {
+		t.Fatalf("got %d days, wanted %d", len(days), len(expected))
+	}
+
+	for i, d := range days {
+		if !d.Date.Equal(day(i + 1)) {
+			t.Errorf("day %d is %s", i, d.Date.Format("2006-01-02"))
+		}
+		if d.Available != expected[i] {
+			t.Errorf("%s: got %t, wanted %t", d.Date.Format("2006-01-02"), d.Available, expected[i])
+		}
+	}
+}
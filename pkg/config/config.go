@@ -2,6 +2,7 @@ package config
 
 import (
 	"BookingProject/pkg/models"
+	"BookingProject/pkg/payments"
 	"html/template"
 	"log"
 
@@ -18,4 +19,12 @@ type AppConfig struct {
 	InProd        bool
 	Session       *scs.SessionManager
 	MailChan      chan models.MailData
+	Payments      payments.Gateway
+	SigningKey    []byte
+
+	// BaseURL is the scheme and host the site is reached on, for links in emails and for payment callbacks
+	BaseURL string
+
+	// SearchWindowDays is how far either side of the dates asked for a flexible search looks
+	SearchWindowDays int
 }
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 )
@@ -61,3 +63,52 @@ func (f *Form) IsEmail(field string) {
 		f.Errors.Add(field, "Invalid Email Address")
 	}
 }
+
+// checks for an absolute http or https url
+func (f *Form) IsURL(field string) {
+	u, err := url.ParseRequestURI(f.Get(field))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		f.Errors.Add(field, "Invalid URL")
+	}
+}
+
+// checks for a code people can type, like a promo code: 3 to 32 letters, digits and dashes
+func (f *Form) IsCode(field string) {
+	code := f.Get(field)
+	ok := len(code) >= 3 && len(code) <= 32
+	for _, r := range code {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			ok = false
+		}
+	}
+
+	if !ok {
+		f.Errors.Add(field, "Use 3 to 32 letters, numbers and dashes")
+	}
+}
+
+// reads a date field that may be left blank, which gives the zero time
+func (f *Form) OptionalDate(field string) time.Time {
+	if f.Get(field) == "" {
+		return time.Time{}
+	}
+
+	d, err := time.Parse("2006-01-02", f.Get(field))
+	if err != nil {
+		f.Errors.Add(field, "Enter a date like 2050-01-31")
+	}
+	return d
+}
+
+// reads a whole number field that may be left blank, which gives zero
+func (f *Form) OptionalCount(field string) int {
+	if f.Get(field) == "" {
+		return 0
+	}
+
+	n, err := strconv.Atoi(f.Get(field))
+	if err != nil || n < 0 {
+		f.Errors.Add(field, "Enter a whole number")
+	}
+	return n
+}
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestForm_Valid(t *testing.T) {
@@ -126,3 +127,101 @@ func TestForm_IsEmail(t *testing.T) {
 	}
 
 }
+
+func TestForm_IsURL(t *testing.T) {
+	var tests = []struct {
+		value string
+		valid bool
+	}{
+		{"https://www.example.com/calendar.ics?s=1", true},
+		{"http://localhost:8080/ical", true},
+		{"ftp://example.com/calendar.ics", false},
+		{"www.example.com/calendar.ics", false},
+		{"", false},
+	}
+
+	for _, e := range tests {
+		postedValues := url.Values{}
+		postedValues.Add("url", e.value)
+
+		form := New(postedValues)
+		form.IsURL("url")
+
+		if form.Valid() != e.valid {
+			t.Errorf("for %q expected valid to be %t", e.value, e.valid)
+		}
+	}
+}
+
+func TestForm_IsCode(t *testing.T) {
+	var tests = []struct {
+		value string
+		valid bool
+	}{
+		{"SPRING-25", true},
+		{"abc", true},
+		{"AB", false},
+		{"SUMMER 25", false},
+		{"CAFÉ", false},
+		{"", false},
+	}
+
+	for _, e := range tests {
+		postedValues := url.Values{}
+		postedValues.Add("code", e.value)
+
+		form := New(postedValues)
+		form.IsCode("code")
+
+		if form.Valid() != e.valid {
+			t.Errorf("for %q expected valid to be %t", e.value, e.valid)
+		}
+	}
+}
+
+func TestForm_OptionalDate(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected time.Time
+		valid    bool
+	}{
+		{"2050-01-31", time.Date(2050, 1, 31, 0, 0, 0, 0, time.UTC), true},
+		{"", time.Time{}, true},
+		{"31/01/2050", time.Time{}, false},
+	}
+
+	for _, e := range tests {
+		form := New(url.Values{"date": {e.value}})
+
+		if d := form.OptionalDate("date"); !d.Equal(e.expected) {
+			t.Errorf("for %q expected %s, got %s", e.value, e.expected, d)
+		}
+		if form.Valid() != e.valid {
+			t.Errorf("for %q expected valid to be %t", e.value, e.valid)
+		}
+	}
+}
+
+func TestForm_OptionalCount(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected int
+		valid    bool
+	}{
+		{"3", 3, true},
+		{"", 0, true},
+		{"three", 0, false},
+		{"-1", -1, false},
+	}
+
+	for _, e := range tests {
+		form := New(url.Values{"count": {e.value}})
+
+		if n := form.OptionalCount("count"); n != e.expected {
+			t.Errorf("for %q expected %d, got %d", e.value, e.expected, n)
+		}
+		if form.Valid() != e.valid {
+			t.Errorf("for %q expected valid to be %t", e.value, e.valid)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"BookingProject/pkg/models"
+	"net/url"
+	"time"
+)
+
+// how many weeks the reservations calendar shows unless asked, and the most it shows at once
+const (
+	defaultTimelineWeeks = 4
+	maxTimelineWeeks     = 26
+)
+
+// timelineSpans are the lengths, in weeks, the reservations calendar offers to switch to
+var timelineSpans = []int{2, 4, 8, 13, 26}
+
+// timelineMonth is a month heading over the nights of it the reservations calendar shows
+type timelineMonth struct {
+	Name   string
+	Nights int
+}
+
+// timelineRow is a room's line on the reservations calendar
+type timelineRow struct {
+	Room  models.Room
+	Cells []timelineCell
+}
+
+// timelineCell is a free night, or a restriction over as many of the nights shown as it covers
+type timelineCell struct {
+	Date        time.Time
+	Nights      int
+	Free        bool
+	Restriction models.RoomRestriction
+}
+
+// Kind is what the cell shows: free, reservation, block or external
+func (c timelineCell) Kind() string {
+	switch {
+	case c.Free:
+		return "free"
+	case c.Restriction.ReservationID > 0:
+		return "reservation"
+	case c.Restriction.RestrictionID == models.RestrictionOwnerBlock:
+		return "block"
+	default:
+		return "external"
+	}
+}
+
+// buildTimeline lays the restrictions out along the nights from start up to end, a row per room. A
+// restriction is a single cell, cut short at either end of the timeline. It also returns the owner blocks
+// on each room by the first night they're shown on, for saving the calendar against
+func buildTimeline(rooms []models.Room, restrictions []models.RoomRestriction, start, end time.Time) ([]timelineRow, map[int]map[string]int) {
+	var nights []time.Time
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		nights = append(nights, d)
+	}
+
+	var rows []timelineRow
+	blocks := make(map[int]map[string]int)
+
+	for _, room := range rooms {
+		//which restriction, counting from 1, has each night. the first one wins if they overlap
+		taken := make([]int, len(nights))
+		for i, r := range restrictions {
+			if r.RoomID != room.ID {
+				continue
+			}
+			for n, d := range nights {
+				if taken[n] == 0 && !d.Before(r.StartDate) && d.Before(r.EndDate) {
+					taken[n] = i + 1
+				}
+			}
+		}
+
+		row := timelineRow{Room: room}
+		blocks[room.ID] = make(map[string]int)
+
+		for n := 0; n < len(nights); {
+			cell := timelineCell{Date: nights[n], Nights: 1, Free: taken[n] == 0}
+			if !cell.Free {
+				cell.Restriction = restrictions[taken[n]-1]
+				for n+cell.Nights < len(nights) && taken[n+cell.Nights] == taken[n] {
+					cell.Nights++
+				}
+				if cell.Kind() == "block" {
+					blocks[room.ID][cell.Date.Format("2006-01-02")] = cell.Restriction.ID
+				}
+			}
+
+			row.Cells = append(row.Cells, cell)
+			n += cell.Nights
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, blocks
+}
+
+// timelineMonths heads the nights from start up to end with the months they fall in
+func timelineMonths(start, end time.Time) []timelineMonth {
+	var months []timelineMonth
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if len(months) == 0 || d.Day() == 1 {
+			months = append(months, timelineMonth{Name: d.Format("January 2006")})
+		}
+		months[len(months)-1].Nights++
+	}
+	return months
+}
+
+// calendarLink is the reservations calendar from start for weeks, as it was when an admin left it
+func calendarLink(start, weeks string) string {
+	if start == "" {
+		return "/admin/reservations-calendar"
+	}
+	return "/admin/reservations-calendar?" + url.Values{"start": {start}, "weeks": {weeks}}.Encode()
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"BookingProject/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2050, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	rooms := []models.Room{{ID: 1}, {ID: 2}}
+	restrictions := []models.RoomRestriction{
+		// starts before the timeline does
+		{ID: 1, RoomID: 1, ReservationID: 7, RestrictionID: models.RestrictionReservation, StartDate: day(1), EndDate: day(4)},
+		{ID: 2, RoomID: 1, RestrictionID: models.RestrictionOwnerBlock, StartDate: day(5), EndDate: day(6)},
+		// runs past the end of it
+		{ID: 3, RoomID: 2, RestrictionID: models.RestrictionExternal, StartDate: day(6), EndDate: day(12)},
+	}
+
+	rows, blocks := buildTimeline(rooms, restrictions, day(2), day(9))
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	var tests = []struct {
+		row    int
+		kinds  []string
+		nights []int
+	}{
+		{0, []string{"reservation", "free", "block", "free", "free", "free"}, []int{2, 1, 1, 1, 1, 1}},
+		{1, []string{"free", "free", "free", "free", "external"}, []int{1, 1, 1, 1, 3}},
+	}
+
+	for _, e := range tests {
+		cells := rows[e.row].Cells
+		if len(cells) != len(e.kinds) {
+			t.Errorf("row %d: expected %d cells, got %d", e.row, len(e.kinds), len(cells))
+			continue
+		}
+		for i, c := range cells {
+			if c.Kind() != e.kinds[i] || c.Nights != e.nights[i] {
+				t.Errorf("row %d cell %d: expected %s for %d nights, got %s for %d", e.row, i, e.kinds[i], e.nights[i], c.Kind(), c.Nights)
+			}
+		}
+	}
+
+	if !rows[0].Cells[0].Date.Equal(day(2)) {
+		t.Errorf("expected the reservation to be cut to start on the first night shown, got %s", rows[0].Cells[0].Date)
+	}
+
+	if blocks[1]["2050-01-05"] != 2 || len(blocks[1]) != 1 || len(blocks[2]) != 0 {
+		t.Errorf("unexpected block maps %v", blocks)
+	}
+}
+
+func TestTimelineMonths(t *testing.T) {
+	months := timelineMonths(time.Date(2050, 1, 25, 0, 0, 0, 0, time.UTC), time.Date(2050, 3, 3, 0, 0, 0, 0, time.UTC))
+
+	expected := []timelineMonth{{"January 2050", 7}, {"February 2050", 28}, {"March 2050", 2}}
+	if len(months) != len(expected) {
+		t.Fatalf("expected %d months, got %v", len(expected), months)
+	}
+	for i := range expected {
+		if months[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], months[i])
+		}
+	}
+}
+
+func TestCalendarLink(t *testing.T) {
+	if l := calendarLink("", ""); l != "/admin/reservations-calendar" {
+		t.Errorf("unexpected link %s", l)
+	}
+	if l := calendarLink("2050-01-01", "8"); l != "/admin/reservations-calendar?start=2050-01-01&weeks=8" {
+		t.Errorf("unexpected link %s", l)
+	}
+}
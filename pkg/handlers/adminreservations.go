@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/pricing"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// staffSources are where the reservations staff take come from, in the order they're offered
+var staffSources = []string{models.SourcePhone, models.SourceWalkIn, models.SourceEmail}
+
+// AdminAddReservation shows the form for staff to book a guest in over the phone, at the desk or by
+// email. The room and dates can be filled in from the query
+func (m *Repository) AdminAddReservation(w http.ResponseWriter, req *http.Request) {
+	form := forms.New(req.URL.Query())
+	if form.Get("source") == "" {
+		form.Set("source", models.SourcePhone)
+	}
+
+	m.renderAdminAddReservation(w, req, form)
+}
+
+func (m *Repository) renderAdminAddReservation(w http.ResponseWriter, req *http.Request, form *forms.Form) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+	data["sources"] = staffSources
+
+	render.Template(w, req, "admin-add-reservation.page.html", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminPostAddReservation books a room for a guest, putting the reservation and the room restriction for
+// it in together. A stay shorter than the room's minimum needs a reason, which is kept and logged
+func (m *Repository) AdminPostAddReservation(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	form.Required("first_name", "last_name", "room_id", "start", "end", "source")
+	if form.Get("email") != "" {
+		form.IsEmail("email")
+	}
+
+	source := form.Get("source")
+	known := false
+	for _, s := range staffSources {
+		known = known || s == source
+	}
+	if source != "" && !known {
+		form.Errors.Add("source", "Choose where the booking came from")
+	}
+
+	res := models.Reservation{
+		FirstName: form.Get("first_name"),
+		LastName:  form.Get("last_name"),
+		Email:     form.Get("email"),
+		Phone:     form.Get("phone"),
+		StartDate: form.OptionalDate("start"),
+		EndDate:   form.OptionalDate("end"),
+		Status:    models.ReservationConfirmed,
+		Source:    source,
+	}
+	res.SpecialRequests = checkSpecialRequests(form)
+
+	if !res.StartDate.IsZero() && !res.EndDate.IsZero() && !res.EndDate.After(res.StartDate) {
+		form.Errors.Add("end", "Check-out has to be after check-in")
+	}
+
+	res.RoomID, err = strconv.Atoi(form.Get("room_id"))
+	if err == nil {
+		res.Room, err = m.DB.GetRoomByID(res.RoomID)
+	}
+	if err != nil && form.Get("room_id") != "" {
+		form.Errors.Add("room_id", "Choose a room")
+	}
+
+	if problem := pricing.MinStayProblem(res.Room, res.StartDate, res.EndDate); form.Valid() && problem != "" {
+		res.MinStayOverride = form.Get("override_reason")
+		if res.MinStayOverride == "" {
+			form.Errors.Add("override_reason", problem+". Give a reason to book it for fewer")
+		} else if len(res.MinStayOverride) > 255 {
+			form.Errors.Add("override_reason", "Keep the reason under 255 characters")
+		}
+	}
+
+	if !form.Valid() {
+		m.renderAdminAddReservation(w, req, form)
+		return
+	}
+
+	res.ID, err = m.DB.InsertReservationWithRestriction(res)
+	if errors.Is(err, sql.ErrNoRows) {
+		form.Errors.Add("start", "The room is already taken for some of those nights")
+		m.renderAdminAddReservation(w, req, form)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.audit(req, models.AuditCreate, models.EntityReservation, res.ID, nil, res)
+
+	if res.MinStayOverride != "" {
+		m.App.InfoLog.Printf("reservation %d booked under the %d night minimum for %s: %s",
+			res.ID, res.Room.MinNights, res.Room.RoomName, res.MinStayOverride)
+	}
+
+	if res.Email != "" && form.Get("send_confirmation") != "" {
+		m.sendConfirmation(res)
+	}
+
+	m.App.Session.Put(req.Context(), "flash", "Reservation added")
+	http.Redirect(w, req, fmt.Sprintf("/admin/reservations/all/%d/show", res.ID), http.StatusSeeOther)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminPostAddReservation(t *testing.T) {
+	guest := func(extra url.Values) url.Values {
+		v := url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"phone":      {"555-555-5555"},
+			"room_id":    {"1"},
+			"start":      {"2050-01-01"},
+			"end":        {"2050-01-03"},
+			"source":     {"phone"},
+		}
+		for k, s := range extra {
+			v[k] = s
+		}
+		return v
+	}
+
+	var tests = []struct {
+		name             string
+		postedData       url.Values
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"phone booking", guest(nil), http.StatusSeeOther, "/admin/reservations/all/1/show"},
+		{"walk-in with confirmation", guest(url.Values{"source": {"walk-in"}, "email": {"john@smith.com"}, "send_confirmation": {"1"}}), http.StatusSeeOther, "/admin/reservations/all/1/show"},
+		{"missing name", guest(url.Values{"first_name": {""}}), http.StatusOK, ""},
+		{"bad email", guest(url.Values{"email": {"john"}}), http.StatusOK, ""},
+		{"special requests too long", guest(url.Values{"special_requests": {strings.Repeat("x", maxSpecialRequests+1)}}), http.StatusOK, ""},
+		{"website source", guest(url.Values{"source": {"website"}}), http.StatusOK, ""},
+		{"unknown room", guest(url.Values{"room_id": {"3"}}), http.StatusOK, ""},
+		{"dates reversed", guest(url.Values{"start": {"2050-01-03"}, "end": {"2050-01-01"}}), http.StatusOK, ""},
+		{"room taken", guest(url.Values{"start": {"2070-01-01"}, "end": {"2070-01-03"}}), http.StatusOK, ""},
+		{"database error", guest(url.Values{"start": {"2060-01-01"}, "end": {"2060-01-03"}}), http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/add-reservation", strings.NewReader(e.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostAddReservation)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedCode)
+		}
+
+		if e.expectedLocation != "" {
+			location, _ := rr.Result().Location()
+			if location.String() != e.expectedLocation {
+				t.Errorf("%s: expected location %s, got %s", e.name, e.expectedLocation, location.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/pricing"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// dates in the api are always plain calendar days
+const apiDateLayout = "2006-01-02"
+
+// APIRoom is a room as returned by the api
+type APIRoom struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// APIReservation is a reservation as returned by the api
+type APIReservation struct {
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	RoomID    int    `json:"room_id"`
+	RoomName  string `json:"room_name,omitempty"`
+	Status    string `json:"status"`
+}
+
+// APIAvailability is the answer to an availability search
+type APIAvailability struct {
+	StartDate string    `json:"start_date"`
+	EndDate   string    `json:"end_date"`
+	RoomID    int       `json:"room_id,omitempty"`
+	Available bool      `json:"available"`
+	Rooms     []APIRoom `json:"rooms"`
+}
+
+// APIReservationRequest is the body for creating a reservation
+type APIReservationRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	RoomID    int    `json:"room_id"`
+}
+
+// APIGuestRequest is the body for updating the guest details of a reservation
+type APIGuestRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+}
+
+// APIResponse wraps every successful api response
+type APIResponse struct {
+	Data interface{} `json:"data"`
+}
+
+func toAPIRoom(r models.Room) APIRoom {
+	return APIRoom{
+		ID:   r.ID,
+		Name: r.RoomName,
+	}
+}
+
+func toAPIReservation(r models.Reservation) APIReservation {
+	return APIReservation{
+		ID:        r.ID,
+		FirstName: r.FirstName,
+		LastName:  r.LastName,
+		Email:     r.Email,
+		Phone:     r.Phone,
+		StartDate: r.StartDate.Format(apiDateLayout),
+		EndDate:   r.EndDate.Format(apiDateLayout),
+		RoomID:    r.RoomID,
+		RoomName:  r.Room.RoomName,
+		Status:    r.Status,
+	}
+}
+
+// parses a start/end pair and checks that the stay is at least one night
+func parseAPIDates(sd, ed string) (time.Time, time.Time, *helpers.APIError) {
+	startDate, err := time.Parse(apiDateLayout, sd)
+	if err != nil {
+		return startDate, startDate, &helpers.APIError{
+			Code:    "invalid_date",
+			Message: "start_date must be in YYYY-MM-DD format",
+		}
+	}
+
+	endDate, err := time.Parse(apiDateLayout, ed)
+	if err != nil {
+		return startDate, endDate, &helpers.APIError{
+			Code:    "invalid_date",
+			Message: "end_date must be in YYYY-MM-DD format",
+		}
+	}
+
+	if !endDate.After(startDate) {
+		return startDate, endDate, &helpers.APIError{
+			Code:    "invalid_date",
+			Message: "end_date must be after start_date",
+		}
+	}
+
+	return startDate, endDate, nil
+}
+
+// validates guest details with the same rules as the reservation form
+func validateGuest(g APIGuestRequest) *forms.Form {
+	form := forms.New(url.Values{
+		"first_name": {g.FirstName},
+		"last_name":  {g.LastName},
+		"email":      {g.Email},
+		"phone":      {g.Phone},
+	})
+
+	form.Required("first_name", "last_name", "email", "phone")
+	form.IsEmail("email")
+
+	return form
+}
+
+func validationError(w http.ResponseWriter, form *forms.Form) {
+	helpers.ErrorJSON(w, http.StatusUnprocessableEntity, helpers.APIError{
+		Code:    "validation_failed",
+		Message: "the request contains invalid fields",
+		Fields:  form.Errors,
+	})
+}
+
+// decodes a json request body, rejecting unknown fields
+func decodeJSON(w http.ResponseWriter, req *http.Request, dst interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<20))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		helpers.ErrorJSON(w, http.StatusBadRequest, helpers.APIError{
+			Code:    "invalid_json",
+			Message: err.Error(),
+		})
+		return false
+	}
+
+	return true
+}
+
+// loads the reservation named in the url, writing the error response itself when it can't
+func (m *Repository) apiReservationFromURL(w http.ResponseWriter, req *http.Request) (models.Reservation, bool) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ErrorJSON(w, http.StatusBadRequest, helpers.APIError{
+			Code:    "invalid_id",
+			Message: "reservation id must be a number",
+		})
+		return models.Reservation{}, false
+	}
+
+	res, err := m.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusNotFound, helpers.APIError{
+			Code:    "not_found",
+			Message: fmt.Sprintf("reservation %d does not exist", id),
+		})
+		return res, false
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return res, false
+	}
+
+	return res, true
+}
+
+// lists all rooms
+func (m *Repository) APIRooms(w http.ResponseWriter, req *http.Request) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	out := []APIRoom{}
+	for _, r := range rooms {
+		out = append(out, toAPIRoom(r))
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, APIResponse{Data: out})
+}
+
+// searches availability for one room, or for every room when room_id is left out
+func (m *Repository) APIAvailability(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	startDate, endDate, apiErr := parseAPIDates(q.Get("start_date"), q.Get("end_date"))
+	if apiErr != nil {
+		helpers.ErrorJSON(w, http.StatusBadRequest, *apiErr)
+		return
+	}
+
+	result := APIAvailability{
+		StartDate: startDate.Format(apiDateLayout),
+		EndDate:   endDate.Format(apiDateLayout),
+		Rooms:     []APIRoom{},
+	}
+
+	if q.Get("room_id") != "" {
+		roomID, err := strconv.Atoi(q.Get("room_id"))
+		if err != nil {
+			helpers.ErrorJSON(w, http.StatusBadRequest, helpers.APIError{
+				Code:    "invalid_id",
+				Message: "room_id must be a number",
+			})
+			return
+		}
+
+		room, err := m.DB.GetRoomByID(roomID)
+		if errors.Is(err, sql.ErrNoRows) {
+			helpers.ErrorJSON(w, http.StatusNotFound, helpers.APIError{
+				Code:    "not_found",
+				Message: fmt.Sprintf("room %d does not exist", roomID),
+			})
+			return
+		} else if err != nil {
+			helpers.ServerErrorJSON(w, err)
+			return
+		}
+
+		available, err := m.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, roomID)
+		if err != nil {
+			helpers.ServerErrorJSON(w, err)
+			return
+		}
+
+		result.RoomID = roomID
+		result.Available = available
+		if available {
+			result.Rooms = append(result.Rooms, APIRoom{ID: roomID, Name: room.RoomName})
+		}
+
+		helpers.WriteJSON(w, http.StatusOK, APIResponse{Data: result})
+		return
+	}
+
+	rooms, err := m.DB.SearchAvailabilityForAllRooms(startDate, endDate)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	for _, r := range rooms {
+		result.Rooms = append(result.Rooms, toAPIRoom(r))
+	}
+	result.Available = len(result.Rooms) > 0
+
+	helpers.WriteJSON(w, http.StatusOK, APIResponse{Data: result})
+}
+
+// creates a reservation and blocks the room for its dates, unless it is already taken
+func (m *Repository) APICreateReservation(w http.ResponseWriter, req *http.Request) {
+	var body APIReservationRequest
+	if !decodeJSON(w, req, &body) {
+		return
+	}
+
+	form := validateGuest(APIGuestRequest{
+		FirstName: body.FirstName,
+		LastName:  body.LastName,
+		Email:     body.Email,
+		Phone:     body.Phone,
+	})
+	if !form.Valid() {
+		validationError(w, form)
+		return
+	}
+
+	startDate, endDate, apiErr := parseAPIDates(body.StartDate, body.EndDate)
+	if apiErr != nil {
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, *apiErr)
+		return
+	}
+
+	room, err := m.DB.GetRoomByID(body.RoomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, helpers.APIError{
+			Code:    "invalid_room",
+			Message: fmt.Sprintf("room %d does not exist", body.RoomID),
+		})
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	if problem := pricing.MinStayProblem(room, startDate, endDate); problem != "" {
+		helpers.ErrorJSON(w, http.StatusUnprocessableEntity, helpers.APIError{
+			Code:    "too_short",
+			Message: problem,
+		})
+		return
+	}
+
+	reservation := models.Reservation{
+		FirstName: body.FirstName,
+		LastName:  body.LastName,
+		Email:     body.Email,
+		Phone:     body.Phone,
+		StartDate: startDate,
+		EndDate:   endDate,
+		RoomID:    body.RoomID,
+		Room:      room,
+		Status:    models.ReservationConfirmed,
+		Source:    models.SourceAPI,
+	}
+
+	//the room is checked and taken together, so nothing can book it in between
+	reservation.ID, err = m.DB.InsertReservationWithRestriction(reservation)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusConflict, helpers.APIError{
+			Code:    "unavailable",
+			Message: "the room is not available for these dates",
+		})
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	m.sendConfirmation(reservation)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/reservations/%d", reservation.ID))
+	helpers.WriteJSON(w, http.StatusCreated, APIResponse{Data: toAPIReservation(reservation)})
+}
+
+// returns one reservation
+func (m *Repository) APIReservation(w http.ResponseWriter, req *http.Request) {
+	res, ok := m.apiReservationFromURL(w, req)
+	if !ok {
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, APIResponse{Data: toAPIReservation(res)})
+}
+
+// replaces the guest details of a reservation
+func (m *Repository) APIUpdateReservation(w http.ResponseWriter, req *http.Request) {
+	res, ok := m.apiReservationFromURL(w, req)
+	if !ok {
+		return
+	}
+
+	var body APIGuestRequest
+	if !decodeJSON(w, req, &body) {
+		return
+	}
+
+	form := validateGuest(body)
+	if !form.Valid() {
+		validationError(w, form)
+		return
+	}
+
+	if res.Status == models.ReservationCancelled {
+		helpers.ErrorJSON(w, http.StatusConflict, helpers.APIError{
+			Code:    "cancelled",
+			Message: "cancelled reservations can't be changed",
+		})
+		return
+	}
+
+	res.FirstName = body.FirstName
+	res.LastName = body.LastName
+	res.Email = body.Email
+	res.Phone = body.Phone
+
+	err := m.DB.UpdateReservation(res)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, APIResponse{Data: toAPIReservation(res)})
+}
+
+// cancels a reservation, freeing the room for its dates
+func (m *Repository) APICancelReservation(w http.ResponseWriter, req *http.Request) {
+	res, ok := m.apiReservationFromURL(w, req)
+	if !ok {
+		return
+	}
+
+	if res.Status == models.ReservationCancelled {
+		helpers.ErrorJSON(w, http.StatusConflict, helpers.APIError{
+			Code:    "cancelled",
+			Message: "reservation is already cancelled",
+		})
+		return
+	}
+	if !res.Open() {
+		helpers.ErrorJSON(w, http.StatusConflict, helpers.APIError{
+			Code:    "checked_in",
+			Message: "the guest has checked in, so the reservation can't be cancelled",
+		})
+		return
+	}
+
+	err := m.DB.CancelReservation(res.ID)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	m.NotifyWaitlist()
+
+	res.Status = models.ReservationCancelled
+	helpers.WriteJSON(w, http.StatusOK, APIResponse{Data: toAPIReservation(res)})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"BookingProject/pkg/helpers"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// apiTests is the data for the json api handlers, /api/v1
+var apiTests = []struct {
+	name               string
+	method             string
+	url                string
+	body               string
+	expectedStatusCode int
+	expectedErrorCode  string
+}{
+	{"openapi", "GET", "/api/openapi.json", "", http.StatusOK, ""},
+	{"rooms", "GET", "/api/v1/rooms", "", http.StatusOK, ""},
+	{"availability-all-rooms", "GET", "/api/v1/availability?start_date=2040-01-01&end_date=2040-01-02", "", http.StatusOK, ""},
+	{"availability-one-room", "GET", "/api/v1/availability?start_date=2040-01-01&end_date=2040-01-02&room_id=1", "", http.StatusOK, ""},
+	{"availability-bad-date", "GET", "/api/v1/availability?start_date=invalid&end_date=2040-01-02", "", http.StatusBadRequest, "invalid_date"},
+	{"availability-end-before-start", "GET", "/api/v1/availability?start_date=2040-01-05&end_date=2040-01-02", "", http.StatusBadRequest, "invalid_date"},
+	{"availability-unknown-room", "GET", "/api/v1/availability?start_date=2040-01-01&end_date=2040-01-02&room_id=3", "", http.StatusNotFound, "not_found"},
+	{"availability-db-error", "GET", "/api/v1/availability?start_date=2060-01-01&end_date=2060-01-02&room_id=1", "", http.StatusInternalServerError, "server_error"},
+	{"availability-room-db-error", "GET", "/api/v1/availability?start_date=2040-01-01&end_date=2040-01-02&room_id=1000", "", http.StatusInternalServerError, "server_error"},
+	{
+		"create-reservation", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","phone":"555","start_date":"2040-01-01","end_date":"2040-01-02","room_id":1}`,
+		http.StatusCreated, "",
+	},
+	{
+		"create-reservation-unavailable", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","phone":"555","start_date":"2070-01-01","end_date":"2070-01-02","room_id":1}`,
+		http.StatusConflict, "unavailable",
+	},
+	{
+		"create-reservation-db-error", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","phone":"555","start_date":"2060-01-01","end_date":"2060-01-02","room_id":1}`,
+		http.StatusInternalServerError, "server_error",
+	},
+	{
+		"create-reservation-invalid-email", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john","phone":"555","start_date":"2040-01-01","end_date":"2040-01-02","room_id":1}`,
+		http.StatusUnprocessableEntity, "validation_failed",
+	},
+	{
+		"create-reservation-unknown-room", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","phone":"555","start_date":"2040-01-01","end_date":"2040-01-02","room_id":3}`,
+		http.StatusUnprocessableEntity, "invalid_room",
+	},
+	{
+		"create-reservation-too-short", "POST", "/api/v1/reservations",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","phone":"555","start_date":"2040-01-01","end_date":"2040-01-02","room_id":4}`,
+		http.StatusUnprocessableEntity, "too_short",
+	},
+	{"create-reservation-unknown-field", "POST", "/api/v1/reservations", `{"fish":1}`, http.StatusBadRequest, "invalid_json"},
+	{"create-reservation-bad-json", "POST", "/api/v1/reservations", `{`, http.StatusBadRequest, "invalid_json"},
+	{"get-reservation", "GET", "/api/v1/reservations/1", "", http.StatusOK, ""},
+	{"get-reservation-not-found", "GET", "/api/v1/reservations/101", "", http.StatusNotFound, "not_found"},
+	{"get-reservation-bad-id", "GET", "/api/v1/reservations/fish", "", http.StatusBadRequest, "invalid_id"},
+	{
+		"update-reservation", "PUT", "/api/v1/reservations/1",
+		`{"first_name":"John","last_name":"Smith","email":"john@smith.com","phone":"555"}`,
+		http.StatusOK, "",
+	},
+	{
+		"update-reservation-missing-name", "PUT", "/api/v1/reservations/1",
+		`{"first_name":"","last_name":"Smith","email":"john@smith.com","phone":"555"}`,
+		http.StatusUnprocessableEntity, "validation_failed",
+	},
+	{"cancel-reservation", "POST", "/api/v1/reservations/1/cancel", "", http.StatusOK, ""},
+	{"cancel-reservation-not-found", "POST", "/api/v1/reservations/101/cancel", "", http.StatusNotFound, "not_found"},
+	{"cancel-reservation-checked-in", "POST", "/api/v1/reservations/8/cancel", "", http.StatusConflict, "checked_in"},
+	{"cancel-reservation-checked-out", "POST", "/api/v1/reservations/9/cancel", "", http.StatusConflict, "checked_in"},
+}
+
+// TestAPI tests the json api handlers and their error envelopes
+func TestAPI(t *testing.T) {
+	routes := getRoutes()
+
+	for _, e := range apiTests {
+		req := httptest.NewRequest(e.method, e.url, strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedStatusCode)
+		}
+
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s returned content type %q", e.name, ct)
+		}
+
+		if e.expectedErrorCode != "" {
+			var resp helpers.APIErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Errorf("%s: failed to parse json: %v", e.name, err)
+				continue
+			}
+
+			if resp.Error.Code != e.expectedErrorCode {
+				t.Errorf("%s: expected error code %s but got %s", e.name, e.expectedErrorCode, resp.Error.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// shows the api keys and the form to create one
+func (m *Repository) AdminAPIKeys(w http.ResponseWriter, req *http.Request) {
+	keys, err := m.DB.AllAPIKeys()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["api_keys"] = keys
+	data["scopes"] = models.APIScopes
+
+	//the plain key is only ever shown once, right after it is created
+	stringMap := make(map[string]string)
+	stringMap["new_key"] = m.App.Session.PopString(req.Context(), "new_api_key")
+
+	render.Template(w, req, "admin-api-keys.page.html", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		Form:      forms.New(nil),
+	})
+}
+
+// creates an api key with the posted name and scopes
+func (m *Repository) AdminPostAPIKey(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	form.Required("name")
+
+	var scopes []string
+	for _, s := range models.APIScopes {
+		if form.Has("scope_"+s, req) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	if len(scopes) == 0 {
+		form.Errors.Add("scopes", "Pick at least one scope")
+	}
+
+	if !form.Valid() {
+		keys, err := m.DB.AllAPIKeys()
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		data := make(map[string]interface{})
+		data["api_keys"] = keys
+		data["scopes"] = models.APIScopes
+
+		render.Template(w, req, "admin-api-keys.page.html", &models.TemplateData{
+			Data: data,
+			Form: form,
+		})
+		return
+	}
+
+	key, prefix, hash, err := helpers.GenerateAPIKey()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	id, err := m.DB.InsertAPIKey(models.APIKey{
+		Name:   form.Get("name"),
+		Prefix: prefix,
+		Hash:   hash,
+		Scopes: scopes,
+		UserID: m.App.Session.GetInt(req.Context(), "user_id"),
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	//never the hash, which is as good as the key for looking it up
+	m.audit(req, models.AuditCreate, models.EntityAPIKey, id, nil, map[string]interface{}{
+		"Name":   form.Get("name"),
+		"Prefix": prefix,
+		"Scopes": scopes,
+	})
+
+	m.App.Session.Put(req.Context(), "new_api_key", key)
+	m.App.Session.Put(req.Context(), "flash", "API key created")
+	http.Redirect(w, req, "/admin/api-keys", http.StatusSeeOther)
+}
+
+// revokes an api key, it stops working immediately
+func (m *Repository) AdminRevokeAPIKey(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	key, err := m.DB.GetAPIKeyByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = m.DB.RevokeAPIKey(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditRevoke, models.EntityAPIKey, id, map[string]interface{}{
+		"Name":   key.Name,
+		"Prefix": key.Prefix,
+		"Scopes": key.Scopes,
+	}, nil)
+
+	m.App.Session.Put(req.Context(), "flash", "API key revoked")
+	http.Redirect(w, req, "/admin/api-keys", http.StatusSeeOther)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+)
+
+// auditActions and auditEntities are offered as filters on the audit log
+var auditActions = []string{
+	models.AuditCreate, models.AuditUpdate, models.AuditDelete, models.AuditProcess, models.AuditMove,
+	models.AuditImport, models.AuditCheckIn, models.AuditCheckOut, models.AuditRevoke, models.AuditSync,
+	models.AuditNewToken,
+}
+
+var auditEntities = []string{
+	models.EntityReservation, models.EntityBlock, models.EntityAPIKey, models.EntityICalFeed, models.EntityRoom,
+	models.EntityUser, models.EntityCharge, models.EntityPromoCode, models.EntityNote,
+}
+
+// auditRow is an audit entry as the audit log shows it, with the fields it changed picked out
+type auditRow struct {
+	Entry   models.AuditEntry
+	Link    string
+	Changes []auditChange
+}
+
+// auditChange is a field an audited change set or changed, with its values as json
+type auditChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// audit records who did what to which thing, from where, with the thing as it was before and after.
+// Either can be nil. The change has already been made, so failing to record it is only logged
+func (m *Repository) audit(req *http.Request, action, entity string, id int, before, after interface{}) {
+	e := models.AuditEntry{
+		UserID:   m.App.Session.GetInt(req.Context(), "user_id"),
+		Action:   action,
+		Entity:   entity,
+		EntityID: id,
+		Before:   auditJSON(before),
+		After:    auditJSON(after),
+		IP:       clientIP(req),
+	}
+
+	err := m.DB.InsertAuditEntry(e)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+}
+
+// auditJSON is v as json, or empty for nil
+func auditJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// clientIP is the address a request came from, without the port
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// AdminAuditLog shows the changes made from the admin pages, newest first, filtered by who made them,
+// what they did, what to and when
+func (m *Repository) AdminAuditLog(w http.ResponseWriter, req *http.Request) {
+	form := forms.New(req.URL.Query())
+	filter := models.AuditFilter{
+		Action: form.Get("action"),
+		Entity: form.Get("entity"),
+		From:   form.OptionalDate("from"),
+		To:     form.OptionalDate("to"),
+	}
+	filter.UserID = form.OptionalCount("user_id")
+	filter.EntityID = form.OptionalCount("entity_id")
+
+	//the end date is taken as the whole of that day
+	if !filter.To.IsZero() {
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		form.Errors.Add("to", "The end can't be before the start")
+		filter.To = filter.From.AddDate(0, 0, 1)
+	}
+
+	number, perPage := pageFromForm(form)
+	filter.Limit = perPage
+	filter.Offset = (number - 1) * perPage
+
+	entries, total, err := m.DB.SearchAuditLog(filter)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	users, err := m.DB.AuditLogUsers()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rows := make([]auditRow, len(entries))
+	for i, e := range entries {
+		rows[i] = auditRow{Entry: e, Link: auditLink(e), Changes: auditChanges(e.Before, e.After)}
+	}
+
+	data := make(map[string]interface{})
+	data["rows"] = rows
+	data["users"] = users
+	data["actions"] = auditActions
+	data["entities"] = auditEntities
+	data["page"] = buildListPage(req.URL, number, perPage, len(entries), total)
+
+	render.Template(w, req, "admin-audit-log.page.html", &models.TemplateData{
+		Form: form,
+		Data: data,
+	})
+}
+
+// auditLink is the admin page for the thing an audit entry changed, if it has one
+func auditLink(e models.AuditEntry) string {
+	if e.EntityID == 0 {
+		return ""
+	}
+
+	switch e.Entity {
+	case models.EntityReservation:
+		return fmt.Sprintf("/admin/reservations/all/%d/show", e.EntityID)
+	case models.EntityBlock:
+		if e.Action != models.AuditDelete {
+			return fmt.Sprintf("/admin/blocks/%d", e.EntityID)
+		}
+	}
+	return ""
+}
+
+// auditChanges picks out the fields that differ between the before and after json of an audit entry.
+// Fields that are empty on both sides are left out, so a new or deleted thing lists only what it had
+func auditChanges(before, after string) []auditChange {
+	var b, a map[string]json.RawMessage
+	_ = json.Unmarshal([]byte(before), &b)
+	_ = json.Unmarshal([]byte(after), &a)
+
+	fields := make(map[string]bool)
+	for f := range b {
+		fields[f] = true
+	}
+	for f := range a {
+		fields[f] = true
+	}
+
+	var changes []auditChange
+	for f := range fields {
+		bv, av := b[f], a[f]
+		if bytes.Equal(bv, av) || (emptyJSON(bv) && emptyJSON(av)) {
+			continue
+		}
+		changes = append(changes, auditChange{Field: f, Before: showJSON(bv), After: showJSON(av)})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// emptyJSON reports whether a json value is missing or holds nothing but zero values
+func emptyJSON(raw json.RawMessage) bool {
+	if len(raw) == 0 {
+		return true
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return false
+	}
+	return emptyValue(v)
+}
+
+func emptyValue(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !x
+	case float64:
+		return x == 0
+	case string:
+		return x == "" || x == "0001-01-01T00:00:00Z"
+	case []interface{}:
+		return len(x) == 0
+	case map[string]interface{}:
+		for _, f := range x {
+			if !emptyValue(f) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// showJSON is a json value fit to show, with strings unquoted
+func showJSON(raw json.RawMessage) string {
+	if emptyJSON(raw) {
+		return ""
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	var out bytes.Buffer
+	if json.Compact(&out, raw) != nil {
+		return string(raw)
+	}
+	return out.String()
+}
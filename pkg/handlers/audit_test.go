@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"BookingProject/pkg/models"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAuditChanges(t *testing.T) {
+	var tests = []struct {
+		name     string
+		before   string
+		after    string
+		expected []auditChange
+	}{
+		{"update", `{"FirstName":"Jon","LastName":"Smith","Phone":""}`, `{"FirstName":"John","LastName":"Smith","Phone":"555"}`,
+			[]auditChange{{"FirstName", "Jon", "John"}, {"Phone", "", "555"}}},
+		{"create", "", `{"ID":3,"Reason":"Maintenance","ExpiresAt":"0001-01-01T00:00:00Z","Room":{"ID":0,"RoomName":""}}`,
+			[]auditChange{{"ID", "", "3"}, {"Reason", "", "Maintenance"}}},
+		{"delete", `{"Code":"SPRING","Room":{"ID":1,"RoomName":"Suite"}}`, "",
+			[]auditChange{{"Code", "SPRING", ""}, {"Room", `{"ID":1,"RoomName":"Suite"}`, ""}}},
+		{"nothing", "", "", nil},
+	}
+
+	for _, e := range tests {
+		got := auditChanges(e.before, e.after)
+		if !reflect.DeepEqual(got, e.expected) {
+			t.Errorf("%s: expected %v, got %v", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestAuditJSON(t *testing.T) {
+	if got := auditJSON(nil); got != "" {
+		t.Errorf("expected nothing for nil, got %s", got)
+	}
+	if got := auditJSON(models.Room{ID: 1, RoomName: "Suite"}); got == "" {
+		t.Error("expected json for a room")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/admin/dashboard", nil)
+	req.RemoteAddr = "10.0.0.1:5123"
+	if got := clientIP(req); got != "10.0.0.1" {
+		t.Errorf("expected 10.0.0.1, got %s", got)
+	}
+
+	req.RemoteAddr = "[::1]:5123"
+	if got := clientIP(req); got != "::1" {
+		t.Errorf("expected ::1, got %s", got)
+	}
+}
+
+func TestAuditLink(t *testing.T) {
+	var tests = []struct {
+		entry    models.AuditEntry
+		expected string
+	}{
+		{models.AuditEntry{Action: models.AuditUpdate, Entity: models.EntityReservation, EntityID: 4}, "/admin/reservations/all/4/show"},
+		{models.AuditEntry{Action: models.AuditUpdate, Entity: models.EntityBlock, EntityID: 2}, "/admin/blocks/2"},
+		{models.AuditEntry{Action: models.AuditDelete, Entity: models.EntityBlock, EntityID: 2}, ""},
+		{models.AuditEntry{Action: models.AuditImport, Entity: models.EntityReservation}, ""},
+	}
+
+	for _, e := range tests {
+		if got := auditLink(e.entry); got != e.expected {
+			t.Errorf("%+v: expected %q, got %q", e.entry, e.expected, got)
+		}
+	}
+}
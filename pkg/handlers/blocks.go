@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// blockReasons are offered when blocking a room, though any reason can be typed in
+var blockReasons = []string{"Maintenance", "Owner stay", "Renovation"}
+
+// AdminBlocks shows the blocks that haven't finished yet and the form to add one. The room and dates
+// can be filled in from the query
+func (m *Repository) AdminBlocks(w http.ResponseWriter, req *http.Request) {
+	m.renderAdminBlocks(w, req, forms.New(req.URL.Query()))
+}
+
+func (m *Repository) renderAdminBlocks(w http.ResponseWriter, req *http.Request, form *forms.Form) {
+	blocks, err := m.DB.UpcomingBlocks()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["blocks"] = blocks
+	data["rooms"] = rooms
+	data["reasons"] = blockReasons
+
+	render.Template(w, req, "admin-blocks.page.html", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminPostBlock blocks a room over a range of dates
+func (m *Repository) AdminPostBlock(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	block := m.blockFromForm(form)
+
+	if !form.Valid() {
+		m.renderAdminBlocks(w, req, form)
+		return
+	}
+
+	block.ID, err = m.DB.InsertBlock(block)
+	if errors.Is(err, sql.ErrNoRows) {
+		form.Errors.Add("start", "The room is already taken for some of those nights")
+		m.renderAdminBlocks(w, req, form)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditCreate, models.EntityBlock, block.ID, nil, block)
+
+	m.App.Session.Put(req.Context(), "flash", "Room blocked")
+	http.Redirect(w, req, "/admin/blocks", http.StatusSeeOther)
+}
+
+// AdminShowBlock shows the form to change a block
+func (m *Repository) AdminShowBlock(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	block, err := m.DB.GetBlockByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(url.Values{
+		"room_id": {strconv.Itoa(block.RoomID)},
+		"start":   {block.StartDate.Format("2006-01-02")},
+		"end":     {block.EndDate.Format("2006-01-02")},
+		"reason":  {block.Reason},
+	})
+
+	m.renderAdminBlock(w, req, block, form)
+}
+
+func (m *Repository) renderAdminBlock(w http.ResponseWriter, req *http.Request, block models.RoomRestriction, form *forms.Form) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["block"] = block
+	data["rooms"] = rooms
+	data["reasons"] = blockReasons
+
+	render.Template(w, req, "admin-block.page.html", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminPostUpdateBlock moves a block to other dates or another room, or changes its reason
+func (m *Repository) AdminPostUpdateBlock(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	block, err := m.DB.GetBlockByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	changed := m.blockFromForm(form)
+	changed.ID = block.ID
+
+	if !form.Valid() {
+		m.renderAdminBlock(w, req, block, form)
+		return
+	}
+
+	updated, err := m.DB.UpdateBlock(changed)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !updated {
+		form.Errors.Add("start", "The room is already taken for some of those nights")
+		m.renderAdminBlock(w, req, block, form)
+		return
+	}
+	m.audit(req, models.AuditUpdate, models.EntityBlock, block.ID, block, changed)
+
+	//nights the block no longer covers may be what someone on the waitlist is after
+	if changed.RoomID != block.RoomID || changed.StartDate.After(block.StartDate) || changed.EndDate.Before(block.EndDate) {
+		m.NotifyWaitlist()
+	}
+
+	m.App.Session.Put(req.Context(), "flash", "Block saved")
+	http.Redirect(w, req, "/admin/blocks", http.StatusSeeOther)
+}
+
+// blockFromForm reads the room, dates and reason of a block, adding any errors to the form
+func (m *Repository) blockFromForm(form *forms.Form) models.RoomRestriction {
+	form.Required("room_id", "start", "end", "reason")
+
+	block := models.RoomRestriction{
+		RestrictionID: models.RestrictionOwnerBlock,
+		StartDate:     form.OptionalDate("start"),
+		EndDate:       form.OptionalDate("end"),
+		Reason:        form.Get("reason"),
+	}
+
+	if !block.StartDate.IsZero() && !block.EndDate.IsZero() && !block.EndDate.After(block.StartDate) {
+		form.Errors.Add("end", "The end has to be after the start")
+	}
+
+	if len(block.Reason) > 255 {
+		form.Errors.Add("reason", "Keep the reason under 255 characters")
+	}
+
+	var err error
+	block.RoomID, err = strconv.Atoi(form.Get("room_id"))
+	if err == nil {
+		_, err = m.DB.GetRoomByID(block.RoomID)
+	}
+	if err != nil && form.Get("room_id") != "" {
+		form.Errors.Add("room_id", "Choose a room")
+	}
+
+	return block
+}
+
+// AdminDeleteBlock removes a block, so the room can be booked for those nights again
+func (m *Repository) AdminDeleteBlock(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	before, err := m.DB.GetBlockByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = m.DB.DeleteBlockByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditDelete, models.EntityBlock, id, before, nil)
+	m.NotifyWaitlist()
+
+	m.App.Session.Put(req.Context(), "flash", "Block removed")
+	http.Redirect(w, req, "/admin/blocks", http.StatusSeeOther)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var blockTests = []struct {
+	name               string
+	postedData         url.Values
+	expectedStatusCode int
+}{
+	{"valid block", url.Values{"room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2050-01-15"}, "reason": {"Renovation"}}, http.StatusSeeOther},
+	{"missing reason", url.Values{"room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2050-01-15"}}, http.StatusOK},
+	{"missing room", url.Values{"start": {"2050-01-01"}, "end": {"2050-01-15"}, "reason": {"Maintenance"}}, http.StatusOK},
+	{"unknown room", url.Values{"room_id": {"3"}, "start": {"2050-01-01"}, "end": {"2050-01-15"}, "reason": {"Maintenance"}}, http.StatusOK},
+	{"dates reversed", url.Values{"room_id": {"1"}, "start": {"2050-01-15"}, "end": {"2050-01-01"}, "reason": {"Maintenance"}}, http.StatusOK},
+	{"no nights", url.Values{"room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2050-01-01"}, "reason": {"Maintenance"}}, http.StatusOK},
+	{"bad date", url.Values{"room_id": {"1"}, "start": {"01/01/2050"}, "end": {"2050-01-15"}, "reason": {"Maintenance"}}, http.StatusOK},
+	{"room taken", url.Values{"room_id": {"1"}, "start": {"2070-01-01"}, "end": {"2070-01-15"}, "reason": {"Owner stay"}}, http.StatusOK},
+	{"database error", url.Values{"room_id": {"1"}, "start": {"2060-01-01"}, "end": {"2060-01-15"}, "reason": {"Owner stay"}}, http.StatusInternalServerError},
+}
+
+func TestAdminPostBlock(t *testing.T) {
+	for _, e := range blockTests {
+		req, _ := http.NewRequest("POST", "/admin/blocks", strings.NewReader(e.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostBlock)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedStatusCode)
+		}
+	}
+}
+
+func TestAdminPostUpdateBlock(t *testing.T) {
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for _, e := range blockTests {
+		resp, err := client.PostForm(ts.URL+"/admin/blocks/1", e.postedData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedStatusCode)
+		}
+	}
+
+	resp, err := client.PostForm(ts.URL+"/admin/blocks/101", blockTests[0].postedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing block returned wrong response code: got %d, wanted %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// the longest date range the bulk block tool works over at once
+const maxBulkBlockDays = 366
+
+// bulkWeekdays are the days a bulk block can be limited to, in the order they're shown
+var bulkWeekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+// bulkBlockPlan is what the bulk block tool will do. Blocks are the blocks to add or remove, and
+// Conflicts what's in the way: the reservations and bookings new blocks go around, or the blocks only
+// partly covered by the nights chosen, which are left alone
+type bulkBlockPlan struct {
+	Nights    int
+	Blocks    []models.RoomRestriction
+	Conflicts []models.RoomRestriction
+}
+
+// AdminBulkBlocks shows the form to block or unblock several rooms over a range of dates at once
+func (m *Repository) AdminBulkBlocks(w http.ResponseWriter, req *http.Request) {
+	m.renderAdminBulkBlocks(w, req, forms.New(req.URL.Query()), nil)
+}
+
+func (m *Repository) renderAdminBulkBlocks(w http.ResponseWriter, req *http.Request, form *forms.Form, plan *bulkBlockPlan) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	roomNames := make(map[int]string)
+	for _, r := range rooms {
+		roomNames[r.ID] = r.RoomName
+	}
+
+	selectedRooms := make(map[string]bool)
+	for _, id := range form.Values["room_id"] {
+		selectedRooms[id] = true
+	}
+
+	selectedDays := make(map[string]bool)
+	for _, d := range form.Values["weekday"] {
+		selectedDays[d] = true
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+	data["room_names"] = roomNames
+	data["selected_rooms"] = selectedRooms
+	data["weekdays"] = bulkWeekdays
+	data["selected_days"] = selectedDays
+	data["reasons"] = blockReasons
+	data["owner_block"] = models.RestrictionOwnerBlock
+	if plan != nil {
+		data["plan"] = plan
+	}
+
+	render.Template(w, req, "admin-blocks-bulk.page.html", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminPostBulkBlocks previews blocking or unblocking rooms over a range of dates, on every night or
+// only on some days of the week, and carries it out once step is apply
+func (m *Repository) AdminPostBulkBlocks(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	form.Required("start", "end")
+
+	mode := form.Get("mode")
+	if mode != "add" && mode != "remove" {
+		form.Errors.Add("mode", "Choose whether to block or unblock")
+	}
+	if mode == "add" {
+		form.Required("reason")
+		if len(form.Get("reason")) > 255 {
+			form.Errors.Add("reason", "Keep the reason under 255 characters")
+		}
+	}
+
+	start := form.OptionalDate("start")
+	end := form.OptionalDate("end")
+	if !start.IsZero() && !end.IsZero() {
+		if !end.After(start) {
+			form.Errors.Add("end", "The end has to be after the start")
+		} else if end.After(start.AddDate(0, 0, maxBulkBlockDays)) {
+			form.Errors.Add("end", fmt.Sprintf("Choose no more than %d days at once", maxBulkBlockDays))
+		}
+	}
+
+	var roomIDs []int
+	for _, v := range form.Values["room_id"] {
+		id, err := strconv.Atoi(v)
+		if err == nil {
+			_, err = m.DB.GetRoomByID(id)
+		}
+		if err != nil {
+			form.Errors.Add("room_id", "Choose rooms from the list")
+			break
+		}
+		roomIDs = append(roomIDs, id)
+	}
+	if len(form.Values["room_id"]) == 0 {
+		form.Errors.Add("room_id", "Choose at least one room")
+	}
+
+	var weekdays []time.Weekday
+	for _, v := range form.Values["weekday"] {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 0 || d > 6 {
+			form.Errors.Add("weekday", "Choose days from the list")
+			break
+		}
+		weekdays = append(weekdays, time.Weekday(d))
+	}
+
+	var nights []time.Time
+	if form.Valid() {
+		nights = bulkNights(start, end, weekdays)
+		if len(nights) == 0 {
+			form.Errors.Add("weekday", "None of those dates fall on the days chosen")
+		}
+	}
+
+	if !form.Valid() {
+		m.renderAdminBulkBlocks(w, req, form, nil)
+		return
+	}
+
+	restrictions, err := m.DB.GetRestrictionsForCalendar(start, end)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	plan := bulkBlockPlan{Nights: len(nights)}
+	if mode == "add" {
+		plan.Blocks, plan.Conflicts = planBulkBlocks(roomIDs, nights, restrictions, form.Get("reason"))
+	} else {
+		plan.Blocks, plan.Conflicts = planBulkUnblocks(roomIDs, nights, restrictions)
+	}
+
+	if form.Get("step") != "apply" {
+		m.renderAdminBulkBlocks(w, req, form, &plan)
+		return
+	}
+
+	done, skipped := 0, 0
+	for _, b := range plan.Blocks {
+		if mode == "add" {
+			b.ID, err = m.DB.InsertBlock(b)
+			//booked since the preview
+			if errors.Is(err, sql.ErrNoRows) {
+				skipped++
+				continue
+			}
+		} else {
+			err = m.DB.DeleteBlockByID(b.ID)
+			//removed since the preview
+			if errors.Is(err, sql.ErrNoRows) {
+				skipped++
+				continue
+			}
+		}
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		if mode == "add" {
+			m.audit(req, models.AuditCreate, models.EntityBlock, b.ID, nil, b)
+		} else {
+			m.audit(req, models.AuditDelete, models.EntityBlock, b.ID, b, nil)
+		}
+		done++
+	}
+
+	if mode == "add" {
+		msg := fmt.Sprintf("%d blocks added", done)
+		if skipped > 0 {
+			msg += fmt.Sprintf(", %d skipped as the room was booked in the meantime", skipped)
+		}
+		m.App.Session.Put(req.Context(), "flash", msg)
+	} else {
+		if done > 0 {
+			m.NotifyWaitlist()
+		}
+		msg := fmt.Sprintf("%d blocks removed", done)
+		if skipped > 0 {
+			msg += fmt.Sprintf(", %d had been removed already", skipped)
+		}
+		m.App.Session.Put(req.Context(), "flash", msg)
+	}
+
+	http.Redirect(w, req, "/admin/blocks", http.StatusSeeOther)
+}
+
+// bulkNights lists the nights from start up to end that fall on one of the weekdays, or all of them
+// when no weekdays are given
+func bulkNights(start, end time.Time, weekdays []time.Weekday) []time.Time {
+	var nights []time.Time
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if len(weekdays) == 0 {
+			nights = append(nights, d)
+			continue
+		}
+		for _, wd := range weekdays {
+			if d.Weekday() == wd {
+				nights = append(nights, d)
+				break
+			}
+		}
+	}
+	return nights
+}
+
+// planBulkBlocks works out the blocks that cover the nights on each room, one per run of consecutive
+// nights. Nights something else already has are left out, and that something is a conflict
+func planBulkBlocks(roomIDs []int, nights []time.Time, restrictions []models.RoomRestriction, reason string) ([]models.RoomRestriction, []models.RoomRestriction) {
+	var blocks, conflicts []models.RoomRestriction
+	seen := make(map[int]bool)
+
+	for _, roomID := range roomIDs {
+		for _, d := range nights {
+			next := d.AddDate(0, 0, 1)
+
+			taken := -1
+			for i, r := range restrictions {
+				if r.RoomID == roomID && d.Before(r.EndDate) && next.After(r.StartDate) {
+					taken = i
+					break
+				}
+			}
+			if taken >= 0 {
+				if !seen[taken] {
+					seen[taken] = true
+					conflicts = append(conflicts, restrictions[taken])
+				}
+				continue
+			}
+
+			//carry on the block for the night before
+			if last := len(blocks) - 1; last >= 0 && blocks[last].RoomID == roomID && blocks[last].EndDate.Equal(d) {
+				blocks[last].EndDate = next
+				continue
+			}
+
+			blocks = append(blocks, models.RoomRestriction{
+				RoomID:        roomID,
+				RestrictionID: models.RestrictionOwnerBlock,
+				StartDate:     d,
+				EndDate:       next,
+				Reason:        reason,
+			})
+		}
+	}
+
+	return blocks, conflicts
+}
+
+// planBulkUnblocks finds the owner blocks on the rooms that every night of is one of the nights. Blocks
+// that are only partly on those nights are conflicts, and stay
+func planBulkUnblocks(roomIDs []int, nights []time.Time, restrictions []models.RoomRestriction) ([]models.RoomRestriction, []models.RoomRestriction) {
+	var blocks, partial []models.RoomRestriction
+
+	chosen := make(map[string]bool)
+	for _, d := range nights {
+		chosen[d.Format("2006-01-02")] = true
+	}
+
+	rooms := make(map[int]bool)
+	for _, id := range roomIDs {
+		rooms[id] = true
+	}
+
+	for _, r := range restrictions {
+		if !rooms[r.RoomID] || r.RestrictionID != models.RestrictionOwnerBlock {
+			continue
+		}
+
+		all, some := true, false
+		for d := r.StartDate; d.Before(r.EndDate); d = d.AddDate(0, 0, 1) {
+			if chosen[d.Format("2006-01-02")] {
+				some = true
+			} else {
+				all = false
+			}
+		}
+
+		if all && some {
+			blocks = append(blocks, r)
+		} else if some {
+			partial = append(partial, r)
+		}
+	}
+
+	return blocks, partial
+}
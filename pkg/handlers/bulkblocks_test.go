@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"BookingProject/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBulkNights(t *testing.T) {
+	// 2050-01-03 is a Monday
+	start := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2050, 1, 22, 0, 0, 0, 0, time.UTC)
+
+	if n := len(bulkNights(start, end, nil)); n != 21 {
+		t.Errorf("expected every one of 21 nights, got %d", n)
+	}
+
+	mondays := bulkNights(start, end, []time.Weekday{time.Monday})
+	if len(mondays) != 3 || mondays[0].Day() != 3 || mondays[2].Day() != 17 {
+		t.Errorf("unexpected mondays %v", mondays)
+	}
+}
+
+func TestPlanBulkBlocks(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2050, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	var nights []time.Time
+	for d := 1; d <= 7; d++ {
+		nights = append(nights, day(d))
+	}
+
+	restrictions := []models.RoomRestriction{
+		{ID: 1, RoomID: 1, ReservationID: 5, RestrictionID: models.RestrictionReservation, StartDate: day(3), EndDate: day(5)},
+		{ID: 2, RoomID: 3, ReservationID: 6, RestrictionID: models.RestrictionReservation, StartDate: day(3), EndDate: day(5)},
+	}
+
+	blocks, conflicts := planBulkBlocks([]int{1, 2}, nights, restrictions, "Holiday")
+
+	// room 1 goes around its reservation, room 2 is blocked all week
+	expected := []models.RoomRestriction{
+		{RoomID: 1, StartDate: day(1), EndDate: day(3)},
+		{RoomID: 1, StartDate: day(5), EndDate: day(8)},
+		{RoomID: 2, StartDate: day(1), EndDate: day(8)},
+	}
+	if len(blocks) != len(expected) {
+		t.Fatalf("expected %d blocks, got %v", len(expected), blocks)
+	}
+	for i, e := range expected {
+		b := blocks[i]
+		if b.RoomID != e.RoomID || !b.StartDate.Equal(e.StartDate) || !b.EndDate.Equal(e.EndDate) {
+			t.Errorf("block %d: expected room %d %s to %s, got room %d %s to %s", i, e.RoomID, e.StartDate, e.EndDate, b.RoomID, b.StartDate, b.EndDate)
+		}
+		if b.Reason != "Holiday" || b.RestrictionID != models.RestrictionOwnerBlock {
+			t.Errorf("block %d: unexpected reason %q or restriction %d", i, b.Reason, b.RestrictionID)
+		}
+	}
+
+	if len(conflicts) != 1 || conflicts[0].ID != 1 {
+		t.Errorf("expected the reservation on room 1 to conflict once, got %v", conflicts)
+	}
+}
+
+func TestPlanBulkUnblocks(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2050, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	// just the mondays
+	nights := []time.Time{day(3), day(10)}
+
+	restrictions := []models.RoomRestriction{
+		{ID: 1, RoomID: 1, RestrictionID: models.RestrictionOwnerBlock, StartDate: day(3), EndDate: day(4)},
+		{ID: 2, RoomID: 1, RestrictionID: models.RestrictionOwnerBlock, StartDate: day(9), EndDate: day(12)},
+		{ID: 3, RoomID: 1, ReservationID: 5, RestrictionID: models.RestrictionReservation, StartDate: day(10), EndDate: day(11)},
+		{ID: 4, RoomID: 2, RestrictionID: models.RestrictionOwnerBlock, StartDate: day(3), EndDate: day(4)},
+		{ID: 5, RoomID: 1, RestrictionID: models.RestrictionOwnerBlock, StartDate: day(5), EndDate: day(6)},
+	}
+
+	blocks, partial := planBulkUnblocks([]int{1}, nights, restrictions)
+
+	if len(blocks) != 1 || blocks[0].ID != 1 {
+		t.Errorf("expected only block 1 to be removed, got %v", blocks)
+	}
+	if len(partial) != 1 || partial[0].ID != 2 {
+		t.Errorf("expected block 2 to be left as only partly chosen, got %v", partial)
+	}
+}
+
+func TestAdminPostBulkBlocks(t *testing.T) {
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+	}{
+		{"preview", url.Values{"mode": {"add"}, "room_id": {"1", "2"}, "start": {"2050-01-01"}, "end": {"2050-02-01"}, "weekday": {"1"}, "reason": {"Cleaning"}}, http.StatusOK},
+		{"add", url.Values{"mode": {"add"}, "room_id": {"1", "2"}, "start": {"2050-01-01"}, "end": {"2050-02-01"}, "weekday": {"1"}, "reason": {"Cleaning"}, "step": {"apply"}}, http.StatusSeeOther},
+		{"add when booked meanwhile", url.Values{"mode": {"add"}, "room_id": {"1"}, "start": {"2070-01-01"}, "end": {"2070-01-08"}, "reason": {"Holiday"}, "step": {"apply"}}, http.StatusSeeOther},
+		{"remove", url.Values{"mode": {"remove"}, "room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2050-01-08"}, "step": {"apply"}}, http.StatusSeeOther},
+		{"no mode", url.Values{"room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2050-01-08"}, "reason": {"Holiday"}}, http.StatusOK},
+		{"no reason to block", url.Values{"mode": {"add"}, "room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2050-01-08"}}, http.StatusOK},
+		{"no rooms", url.Values{"mode": {"add"}, "start": {"2050-01-01"}, "end": {"2050-01-08"}, "reason": {"Holiday"}}, http.StatusOK},
+		{"unknown room", url.Values{"mode": {"add"}, "room_id": {"3"}, "start": {"2050-01-01"}, "end": {"2050-01-08"}, "reason": {"Holiday"}}, http.StatusOK},
+		{"bad weekday", url.Values{"mode": {"add"}, "room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2050-01-08"}, "weekday": {"7"}, "reason": {"Holiday"}}, http.StatusOK},
+		{"no nights on the weekday", url.Values{"mode": {"add"}, "room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2050-01-02"}, "weekday": {"1"}, "reason": {"Holiday"}}, http.StatusOK},
+		{"dates reversed", url.Values{"mode": {"add"}, "room_id": {"1"}, "start": {"2050-01-08"}, "end": {"2050-01-01"}, "reason": {"Holiday"}}, http.StatusOK},
+		{"too long", url.Values{"mode": {"add"}, "room_id": {"1"}, "start": {"2050-01-01"}, "end": {"2052-01-01"}, "reason": {"Holiday"}}, http.StatusOK},
+		{"database error", url.Values{"mode": {"add"}, "room_id": {"1"}, "start": {"2060-01-01"}, "end": {"2060-01-08"}, "reason": {"Holiday"}, "step": {"apply"}}, http.StatusInternalServerError},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/blocks/bulk", strings.NewReader(e.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostBulkBlocks)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedStatusCode)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/pricing"
+	"BookingProject/pkg/render"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// AdminCharges shows the tax and fee rules and the form to add one
+func (m *Repository) AdminCharges(w http.ResponseWriter, req *http.Request) {
+	m.renderAdminCharges(w, req, forms.New(nil))
+}
+
+func (m *Repository) renderAdminCharges(w http.ResponseWriter, req *http.Request, form *forms.Form) {
+	rules, err := m.DB.AllChargeRules()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rules"] = rules
+	data["rooms"] = rooms
+
+	render.Template(w, req, "admin-charges.page.html", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminPostCharge adds a tax or fee rule. It applies to quotes from then on; reservations
+// already invoiced keep the price they were invoiced at
+func (m *Repository) AdminPostCharge(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	form.Required("name", "amount")
+
+	rule := models.ChargeRule{
+		Name:   form.Get("name"),
+		Kind:   form.Get("kind"),
+		Method: form.Get("method"),
+		Per:    form.Get("per"),
+	}
+
+	if rule.Kind != models.ChargeFee && rule.Kind != models.ChargeTax {
+		form.Errors.Add("kind", "Choose a tax or a fee")
+	}
+
+	switch rule.Method {
+	case models.ChargePercent:
+		rule.Per = models.ChargePerStay
+	case models.ChargeFlat:
+		if rule.Per != models.ChargePerNight && rule.Per != models.ChargePerStay {
+			form.Errors.Add("per", "Choose per night or per stay")
+		}
+	default:
+		form.Errors.Add("method", "Choose a percentage or a flat amount")
+	}
+
+	if form.Get("amount") != "" {
+		rule.Amount, err = pricing.ParseAmount(form.Get("amount"))
+		if err != nil {
+			form.Errors.Add("amount", "Enter an amount like 12.50")
+		} else if rule.Method == models.ChargePercent && rule.Amount > 10000 {
+			form.Errors.Add("amount", "A percentage can't be more than 100")
+		}
+	}
+
+	rule.RoomID, err = strconv.Atoi(form.Get("room_id"))
+	if err != nil {
+		form.Errors.Add("room_id", "Choose a room")
+	} else if rule.RoomID != 0 {
+		_, err = m.DB.GetRoomByID(rule.RoomID)
+		if err != nil {
+			form.Errors.Add("room_id", "Choose a room")
+		}
+	}
+
+	if !form.Valid() {
+		m.renderAdminCharges(w, req, form)
+		return
+	}
+
+	rule.ID, err = m.DB.InsertChargeRule(rule)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditCreate, models.EntityCharge, rule.ID, nil, rule)
+
+	m.App.Session.Put(req.Context(), "flash", rule.Name+" added")
+	http.Redirect(w, req, "/admin/taxes-fees", http.StatusSeeOther)
+}
+
+// AdminDeleteCharge stops charging a tax or fee
+func (m *Repository) AdminDeleteCharge(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	rules, err := m.DB.AllChargeRules()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	var before interface{}
+	for _, r := range rules {
+		if r.ID == id {
+			before = r
+		}
+	}
+
+	err = m.DB.DeleteChargeRule(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditDelete, models.EntityCharge, id, before, nil)
+
+	m.App.Session.Put(req.Context(), "flash", "Charge deleted")
+	http.Redirect(w, req, "/admin/taxes-fees", http.StatusSeeOther)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminPostCharge(t *testing.T) {
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+	}{
+		{"percentage tax", url.Values{"name": {"VAT"}, "kind": {"tax"}, "method": {"percent"}, "amount": {"12.5"}, "room_id": {"0"}}, http.StatusSeeOther},
+		{"flat fee per night", url.Values{"name": {"Resort fee"}, "kind": {"fee"}, "method": {"flat"}, "per": {"night"}, "amount": {"5"}, "room_id": {"1"}}, http.StatusSeeOther},
+		{"missing name", url.Values{"kind": {"tax"}, "method": {"percent"}, "amount": {"10"}, "room_id": {"0"}}, http.StatusOK},
+		{"bad amount", url.Values{"name": {"VAT"}, "kind": {"tax"}, "method": {"percent"}, "amount": {"ten"}, "room_id": {"0"}}, http.StatusOK},
+		{"over 100 percent", url.Values{"name": {"VAT"}, "kind": {"tax"}, "method": {"percent"}, "amount": {"150"}, "room_id": {"0"}}, http.StatusOK},
+		{"flat without per", url.Values{"name": {"Cleaning"}, "kind": {"fee"}, "method": {"flat"}, "amount": {"25"}, "room_id": {"0"}}, http.StatusOK},
+		{"unknown kind", url.Values{"name": {"Cleaning"}, "kind": {"other"}, "method": {"flat"}, "per": {"stay"}, "amount": {"25"}, "room_id": {"0"}}, http.StatusOK},
+		{"unknown room", url.Values{"name": {"Cleaning"}, "kind": {"fee"}, "method": {"flat"}, "per": {"stay"}, "amount": {"25"}, "room_id": {"3"}}, http.StatusOK},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/taxes-fees", strings.NewReader(e.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostCharge)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedStatusCode)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"net/http"
+	"time"
+)
+
+// the longest range of dates the dashboard reports on at once
+const maxDashboardDays = 731
+
+// dashboardRange is a range of dates the dashboard offers to report on
+type dashboardRange struct {
+	Label string
+	Start string
+	End   string
+}
+
+// AdminDashboard reports on occupancy, stays, revenue and cancellations from start up to end in the
+// query, this month unless asked, along with today's arrivals and departures and any double bookings
+func (m *Repository) AdminDashboard(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	form := forms.New(req.URL.Query())
+	start, end := dashboardDates(form, today)
+
+	occupancy, err := m.DB.RoomOccupancy(start, end)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stats, err := m.DB.StayStats(start, end)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	revenue, err := m.DB.RevenueByMonth(start, end)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	day, err := m.DB.CountDay(today)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	//external bookings that clash with ours need sorting out by hand
+	conflicts, err := m.DB.GetBookingConflicts()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	total := models.RoomOccupancy{Room: models.Room{RoomName: "All rooms"}}
+	for _, o := range occupancy {
+		total.Nights += o.Nights
+		total.Booked += o.Booked
+		total.Blocked += o.Blocked
+	}
+
+	var revenueTotal models.MonthRevenue
+	for _, r := range revenue {
+		revenueTotal.Reservations += r.Reservations
+		revenueTotal.Invoiced += r.Invoiced
+		revenueTotal.Paid += r.Paid
+	}
+
+	cancellationRate := 0
+	if n := stats.Reservations + stats.Cancellations; n > 0 {
+		cancellationRate = stats.Cancellations * 10000 / n
+	}
+
+	data := make(map[string]interface{})
+	data["start"] = start
+	data["end"] = end
+	data["ranges"] = dashboardRanges(today)
+	data["occupancy"] = occupancy
+	data["occupancy_total"] = total
+	data["stats"] = stats
+	data["revenue"] = revenue
+	data["revenue_total"] = revenueTotal
+	data["today"] = day
+	data["conflicts"] = conflicts
+
+	intMap := make(map[string]int)
+	intMap["cancellation_rate"] = cancellationRate
+
+	form.Set("start", start.Format("2006-01-02"))
+	form.Set("end", end.Format("2006-01-02"))
+
+	render.Template(w, req, "admin-dashboard.page.html", &models.TemplateData{
+		Form:   form,
+		Data:   data,
+		IntMap: intMap,
+	})
+}
+
+// dashboardDates reads the range of dates to report on, falling back to the month today is in for
+// any that can't be used
+func dashboardDates(form *forms.Form, today time.Time) (time.Time, time.Time) {
+	start := form.OptionalDate("start")
+	end := form.OptionalDate("end")
+
+	if start.IsZero() || end.IsZero() || !form.Valid() {
+		start = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+
+	if !end.After(start) {
+		form.Errors.Add("end", "The end has to be after the start")
+		return start, start.AddDate(0, 1, 0)
+	}
+
+	if end.After(start.AddDate(0, 0, maxDashboardDays)) {
+		form.Errors.Add("end", "Choose no more than two years at once")
+		end = start.AddDate(0, 0, maxDashboardDays)
+	}
+
+	return start, end
+}
+
+// dashboardRanges are the ranges of dates the dashboard offers as shortcuts
+func dashboardRanges(today time.Time) []dashboardRange {
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	year := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := func(label string, start, end time.Time) dashboardRange {
+		return dashboardRange{label, start.Format("2006-01-02"), end.Format("2006-01-02")}
+	}
+
+	return []dashboardRange{
+		r("This month", month, month.AddDate(0, 1, 0)),
+		r("Last month", month.AddDate(0, -1, 0), month),
+		r("Next month", month.AddDate(0, 1, 0), month.AddDate(0, 2, 0)),
+		r("Last 30 days", today.AddDate(0, 0, -30), today),
+		r("Next 30 days", today, today.AddDate(0, 0, 30)),
+		r("This year", year, year.AddDate(1, 0, 0)),
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/models"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDashboardDates(t *testing.T) {
+	today := time.Date(2050, 3, 15, 0, 0, 0, 0, time.UTC)
+	march := time.Date(2050, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name  string
+		query url.Values
+		start time.Time
+		end   time.Time
+		error bool
+	}{
+		{"default", url.Values{}, march, march.AddDate(0, 1, 0), false},
+		{"range", url.Values{"start": {"2050-01-10"}, "end": {"2050-02-10"}},
+			time.Date(2050, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2050, 2, 10, 0, 0, 0, 0, time.UTC), false},
+		{"bad date", url.Values{"start": {"soon"}, "end": {"2050-02-10"}}, march, march.AddDate(0, 1, 0), true},
+		{"reversed", url.Values{"start": {"2050-02-10"}, "end": {"2050-01-10"}},
+			time.Date(2050, 2, 10, 0, 0, 0, 0, time.UTC), time.Date(2050, 3, 10, 0, 0, 0, 0, time.UTC), true},
+		{"too long", url.Values{"start": {"2050-01-01"}, "end": {"2060-01-01"}},
+			time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, maxDashboardDays), true},
+	}
+
+	for _, e := range tests {
+		form := forms.New(e.query)
+		start, end := dashboardDates(form, today)
+		if !start.Equal(e.start) || !end.Equal(e.end) {
+			t.Errorf("%s: expected %s to %s, got %s to %s", e.name, e.start, e.end, start, end)
+		}
+		if form.Valid() == e.error {
+			t.Errorf("%s: expected an error %v, got %v", e.name, e.error, form.Errors)
+		}
+	}
+}
+
+func TestDashboardRanges(t *testing.T) {
+	ranges := dashboardRanges(time.Date(2050, 1, 20, 0, 0, 0, 0, time.UTC))
+
+	if ranges[0].Start != "2050-01-01" || ranges[0].End != "2050-02-01" {
+		t.Errorf("unexpected this month %+v", ranges[0])
+	}
+	if ranges[1].Start != "2049-12-01" || ranges[1].End != "2050-01-01" {
+		t.Errorf("unexpected last month %+v", ranges[1])
+	}
+}
+
+func TestRoomOccupancyRate(t *testing.T) {
+	var tests = []struct {
+		occupancy models.RoomOccupancy
+		rate      int
+	}{
+		{models.RoomOccupancy{Nights: 30, Booked: 15}, 5000},
+		{models.RoomOccupancy{Nights: 30, Booked: 10, Blocked: 10}, 5000},
+		{models.RoomOccupancy{Nights: 30, Blocked: 30}, 0},
+		{models.RoomOccupancy{}, 0},
+	}
+
+	for _, e := range tests {
+		if rate := e.occupancy.Rate(); rate != e.rate {
+			t.Errorf("%+v: expected %d, got %d", e.occupancy, e.rate, rate)
+		}
+	}
+}
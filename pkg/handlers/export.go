@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/pricing"
+	"BookingProject/pkg/xlsx"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// exportColumns head the columns of a reservations export
+var exportColumns = []interface{}{
+	"ID", "First Name", "Last Name", "Email", "Phone", "Room", "Arrival", "Departure", "Nights", "Status",
+	"Booked By", "Booked On", "Invoiced", "Paid", "Balance", "Currency",
+}
+
+// exportWriter writes an export a row at a time
+type exportWriter interface {
+	Row(cells ...interface{}) error
+	Close() error
+}
+
+// exportContentTypes are the formats reservations export to
+var exportContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// AdminExportReservations downloads every reservation on a list, with the list's filters and sort from
+// the query, as csv or xlsx. Rows are written out as they're read
+func (m *Repository) AdminExportReservations(w http.ResponseWriter, req *http.Request) {
+	src := chi.URLParam(req, "src")
+	format := chi.URLParam(req, "format")
+
+	contentType, ok := exportContentTypes[format]
+	if !ok || (src != "all" && src != "new") {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	filter := reservationFilterFromForm(forms.New(req.URL.Query()))
+	filter.NewOnly = src == "new"
+
+	//the download only starts with the first row, so a failed query can still be an error page
+	var out exportWriter
+	start := func() error {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="reservations-%s-%s.%s"`, src, time.Now().Format("2006-01-02"), format))
+
+		var err error
+		if format == "xlsx" {
+			out, err = xlsx.New(w, "Reservations")
+		} else {
+			out = newCSVExport(w)
+		}
+		if err != nil {
+			return err
+		}
+		return out.Row(exportColumns...)
+	}
+
+	err := m.DB.EachReservation(filter, func(r models.ReservationAmounts) error {
+		if out == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return out.Row(exportRow(r)...)
+	})
+	if err == nil && out == nil {
+		err = start()
+	}
+	if err == nil {
+		err = out.Close()
+	}
+
+	if err != nil {
+		if out == nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		//too late for an error page, the download is left cut short
+		m.App.ErrorLog.Println(err)
+	}
+}
+
+// exportRow is a reservation as a row of an export, with amounts in dollars
+func exportRow(r models.ReservationAmounts) []interface{} {
+	dollars := func(cents int) float64 { return float64(cents) / 100 }
+
+	return []interface{}{
+		r.ID, r.FirstName, r.LastName, r.Email, r.Phone, r.Room.RoomName,
+		r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02"), pricing.Nights(r.StartDate, r.EndDate),
+		r.Status, r.Source, r.CreatedAt.Format("2006-01-02"),
+		dollars(r.Invoiced), dollars(r.Paid), dollars(r.Invoiced - r.Paid), r.Currency,
+	}
+}
+
+// csvExport writes an export as csv
+type csvExport struct {
+	w *csv.Writer
+}
+
+func newCSVExport(w io.Writer) *csvExport {
+	return &csvExport{w: csv.NewWriter(w)}
+}
+
+// Row writes a row, with amounts to the cent. Text that a spreadsheet would take for a formula is
+// quoted so it stays text
+func (c *csvExport) Row(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', 2, 64)
+		case string:
+			if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+				v = "'" + v
+			}
+			record[i] = v
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return c.w.Write(record)
+}
+
+// Close flushes what's left of the csv
+func (c *csvExport) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminExportReservations(t *testing.T) {
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	var tests = []struct {
+		name                string
+		url                 string
+		expectedCode        int
+		expectedContentType string
+	}{
+		{"csv", "/admin/export-reservations/all/csv?q=smith&sort=room", http.StatusOK, "text/csv; charset=utf-8"},
+		{"xlsx", "/admin/export-reservations/new/xlsx", http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"unknown format", "/admin/export-reservations/all/pdf", http.StatusNotFound, ""},
+		{"unknown list", "/admin/export-reservations/old/csv", http.StatusNotFound, ""},
+		{"query fails", "/admin/export-reservations/all/csv?q=error", http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range tests {
+		resp, err := ts.Client().Get(ts.URL + e.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedCode)
+			continue
+		}
+		if e.expectedContentType == "" {
+			continue
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != e.expectedContentType {
+			t.Errorf("%s: expected content type %s, got %s", e.name, e.expectedContentType, ct)
+		}
+		if !strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment;") {
+			t.Errorf("%s: expected a download", e.name)
+		}
+
+		switch e.name {
+		case "csv":
+			records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("expected a heading and one reservation, got %v", records)
+			}
+			want := "1,John,Smith,john@smith.com,,General's Quarters,2050-01-01,2050-01-03,2,confirmed,phone,0001-01-01,300.00,75.00,225.00,USD"
+			if got := strings.Join(records[1], ","); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		case "xlsx":
+			if _, err := zip.NewReader(bytes.NewReader(body), int64(len(body))); err != nil {
+				t.Errorf("xlsx isn't a zip: %s", err)
+			}
+		}
+	}
+}
+
+func TestCSVExportQuotesFormulas(t *testing.T) {
+	var out bytes.Buffer
+	c := newCSVExport(&out)
+	_ = c.Row("=SUM(A1:A2)", "+1 555", "Smith", 12.5, 3)
+	_ = c.Close()
+
+	if got := strings.TrimSpace(out.String()); got != "'=SUM(A1:A2),'+1 555,Smith,12.50,3" {
+		t.Errorf("unexpected row %s", got)
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// deskList is one of the lists of guests on the front desk page
+type deskList struct {
+	Title        string
+	Empty        string
+	Reservations []models.Reservation
+}
+
+// AdminToday shows the front desk who arrives, who leaves and who is staying over on the date in the
+// query, today unless asked, room by room
+func (m *Repository) AdminToday(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	form := forms.New(req.URL.Query())
+	day := form.OptionalDate("date")
+	if day.IsZero() {
+		day = today
+	}
+
+	reservations, err := m.DB.ReservationsForDay(day)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	arrivals, departures, inHouse := splitDay(reservations, day)
+
+	data := make(map[string]interface{})
+	data["day"] = day
+	data["lists"] = []deskList{
+		{"Arriving", "Nobody arrives", arrivals},
+		{"Leaving", "Nobody leaves", departures},
+		{"Staying Over", "Nobody is staying over", inHouse},
+	}
+
+	form.Set("date", day.Format("2006-01-02"))
+
+	stringMap := make(map[string]string)
+	stringMap["date"] = day.Format("2006-01-02")
+	stringMap["previous"] = todayLink(day.AddDate(0, 0, -1))
+	stringMap["next"] = todayLink(day.AddDate(0, 0, 1))
+	if !day.Equal(today) {
+		stringMap["today"] = todayLink(today)
+	}
+
+	render.Template(w, req, "admin-today.page.html", &models.TemplateData{
+		Form:      form,
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// AdminCheckIn checks the guest of a confirmed reservation in, and goes back to the day it was done from
+func (m *Repository) AdminCheckIn(w http.ResponseWriter, req *http.Request) {
+	m.frontDeskAction(w, req, m.DB.CheckInReservation, models.AuditCheckIn, "Guest checked in",
+		"The guest can't be checked in, the reservation isn't confirmed or they already are")
+}
+
+// AdminCheckOut checks the guest of a reservation out, and goes back to the day it was done from
+func (m *Repository) AdminCheckOut(w http.ResponseWriter, req *http.Request) {
+	m.frontDeskAction(w, req, m.DB.CheckOutReservation, models.AuditCheckOut, "Guest checked out",
+		"The guest can't be checked out, they haven't checked in or have already left")
+}
+
+// frontDeskAction checks a guest in or out with do, saying how it went on the front desk page
+func (m *Repository) frontDeskAction(w http.ResponseWriter, req *http.Request, do func(int) (bool, error), action, done, failed string) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	before, err := m.DB.GetReservationByID(id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	ok, err := do(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if ok {
+		after, err := m.DB.GetReservationByID(id)
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+		}
+		m.audit(req, action, models.EntityReservation, id, before, after)
+		m.App.Session.Put(req.Context(), "flash", done)
+	} else {
+		m.App.Session.Put(req.Context(), "error", failed)
+	}
+
+	link := "/admin/today"
+	if day, err := time.Parse("2006-01-02", req.URL.Query().Get("date")); err == nil {
+		link = todayLink(day)
+	}
+	http.Redirect(w, req, link, http.StatusSeeOther)
+}
+
+// splitDay sorts the reservations around a day into the guests arriving on it, those leaving on it and
+// those staying the night either side of it
+func splitDay(reservations []models.Reservation, day time.Time) (arrivals, departures, inHouse []models.Reservation) {
+	for _, r := range reservations {
+		switch {
+		case r.StartDate.Equal(day):
+			arrivals = append(arrivals, r)
+		case r.EndDate.Equal(day):
+			departures = append(departures, r)
+		case r.StartDate.Before(day) && r.EndDate.After(day):
+			inHouse = append(inHouse, r)
+		}
+	}
+	return arrivals, departures, inHouse
+}
+
+// todayLink is the front desk page for day
+func todayLink(day time.Time) string {
+	return "/admin/today?date=" + day.Format("2006-01-02")
+}
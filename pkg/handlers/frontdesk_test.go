@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"BookingProject/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSplitDay(t *testing.T) {
+	day := time.Date(2050, 1, 10, 0, 0, 0, 0, time.UTC)
+	reservations := []models.Reservation{
+		{ID: 1, StartDate: day, EndDate: day.AddDate(0, 0, 2)},
+		{ID: 2, StartDate: day.AddDate(0, 0, -2), EndDate: day},
+		{ID: 3, StartDate: day.AddDate(0, 0, -1), EndDate: day.AddDate(0, 0, 1)},
+		{ID: 4, StartDate: day.AddDate(0, 0, 1), EndDate: day.AddDate(0, 0, 3)},
+	}
+
+	arrivals, departures, inHouse := splitDay(reservations, day)
+
+	if len(arrivals) != 1 || arrivals[0].ID != 1 {
+		t.Errorf("unexpected arrivals %+v", arrivals)
+	}
+	if len(departures) != 1 || departures[0].ID != 2 {
+		t.Errorf("unexpected departures %+v", departures)
+	}
+	if len(inHouse) != 1 || inHouse[0].ID != 3 {
+		t.Errorf("unexpected guests staying over %+v", inHouse)
+	}
+}
+
+func TestFrontDeskActions(t *testing.T) {
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var tests = []struct {
+		name             string
+		url              string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"check in", "/admin/check-in/1/do?date=2050-01-10", http.StatusSeeOther, "/admin/today?date=2050-01-10"},
+		{"check in not confirmed", "/admin/check-in/101/do?date=2050-01-10", http.StatusSeeOther, "/admin/today?date=2050-01-10"},
+		{"check out", "/admin/check-out/1/do", http.StatusSeeOther, "/admin/today"},
+		{"check out bad date", "/admin/check-out/1/do?date=//evil.com", http.StatusSeeOther, "/admin/today"},
+		{"check out bad id", "/admin/check-out/x/do", http.StatusBadRequest, ""},
+	}
+
+	for _, e := range tests {
+		resp, err := client.Get(ts.URL + e.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedCode)
+		}
+		if e.expectedLocation != "" && resp.Header.Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected redirect to %s, got %s", e.name, e.expectedLocation, resp.Header.Get("Location"))
+		}
+	}
+}
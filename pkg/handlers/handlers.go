@@ -1,16 +1,22 @@
 package handlers
 
 import (
+	"BookingProject/pkg/availability"
 	"BookingProject/pkg/config"
 	"BookingProject/pkg/driver"
 	"BookingProject/pkg/forms"
 	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/invoices"
 	"BookingProject/pkg/models"
+	"BookingProject/pkg/pricing"
 	"BookingProject/pkg/render"
 	"BookingProject/pkg/repository"
 	"BookingProject/pkg/repository/dbrepo"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
 	"strconv"
@@ -48,6 +54,12 @@ func NewHandlers(r *Repository) {
 	Repo = r
 }
 
+// baseURL is where the site is reached from outside, for links that leave the site. It comes from the
+// config rather than the request, whose host header is whatever the client says
+func (m *Repository) baseURL() string {
+	return m.App.BaseURL
+}
+
 func (m *Repository) Home(w http.ResponseWriter, req *http.Request) {
 
 	render.Template(w, req, "home.page.html", &models.TemplateData{})
@@ -72,11 +84,22 @@ func (m *Repository) Majors(w http.ResponseWriter, req *http.Request) {
 
 func (m *Repository) Availability(w http.ResponseWriter, req *http.Request) {
 
-	render.Template(w, req, "search-availability.page.html", &models.TemplateData{})
+	intMap := make(map[string]int)
+	intMap["search_window"] = m.App.SearchWindowDays
+
+	render.Template(w, req, "search-availability.page.html", &models.TemplateData{
+		IntMap: intMap,
+	})
 }
 
 func (m *Repository) PostAvailability(w http.ResponseWriter, req *http.Request) {
 
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
 	start := req.Form.Get("start")
 	end := req.Form.Get("end")
 
@@ -102,9 +125,38 @@ func (m *Repository) PostAvailability(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	if len(rooms) == 0 && req.Form.Get("flexible") != "" {
+		allRooms, err := m.DB.AllRooms()
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		alternatives, err := m.nearbyAvailability(startDate, endDate, allRooms)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		if len(alternatives) > 0 {
+			data := make(map[string]interface{})
+			data["alternatives"] = alternatives
+
+			stringMap := make(map[string]string)
+			stringMap["start_date"] = start
+			stringMap["end_date"] = end
+
+			render.Template(w, req, "choose-room.page.html", &models.TemplateData{
+				Data:      data,
+				StringMap: stringMap,
+			})
+			return
+		}
+	}
+
 	if len(rooms) == 0 {
-		m.App.Session.Put(req.Context(), "error", "No availability")
-		http.Redirect(w, req, "/search-availability", http.StatusSeeOther)
+		m.App.Session.Put(req.Context(), "warning", "No rooms are free for those dates, but you can join the waitlist")
+		http.Redirect(w, req, fmt.Sprintf("/waitlist?start=%s&end=%s", start, end), http.StatusSeeOther)
 		return
 	}
 
@@ -118,15 +170,38 @@ func (m *Repository) PostAvailability(w http.ResponseWriter, req *http.Request)
 
 	m.App.Session.Put(req.Context(), "reservation", res)
 
-	render.Template(w, req, "reservation-summary.html", &models.TemplateData{
+	render.Template(w, req, "choose-room.page.html", &models.TemplateData{
 		Data: data,
 	})
 }
 
+// how many other dates are suggested for each room when a flexible search finds nothing
+const alternativesPerRoom = 3
+
+// nearbyAvailability finds stays as long as start to end, close to those dates, that the rooms are free for
+func (m *Repository) nearbyAvailability(start, end time.Time, rooms []models.Room) ([]availability.Alternative, error) {
+	window := m.App.SearchWindowDays
+
+	restrictions, err := m.DB.GetRestrictionsForAllRoomsByDate(start.AddDate(0, 0, -window), end.AddDate(0, 0, window))
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	return availability.Nearby(rooms, restrictions, start, end, today, window, alternativesPerRoom), nil
+}
+
 type jsonResponse struct {
-	OK        bool   `json:"ok"`
-	Message   string `json:"message"`
-	RoomID    string `json:room_id`
+	OK           bool              `json:"ok"`
+	Message      string            `json:"message"`
+	RoomID       string            `json:"room_id"`
+	StartDate    string            `json:"start_date"`
+	EndDate      string            `json:"end_date"`
+	Alternatives []jsonAlternative `json:"alternatives,omitempty"`
+}
+
+// jsonAlternative is other dates the room is free for, offered by a flexible search
+type jsonAlternative struct {
 	StartDate string `json:"start_date"`
 	EndDate   string `json:"end_date"`
 }
@@ -134,25 +209,76 @@ type jsonResponse struct {
 // handles request for avail in general and major, and sends JSON response
 func (m *Repository) JSONAvailability(w http.ResponseWriter, req *http.Request) {
 
+	err := req.ParseForm()
+	if err != nil {
+		m.writeAvailabilityJSON(w, jsonResponse{Message: "Internal Server Error"})
+		return
+	}
+
 	sd := req.Form.Get("start")
 	ed := req.Form.Get("end")
 
+	resp := jsonResponse{
+		StartDate: sd,
+		EndDate:   ed,
+		RoomID:    req.Form.Get("room_id"),
+	}
+
 	layout := "2006-01-02"
-	startDate, _ := time.Parse(layout, sd)
-	endDate, _ := time.Parse(layout, ed)
+	startDate, err := time.Parse(layout, sd)
+	if err != nil {
+		resp.Message = "Invalid start date"
+		m.writeAvailabilityJSON(w, resp)
+		return
+	}
 
-	roomID, _ := strconv.Atoi(req.Form.Get("room_id"))
+	endDate, err := time.Parse(layout, ed)
+	if err != nil {
+		resp.Message = "Invalid end date"
+		m.writeAvailabilityJSON(w, resp)
+		return
+	}
 
-	available, _ := m.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, roomID)
+	roomID, err := strconv.Atoi(resp.RoomID)
+	if err != nil {
+		resp.Message = "Invalid room id"
+		m.writeAvailabilityJSON(w, resp)
+		return
+	}
 
-	resp := jsonResponse{
-		OK:        available,
-		Message:   "Available",
-		StartDate: sd,
-		EndDate:   ed,
-		RoomID:    strconv.Itoa(roomID),
+	available, err := m.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, roomID)
+	if err != nil {
+		resp.Message = "Error querying database"
+		m.writeAvailabilityJSON(w, resp)
+		return
 	}
 
+	resp.OK = available
+	resp.Message = "Available"
+	if !available {
+		resp.Message = "Not available"
+	}
+
+	if !available && req.Form.Get("flexible") != "" {
+		alternatives, err := m.nearbyAvailability(startDate, endDate, []models.Room{{ID: roomID}})
+		if err != nil {
+			resp.Message = "Error querying database"
+			m.writeAvailabilityJSON(w, resp)
+			return
+		}
+
+		for _, a := range alternatives {
+			resp.Alternatives = append(resp.Alternatives, jsonAlternative{
+				StartDate: a.StartDate.Format(layout),
+				EndDate:   a.EndDate.Format(layout),
+			})
+		}
+	}
+
+	m.writeAvailabilityJSON(w, resp)
+}
+
+func (m *Repository) writeAvailabilityJSON(w http.ResponseWriter, resp jsonResponse) {
 	out, err := json.MarshalIndent(resp, "", "     ")
 	if err != nil {
 		helpers.ServerError(w, err)
@@ -194,8 +320,9 @@ func (m *Repository) Reservation(w http.ResponseWriter, req *http.Request) {
 
 	stringMap := make(map[string]string)
 
-	stringMap["start-date"] = sd
-	stringMap["end-date"] = ed
+	stringMap["start_date"] = sd
+	stringMap["end_date"] = ed
+	stringMap["hold_minutes"] = strconv.Itoa(int(holdDuration.Minutes()))
 
 	data := make(map[string]interface{})
 	data["reservation"] = res
@@ -261,6 +388,7 @@ func (m *Repository) PostReservation(w http.ResponseWriter, req *http.Request) {
 		EndDate:   endDate,
 		RoomID:    roomID,
 		Room:      room,
+		Status:    models.ReservationPending,
 	}
 
 	form := forms.New(req.PostForm)
@@ -270,48 +398,133 @@ func (m *Repository) PostReservation(w http.ResponseWriter, req *http.Request) {
 	form.Required("first_name", "last_name", "email", "phone")
 	form.MinLength("first_name", 3, req)
 	form.IsEmail("email")
+	reservation.SpecialRequests = checkSpecialRequests(form)
+
+	if problem := pricing.MinStayProblem(room, startDate, endDate); problem != "" {
+		form.Errors.Add("start_date", problem)
+	}
+
+	if form.Get("promo_code") != "" {
+		m.checkPromoCode(form, &reservation)
+	}
+
 	//if form is invalid, we don't want to lost that data
 	if !form.Valid() {
-		data := make(map[string]interface{})
-		data["reservation"] = reservation
-		http.Error(w, "Invalid Data in form", http.StatusSeeOther)
-		render.Template(w, req, "make-reservation.page.html", &models.TemplateData{
-			Form: form,
-			Data: data,
-		})
-
+		renderReservationForm(w, req, form, reservation, sd, ed)
 		return
 	}
 
 	newReservationID, err := m.DB.InsertReservation(reservation)
-	if err != nil {
+	if errors.Is(err, repository.ErrPromoUsedUp) {
+		//the last use of the code went to someone else while the guest was filling in the form
+		form.Errors.Add("promo_code", "This code has already been used up")
+		renderReservationForm(w, req, form, reservation, sd, ed)
+		return
+	} else if err != nil {
 		m.App.Session.Put(req.Context(), "error", "Can't add reservation")
 		http.Redirect(w, req, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	m.App.Session.Put(req.Context(), "reservation", reservation)
+	reservation.ID = newReservationID
 
+	//the room is kept while the guest pays, and let go if they never do
 	restriction := models.RoomRestriction{
 		StartDate:     reservation.StartDate,
 		EndDate:       reservation.EndDate,
 		RoomID:        reservation.RoomID,
 		ReservationID: newReservationID,
 		RestrictionID: 1,
+		ExpiresAt:     time.Now().Add(checkoutDuration),
 	}
 
-	err = m.DB.InsertRoomRestriction(restriction)
+	converted, err := m.DB.ConvertHold(m.App.Session.PopInt(req.Context(), "hold_id"), restriction)
 	if err != nil {
 		m.App.Session.Put(req.Context(), "error", "Can't insert restriction")
 		http.Redirect(w, req, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	//send notifications
+	if !converted {
+		//the hold ran out, so the room can only be taken if nobody else has booked it since
+		err = m.DB.InsertReservationRestriction(restriction)
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = m.DB.DeleteReservation(newReservationID)
+			m.App.Session.Put(req.Context(), "error", "Sorry, the room was booked by someone else while you were filling in your details")
+			http.Redirect(w, req, "/search-availability", http.StatusSeeOther)
+			return
+		} else if err != nil {
+			m.App.Session.Put(req.Context(), "error", "Can't insert restriction")
+			http.Redirect(w, req, "/", http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	//the confirmation goes out once the payment is in
+	m.App.Session.Put(req.Context(), "reservation", reservation)
+
+	http.Redirect(w, req, "/checkout", http.StatusSeeOther)
+}
+
+// renderReservationForm shows the reservation form again with what the guest posted and what was wrong with it
+func renderReservationForm(w http.ResponseWriter, req *http.Request, form *forms.Form, reservation models.Reservation, sd, ed string) {
+	data := make(map[string]interface{})
+	data["reservation"] = reservation
 
+	stringMap := make(map[string]string)
+	stringMap["start_date"] = sd
+	stringMap["end_date"] = ed
+	stringMap["hold_minutes"] = strconv.Itoa(int(holdDuration.Minutes()))
+
+	render.Template(w, req, "make-reservation.page.html", &models.TemplateData{
+		Form:      form,
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// checkPromoCode looks up the promo code on the reservation form and, if the stay qualifies for it,
+// puts it on the reservation
+func (m *Repository) checkPromoCode(form *forms.Form, res *models.Reservation) {
+	form.IsCode("promo_code")
+	if !form.Valid() {
+		return
+	}
+
+	promo, err := m.DB.GetPromoCodeByCode(form.Get("promo_code"))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			m.App.ErrorLog.Println(err)
+		}
+		form.Errors.Add("promo_code", "That promo code isn't valid")
+		return
+	}
+
+	if problem := pricing.PromoProblem(promo, res.RoomID, res.StartDate, res.EndDate); problem != "" {
+		form.Errors.Add("promo_code", problem)
+		return
+	}
+
+	res.PromoCodeID = promo.ID
+	res.PromoCode = promo
+}
+
+// sendConfirmation emails the guest a link to their reservation, with the invoice attached
+func (m *Repository) sendConfirmation(reservation models.Reservation) {
+	//the confirmation still goes out if the invoice can't be made, since the link can issue it later
+	inv, err := m.loadInvoice(reservation.ID)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+
+	link := m.guestLink(reservation.ID, "")
 	htmlMessage := fmt.Sprintf(`<strong>Reservation Confirmation</strong><br>
 	Dear %s:,<br>
-	This is to confirm your reservation from %s to %s`, reservation.FirstName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"))
+	This is to confirm your reservation from %s to %s<br>
+	%s
+	You can see your reservation and download your invoice at <a href="%s">%s</a>`,
+		reservation.FirstName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"),
+		priceTable(inv), link, link)
 
 	msg := models.MailData{
 		To:      reservation.Email,
@@ -320,11 +533,36 @@ func (m *Repository) PostReservation(w http.ResponseWriter, req *http.Request) {
 		Content: htmlMessage,
 	}
 
+	if err == nil {
+		msg.Attachments = append(msg.Attachments, models.Attachment{
+			Name:     invoices.FileName(inv),
+			MimeType: "application/pdf",
+			Data:     invoices.PDF(inv),
+		})
+	}
+
 	m.App.MailChan <- msg
+}
 
-	m.App.Session.Put(req.Context(), "reservation", reservation)
+// priceTable itemises an invoice for an html email
+func priceTable(inv models.Invoice) string {
+	if len(inv.Lines) == 0 {
+		return ""
+	}
 
-	http.Redirect(w, req, "/reservation-summary", http.StatusSeeOther)
+	var b strings.Builder
+	b.WriteString("<table>")
+	for _, l := range inv.Lines {
+		fmt.Fprintf(&b, `<tr><td>%s</td><td align="right">%s</td></tr>`, html.EscapeString(l.Description), pricing.Money(l.Amount))
+	}
+	fmt.Fprintf(&b, `<tr><th align="left">Total</th><th align="right">%s</th></tr>`, pricing.Money(inv.Total))
+	if paid := inv.Paid(); paid > 0 {
+		fmt.Fprintf(&b, `<tr><td>Paid</td><td align="right">%s</td></tr>`, pricing.Money(paid))
+		fmt.Fprintf(&b, `<tr><th align="left">Balance due</th><th align="right">%s</th></tr>`, pricing.Money(inv.Balance()))
+	}
+	b.WriteString("</table><br>")
+
+	return b.String()
 }
 
 func (m *Repository) ReservationSummary(w http.ResponseWriter, req *http.Request) {
@@ -341,13 +579,30 @@ func (m *Repository) ReservationSummary(w http.ResponseWriter, req *http.Request
 	data := make(map[string]interface{})
 	data["reservation"] = reservation
 
+	if id := m.App.Session.PopInt(req.Context(), "payment_id"); id > 0 {
+		payment, err := m.DB.GetPaymentByID(id)
+		if err == nil {
+			data["payment"] = payment
+		}
+	}
+
+	inv, err := m.priceBreakdown(reservation)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	data["invoice"] = inv
+
 	sd := reservation.StartDate.Format("2006-01-02")
 	ed := reservation.EndDate.Format("2006-01-02")
 
 	stringMap := make(map[string]string)
 	stringMap["start_date"] = sd
 	stringMap["end_date"] = ed
-	render.Template(w, req, "reservation-summary.html", &models.TemplateData{
+	if reservation.Confirmed() {
+		stringMap["guest_link"] = m.guestLink(reservation.ID, "")
+	}
+	render.Template(w, req, "reservation-summary.page.html", &models.TemplateData{
 		Data:      data,
 		StringMap: stringMap,
 	})
@@ -370,6 +625,10 @@ func (m *Repository) ChooseRoom(w http.ResponseWriter, req *http.Request) {
 
 	res.RoomID = roomID
 
+	if !m.holdRoom(w, req, res) {
+		return
+	}
+
 	m.App.Session.Put(req.Context(), "reservation", res)
 	http.Redirect(w, req, "/make-reservation", http.StatusSeeOther)
 
@@ -392,12 +651,53 @@ func (m *Repository) BookRoom(w http.ResponseWriter, req *http.Request) {
 	res.StartDate = startDate
 	res.EndDate = endDate
 
+	if !m.holdRoom(w, req, res) {
+		return
+	}
+
 	m.App.Session.Put(req.Context(), "reservation", res)
 
 	http.Redirect(w, req, "/make-reservation", http.StatusSeeOther)
 
 }
 
+// how long a room is kept for a guest between choosing it and sending their details
+const holdDuration = 15 * time.Minute
+
+// how long a guest then has to pay before their reservation is cancelled and the room let go
+const checkoutDuration = 30 * time.Minute
+
+// holdRoom keeps the room for the guest while they fill in their details, so nobody else can book it
+// in the meantime. It reports false, having sent the guest back to search, when the room has gone
+func (m *Repository) holdRoom(w http.ResponseWriter, req *http.Request, res models.Reservation) bool {
+	//a guest who goes back and picks again only keeps the room they picked last
+	if id := m.App.Session.PopInt(req.Context(), "hold_id"); id > 0 {
+		err := m.DB.ReleaseHold(id)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return false
+		}
+	}
+
+	holdID, err := m.DB.InsertHold(models.RoomRestriction{
+		StartDate: res.StartDate,
+		EndDate:   res.EndDate,
+		RoomID:    res.RoomID,
+		ExpiresAt: time.Now().Add(holdDuration),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		m.App.Session.Put(req.Context(), "error", "Sorry, that room has just been taken, please choose another")
+		http.Redirect(w, req, "/search-availability", http.StatusSeeOther)
+		return false
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return false
+	}
+
+	m.App.Session.Put(req.Context(), "hold_id", holdID)
+	return true
+}
+
 func (m *Repository) ShowLogin(w http.ResponseWriter, req *http.Request) {
 	render.Template(w, req, "login.page.html", &models.TemplateData{Form: forms.New(nil)})
 }
@@ -447,41 +747,6 @@ func (m *Repository) Logout(w http.ResponseWriter, req *http.Request) {
 	http.Redirect(w, req, "/user/login", http.StatusSeeOther)
 }
 
-func (m *Repository) AdminDashboard(w http.ResponseWriter, req *http.Request) {
-	render.Template(w, req, "admin-dashboard.page.html", &models.TemplateData{})
-}
-
-func (m *Repository) AdminNewReservations(w http.ResponseWriter, req *http.Request) {
-	reservations, err := m.DB.AllNewReservations()
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
-
-	data := make(map[string]interface{})
-	data["reservations"] = reservations
-
-	render.Template(w, req, "admin-new-reservations.page.html", &models.TemplateData{
-		Data: data,
-	})
-}
-
-func (m *Repository) AdminAllReservations(w http.ResponseWriter, req *http.Request) {
-
-	reservations, err := m.DB.AllReservations()
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
-
-	data := make(map[string]interface{})
-	data["reservations"] = reservations
-
-	render.Template(w, req, "admin-all-reservations.page.html", &models.TemplateData{
-		Data: data,
-	})
-}
-
 func (m *Repository) AdminShowReservation(w http.ResponseWriter, req *http.Request) {
 
 	exploded := strings.Split(req.RequestURI, "/")
@@ -503,6 +768,8 @@ func (m *Repository) AdminShowReservation(w http.ResponseWriter, req *http.Reque
 
 	stringMap["month"] = month
 	stringMap["year"] = year
+	stringMap["start"] = req.URL.Query().Get("start")
+	stringMap["weeks"] = req.URL.Query().Get("weeks")
 
 	//get data from DB
 
@@ -512,13 +779,41 @@ func (m *Repository) AdminShowReservation(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	m.renderAdminReservation(w, req, res, stringMap, forms.New(nil))
+}
+
+// renderAdminReservation shows a reservation's admin page with res filled into its form
+func (m *Repository) renderAdminReservation(w http.ResponseWriter, req *http.Request, res models.Reservation,
+	stringMap map[string]string, form *forms.Form) {
+	inv, err := m.priceBreakdown(res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	notes, err := m.DB.NotesForReservation(res.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
 	data := make(map[string]interface{})
 	data["reservation"] = res
+	data["payments"] = inv.Payments
+	data["invoice"] = inv
+	data["rooms"] = rooms
+	data["notes"] = threadNotes(notes)
 
 	render.Template(w, req, "admin-reservations-show.page.html", &models.TemplateData{
 		Data:      data,
 		StringMap: stringMap,
-		Form:      forms.New(nil),
+		Form:      form,
 	})
 }
 
@@ -552,23 +847,58 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, req *http.R
 		return
 	}
 
+	before := res
+	form := forms.New(req.PostForm)
 	res.FirstName = req.Form.Get("first_name")
 	res.LastName = req.Form.Get("last_name")
 	res.Email = req.Form.Get("email")
 	res.Phone = req.Form.Get("phone")
+	//forms from before special requests could be changed don't post them
+	if _, ok := req.Form["special_requests"]; ok {
+		res.SpecialRequests = checkSpecialRequests(form)
+	}
+
+	if !form.Valid() {
+		for _, key := range []string{"year", "month", "start", "weeks"} {
+			stringMap[key] = req.Form.Get(key)
+		}
+		m.renderAdminReservation(w, req, res, stringMap, form)
+		return
+	}
 
-	err = m.DB.UpdateReservation(res)
+	//the stay is checked before anything is saved, so a change that can't be made saves nothing
+	moved, problem, err := m.stayChange(req, res)
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
+	if problem == "" && moved.ID != 0 {
+		problem, err = m.moveStay(req, before, moved)
+	} else if problem == "" {
+		err = m.DB.UpdateReservation(res)
+		if err == nil && res != before {
+			m.audit(req, models.AuditUpdate, models.EntityReservation, id, before, res)
+		}
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if problem != "" {
+		m.App.Session.Put(req.Context(), "error", problem)
+		http.Redirect(w, req, fmt.Sprintf("/admin/reservations/%s/%d/show", src, id), http.StatusSeeOther)
+		return
+	}
+
 	m.App.Session.Put(req.Context(), "flash", "Changes saved")
 
 	month := req.Form.Get("month")
 	year := req.Form.Get("year")
 
-	if year == "" {
+	if start := req.Form.Get("start"); start != "" {
+		http.Redirect(w, req, calendarLink(start, req.Form.Get("weeks")), http.StatusSeeOther)
+	} else if year == "" {
 		http.Redirect(w, req, fmt.Sprintf("admin/reservations-%s", src), http.StatusSeeOther)
 	} else {
 		http.Redirect(w, req, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
@@ -576,18 +906,149 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, req *http.R
 
 }
 
+// stayChange reads the dates and room posted from a reservation's admin page. It returns the reservation
+// moved to them, or an empty one if they're no different, and what's wrong with the change, if anything
+func (m *Repository) stayChange(req *http.Request, res models.Reservation) (models.Reservation, string, error) {
+	var moved models.Reservation
+
+	//forms from before stays could be changed don't post them
+	if req.Form.Get("start_date") == "" {
+		return moved, "", nil
+	}
+
+	start, err := time.Parse("2006-01-02", req.Form.Get("start_date"))
+	if err != nil {
+		return moved, "Enter an arrival date like 2050-01-31", nil
+	}
+	end, err := time.Parse("2006-01-02", req.Form.Get("end_date"))
+	if err != nil {
+		return moved, "Enter a departure date like 2050-01-31", nil
+	}
+	roomID, err := strconv.Atoi(req.Form.Get("room_id"))
+	if err != nil {
+		return moved, "Choose a room", nil
+	}
+
+	if start.Equal(res.StartDate) && end.Equal(res.EndDate) && roomID == res.RoomID {
+		return moved, "", nil
+	}
+
+	if res.Status == models.ReservationCancelled {
+		return moved, "Cancelled reservations can't be moved", nil
+	}
+	if !res.Open() {
+		return moved, "The guest has checked in, so the stay can't be moved", nil
+	}
+	if !end.After(start) {
+		return moved, "Departure has to be after arrival", nil
+	}
+
+	room, err := m.DB.GetRoomByID(roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return moved, "Choose a room", nil
+	} else if err != nil {
+		return moved, "", err
+	}
+
+	moved = res
+	moved.StartDate = start
+	moved.EndDate = end
+	moved.RoomID = roomID
+	moved.Room = room
+
+	return moved, "", nil
+}
+
+// moveStay saves a reservation changed from its admin page, guest details and all, once it has been moved
+// to other dates or another room, repricing it and emailing the guest when asked to. It returns what's
+// wrong with the move, if anything
+func (m *Repository) moveStay(req *http.Request, before, moved models.Reservation) (string, error) {
+	quote, err := m.quote(moved)
+	if err != nil {
+		return "", err
+	}
+
+	ok, err := m.DB.MoveReservation(moved, invoices.FromQuote(moved.ID, quote))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return fmt.Sprintf("%s is already taken for some of those nights", moved.Room.RoomName), nil
+	}
+
+	//the guest's details and the stay are audited apart, the way they're audited when changed on their own
+	res := moved
+	res.StartDate = before.StartDate
+	res.EndDate = before.EndDate
+	res.RoomID = before.RoomID
+	res.Room = before.Room
+	if res != before {
+		m.audit(req, models.AuditUpdate, models.EntityReservation, before.ID, before, res)
+	}
+	m.audit(req, models.AuditMove, models.EntityReservation, before.ID, res, moved)
+
+	//the nights given up may be what someone on the waitlist is after
+	m.NotifyWaitlist()
+
+	if req.Form.Get("notify_guest") != "" {
+		m.sendStayChanged(moved)
+	}
+
+	return "", nil
+}
+
+// sendStayChanged emails the guest the new dates and room of their reservation, and its new price
+func (m *Repository) sendStayChanged(reservation models.Reservation) {
+	inv, err := m.priceBreakdown(reservation)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+
+	link := m.guestLink(reservation.ID, "")
+	htmlMessage := fmt.Sprintf(`<strong>Your reservation has changed</strong><br>
+	Dear %s,<br>
+	Your reservation is now for %s from %s to %s<br>
+	%s
+	You can see your reservation at <a href="%s">%s</a>`,
+		html.EscapeString(reservation.FirstName), html.EscapeString(reservation.Room.RoomName),
+		reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"),
+		priceTable(inv), link, link)
+
+	m.App.MailChan <- models.MailData{
+		To:      reservation.Email,
+		From:    "me@here.com",
+		Subject: "Reservation Changed",
+		Content: htmlMessage,
+	}
+}
+
 func (m *Repository) AdminProcessReservation(w http.ResponseWriter, req *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(req, "id"))
 	src := chi.URLParam(req, "src")
 
-	_ = m.DB.UpdateProcessedForReservation(id, 1)
+	before, err := m.DB.GetReservationByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = m.DB.UpdateProcessedForReservation(id, 1)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	after := before
+	after.Processed = 1
+	m.audit(req, models.AuditProcess, models.EntityReservation, id, before, after)
 
 	year := req.URL.Query().Get("y")
 	month := req.URL.Query().Get("m")
 
 	m.App.Session.Put(req.Context(), "flash", "Reservation Marked as processed")
 
-	if year == "" {
+	if start := req.URL.Query().Get("start"); start != "" {
+		http.Redirect(w, req, calendarLink(start, req.URL.Query().Get("weeks")), http.StatusSeeOther)
+	} else if year == "" {
 		http.Redirect(w, req, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
 	} else {
 		http.Redirect(w, req, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
@@ -599,107 +1060,116 @@ func (m *Repository) AdminDeleteReservation(w http.ResponseWriter, req *http.Req
 	id, _ := strconv.Atoi(chi.URLParam(req, "id"))
 	src := chi.URLParam(req, "src")
 
-	_ = m.DB.DeleteReservation(id)
+	//payments are kept for the accounts, so a reservation with any can only be cancelled
+	paid, err := m.DB.GetPaymentsForReservation(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if len(paid) > 0 {
+		m.App.Session.Put(req.Context(), "error", "This reservation has payments against it, so cancel it instead")
+		http.Redirect(w, req, fmt.Sprintf("/admin/reservations/%s/%d/show", src, id), http.StatusSeeOther)
+		return
+	}
+
+	before, err := m.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = m.DB.DeleteReservation(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditDelete, models.EntityReservation, id, before, nil)
+	m.NotifyWaitlist()
 
 	year := req.URL.Query().Get("y")
 	month := req.URL.Query().Get("m")
 
 	m.App.Session.Put(req.Context(), "flash", "Reservation Deleted")
 
-	if year == "" {
+	if start := req.URL.Query().Get("start"); start != "" {
+		http.Redirect(w, req, calendarLink(start, req.URL.Query().Get("weeks")), http.StatusSeeOther)
+	} else if year == "" {
 		http.Redirect(w, req, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
 	} else {
 		http.Redirect(w, req, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
 	}
 }
 
+// AdminReservationsCalendar shows every room over weeks weeks from start (YYYY-MM-DD), defaulting to today
+// and four weeks. Older links give the year and month to start at as y and m instead
 func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, req *http.Request) {
 
 	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	today := start
 
 	if req.URL.Query().Get("y") != "" {
 		year, _ := strconv.Atoi(req.URL.Query().Get("y"))
 		month, _ := strconv.Atoi(req.URL.Query().Get("m"))
 
-		now = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		start = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
 	}
 
-	data := make(map[string]interface{})
-	data["now"] = now
-
-	next := now.AddDate(0, 1, 0)  //next month
-	last := now.AddDate(0, -1, 0) // last month
-
-	nextMonth := next.Format("01") //gives month
-	nextMonthYear := next.Format("2006")
-
-	lastMonth := last.Format("01")
-	lastMonthYear := last.Format("2006")
-
-	stringMap := make(map[string]string)
-
-	stringMap["next_month"] = nextMonth
-	stringMap["next_month_year"] = nextMonthYear
-	stringMap["last_month"] = lastMonth
-	stringMap["last_month_year"] = lastMonthYear
-
-	stringMap["this_month"] = now.Format("01")
-	stringMap["this_month_year"] = now.Format("2006")
-
-	//get the first and last days of month
-	currentYear, currentMonth, _ := now.Date()
-	currentLocation := now.Location()
-	firstOfMonth := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, currentLocation)
-	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
+	if s, err := time.Parse("2006-01-02", req.URL.Query().Get("start")); err == nil {
+		start = s
+	}
 
-	intMap := make(map[string]int)
+	weeks := defaultTimelineWeeks
+	if n, err := strconv.Atoi(req.URL.Query().Get("weeks")); err == nil && n >= 1 && n <= maxTimelineWeeks {
+		weeks = n
+	}
 
-	intMap["days_in_month"] = lastOfMonth.Day()
+	end := start.AddDate(0, 0, 7*weeks)
 
 	rooms, err := m.DB.AllRooms()
-
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	data["rooms"] = rooms
-
-	for _, x := range rooms {
-
-		reservationMap := make(map[string]int)
-		blockMap := make(map[string]int)
+	//one query for every room, not one per room
+	restrictions, err := m.DB.GetRestrictionsForCalendar(start, end)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
-		for d := firstOfMonth; !d.After(lastOfMonth); d.AddDate(0, 0, 1) {
-			reservationMap[d.Format("2006-01-2")] = 0
-			blockMap[d.Format("2006-01-2")] = 0
-		}
+	rows, blocks := buildTimeline(rooms, restrictions, start, end)
 
-		//get all restrictions for the current room
-		restrictions, err := m.DB.GetRestrictionsForRoomByDate(x.ID, firstOfMonth, lastOfMonth)
-		if err != nil {
-			helpers.ServerError(w, err)
-			return
-		}
+	for roomID, blockMap := range blocks {
+		m.App.Session.Put(req.Context(), fmt.Sprintf("block_map_%d", roomID), blockMap)
+	}
 
-		for _, y := range restrictions {
-			if y.ReservationID > 0 {
-				//its reservation
-				for d := y.StartDate; !d.After(y.EndDate); d.AddDate(0, 0, 1) {
-					reservationMap[d.Format("2006-01-2")] = y.ReservationID
-				}
+	var nights []time.Time
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		nights = append(nights, d)
+	}
 
-			} else {
-				//its a block
-				blockMap[y.StartDate.Format("2006-01-2")] = y.ID
-			}
-		}
+	data := make(map[string]interface{})
+	data["rows"] = rows
+	data["months"] = timelineMonths(start, end)
+	data["nights"] = nights
+	data["spans"] = timelineSpans
+	data["start"] = start
+	data["end"] = end.AddDate(0, 0, -1)
 
-		data[fmt.Sprintf("reservation_map_%d", x.ID)] = reservationMap
-		data[fmt.Sprintf("block_map_%d", x.ID)] = blockMap
+	stringMap := make(map[string]string)
+	stringMap["start"] = start.Format("2006-01-02")
+	stringMap["weeks"] = strconv.Itoa(weeks)
+	stringMap["today"] = today.Format("2006-01-02")
+	stringMap["previous"] = start.AddDate(0, 0, -7*weeks).Format("2006-01-02")
+	stringMap["next"] = end.Format("2006-01-02")
 
-		m.App.Session.Put(req.Context(), fmt.Sprintf("block_map_%d", x.ID), blockMap)
-	}
+	intMap := make(map[string]int)
+	intMap["weeks"] = weeks
 
 	render.Template(w, req, "admin-reservations-calendar.page.html", &models.TemplateData{
 		StringMap: stringMap,
@@ -715,9 +1185,6 @@ func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, req *h
 		return
 	}
 
-	year, _ := strconv.Atoi(req.Form.Get("y"))
-	month, _ := strconv.Atoi(req.Form.Get("m"))
-
 	//process
 
 	rooms, err := m.DB.AllRooms()
@@ -727,10 +1194,12 @@ func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, req *h
 	}
 
 	form := forms.New(req.PostForm)
+	removedBlock := false
 
 	for _, x := range rooms {
 		//get the blockmap from session. loop through it. if we have an entry in map that is not in our posted data and restriction id >0, then we remove it
-		curMap := m.App.Session.Get(req.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
+		//a room added since the calendar was shown has no map, and so no blocks to remove
+		curMap, _ := m.App.Session.Get(req.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
 
 		for name, value := range curMap {
 			//ok will be false if value is not in map
@@ -739,10 +1208,22 @@ func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, req *h
 				if val > 0 {
 					if !form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, name), req) {
 						//delete the restriction by id
-						err := m.DB.DeleteBlockByID(value)
+						before, err := m.DB.GetBlockByID(value)
+						if errors.Is(err, sql.ErrNoRows) {
+							helpers.ClientError(w, http.StatusNotFound)
+							return
+						} else if err != nil {
+							helpers.ServerError(w, err)
+							return
+						}
+
+						err = m.DB.DeleteBlockByID(value)
 
 						if err != nil {
 							log.Println(err)
+						} else {
+							removedBlock = true
+							m.audit(req, models.AuditDelete, models.EntityBlock, value, before, nil)
 						}
 
 					}
@@ -756,18 +1237,35 @@ func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, req *h
 		if strings.HasPrefix(name, "add_block") {
 			exploded := strings.Split(name, "_")
 			roomID, _ := strconv.Atoi(exploded[2])
-			t, _ := time.Parse("2006-01-2", exploded[3])
-			//insert new block
+			t, err := time.Parse("2006-01-2", exploded[3])
+			if err != nil {
+				log.Println(err)
+				continue
+			}
 
-			err := m.DB.InsertBlockForRoom(roomID, t)
+			//insert new block
+			blockID, err := m.DB.InsertBlockForRoom(roomID, t)
 			if err != nil {
 				log.Println(err)
+				continue
 			}
+			m.audit(req, models.AuditCreate, models.EntityBlock, blockID, nil, models.RoomRestriction{
+				ID:            blockID,
+				RoomID:        roomID,
+				RestrictionID: models.RestrictionOwnerBlock,
+				StartDate:     t,
+				EndDate:       t.AddDate(0, 0, 1),
+			})
 
 		}
 	}
 
+	//only once the new blocks are in, so guests aren't offered a room that was blocked again
+	if removedBlock {
+		m.NotifyWaitlist()
+	}
+
 	m.App.Session.Put(req.Context(), "flash", "Changes Saved")
-	http.Redirect(w, req, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), http.StatusSeeOther)
+	http.Redirect(w, req, calendarLink(req.Form.Get("start"), req.Form.Get("weeks")), http.StatusSeeOther)
 
 }
@@ -37,11 +37,51 @@ var theTests = []struct {
 	{"login", "/user/login", "GET", http.StatusOK},
 	{"logout", "/user/logout", "GET", http.StatusOK},
 	{"dashboard", "/admin/dashboard", "GET", http.StatusOK},
+	{"dashboard range", "/admin/dashboard?start=2050-01-01&end=2050-02-01", "GET", http.StatusOK},
+	{"dashboard fails", "/admin/dashboard?start=2060-01-01&end=2060-02-01", "GET", http.StatusInternalServerError},
+	{"front desk", "/admin/today", "GET", http.StatusOK},
+	{"front desk day", "/admin/today?date=2050-01-10", "GET", http.StatusOK},
+	{"front desk fails", "/admin/today?date=2060-01-10", "GET", http.StatusInternalServerError},
+	{"audit log", "/admin/audit-log?entity=reservation&from=2050-01-01&to=2050-01-31", "GET", http.StatusOK},
+	{"audit log fails", "/admin/audit-log?action=error", "GET", http.StatusInternalServerError},
 	{"new res", "/admin/reservations-new", "GET", http.StatusOK},
 	{"all res", "/admin/reservations-all", "GET", http.StatusOK},
+	{"all res filtered", "/admin/reservations-all?q=smith&room_id=1&from=2050-01-01&to=2050-02-01&status=confirmed&sort=room&dir=desc&page=2", "GET", http.StatusOK},
+	{"all res bad filters", "/admin/reservations-all?room_id=one&from=2050-02-01&to=2050-01-01&page=-1", "GET", http.StatusOK},
+	{"all res search fails", "/admin/reservations-all?q=error", "GET", http.StatusInternalServerError},
 	{"show res", "/admin/reservations/new/1/show", "GET", http.StatusOK},
+	{"delete missing res", "/admin/delete-reservation/all/101/do", "GET", http.StatusNotFound},
 	{"show res cal", "/admin/reservations-calendar", "GET", http.StatusOK},
 	{"show res cal with params", "/admin/reservations-calendar?y=2020&m=1", "GET", http.StatusOK},
+	{"show res cal timeline", "/admin/reservations-calendar?start=2050-01-01&weeks=13", "GET", http.StatusOK},
+	{"api keys", "/admin/api-keys", "GET", http.StatusOK},
+	{"revoke api key", "/admin/revoke-api-key/1/do", "GET", http.StatusOK},
+	{"revoke missing api key", "/admin/revoke-api-key/101/do", "GET", http.StatusNotFound},
+	{"ical feeds", "/admin/ical", "GET", http.StatusOK},
+	{"regenerate room ical", "/admin/regenerate-room-ical/1/do", "GET", http.StatusOK},
+	{"regenerate staff ical", "/admin/regenerate-staff-ical/do", "GET", http.StatusOK},
+	{"room ical", "/ical/rooms/room-token.ics", "GET", http.StatusOK},
+	{"room ical bad token", "/ical/rooms/nope.ics", "GET", http.StatusNotFound},
+	{"staff ical", "/ical/staff/staff-token.ics", "GET", http.StatusOK},
+	{"staff ical bad token", "/ical/staff/room-token.ics", "GET", http.StatusNotFound},
+	{"sync ical feed", "/admin/sync-ical-feed/1/do", "GET", http.StatusOK},
+	{"sync missing ical feed", "/admin/sync-ical-feed/101/do", "GET", http.StatusNotFound},
+	{"delete ical feed", "/admin/delete-ical-feed/1/do", "GET", http.StatusOK},
+	{"rooms", "/admin/rooms", "GET", http.StatusOK},
+	{"taxes and fees", "/admin/taxes-fees", "GET", http.StatusOK},
+	{"delete charge", "/admin/delete-charge/1/do", "GET", http.StatusOK},
+	{"promo codes", "/admin/promo-codes", "GET", http.StatusOK},
+	{"waitlist", "/waitlist?start=2050-01-01&end=2050-01-02", "GET", http.StatusOK},
+	{"delete promo code", "/admin/delete-promo-code/1/do", "GET", http.StatusOK},
+	{"delete missing promo code", "/admin/delete-promo-code/101/do", "GET", http.StatusNotFound},
+	{"blocks", "/admin/blocks?room_id=1&start=2050-01-01", "GET", http.StatusOK},
+	{"show block", "/admin/blocks/1", "GET", http.StatusOK},
+	{"bulk blocks", "/admin/blocks/bulk", "GET", http.StatusOK},
+	{"add reservation", "/admin/add-reservation", "GET", http.StatusOK},
+	{"import reservations", "/admin/import-reservations", "GET", http.StatusOK},
+	{"show missing block", "/admin/blocks/101", "GET", http.StatusNotFound},
+	{"delete block", "/admin/delete-block/1/do", "GET", http.StatusOK},
+	{"delete missing block", "/admin/delete-block/101/do", "GET", http.StatusNotFound},
 }
 
 // TestHandlers tests all routes that don't require extra tests (gets)
@@ -163,7 +203,116 @@ var postReservationTests = []struct {
 		},
 		expectedResponseCode: http.StatusSeeOther,
 		expectedHTML:         "",
-		expectedLocation:     "/reservation-summary",
+		expectedLocation:     "/checkout",
+	},
+	{
+		name: "valid-promo-code",
+		postedData: url.Values{
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-02"},
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"room_id":    {"1"},
+			"promo_code": {"spring"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedHTML:         "",
+		expectedLocation:     "/checkout",
+	},
+	{
+		name: "hold-ran-out-room-free",
+		postedData: url.Values{
+			"start_date": {"2040-01-01"},
+			"end_date":   {"2040-01-02"},
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"room_id":    {"1"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedHTML:         "",
+		expectedLocation:     "/checkout",
+	},
+	{
+		name: "hold-ran-out-room-taken",
+		postedData: url.Values{
+			"start_date": {"2070-01-01"},
+			"end_date":   {"2070-01-02"},
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"room_id":    {"1"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedHTML:         "",
+		expectedLocation:     "/search-availability",
+	},
+	{
+		name: "hold-ran-out-search-fails",
+		postedData: url.Values{
+			"start_date": {"2060-01-01"},
+			"end_date":   {"2060-01-02"},
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"room_id":    {"1"},
+		},
+		expectedResponseCode: http.StatusTemporaryRedirect,
+		expectedHTML:         "",
+		expectedLocation:     "/",
+	},
+	{
+		name: "unknown-promo-code",
+		postedData: url.Values{
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-02"},
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"room_id":    {"1"},
+			"promo_code": {"NOPE"},
+		},
+		expectedResponseCode: http.StatusOK,
+		expectedHTML:         "",
+		expectedLocation:     "",
+	},
+	{
+		name: "used-up-promo-code",
+		postedData: url.Values{
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-02"},
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"room_id":    {"1"},
+			"promo_code": {"USEDUP"},
+		},
+		expectedResponseCode: http.StatusOK,
+		expectedHTML:         "",
+		expectedLocation:     "",
+	},
+	{
+		name: "promo-code-used-up-meanwhile",
+		postedData: url.Values{
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-02"},
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"room_id":    {"1"},
+			"promo_code": {"LASTONE"},
+		},
+		expectedResponseCode: http.StatusOK,
+		expectedHTML:         "",
+		expectedLocation:     "",
 	},
 	{
 		name:                 "missing-post-body",
@@ -318,10 +467,11 @@ func TestNewRepo(t *testing.T) {
 
 // testAvailabilityJSONData is data for the AvailabilityJSON handler, /search-availability-json route
 var testAvailabilityJSONData = []struct {
-	name            string
-	postedData      url.Values
-	expectedOK      bool
-	expectedMessage string
+	name                 string
+	postedData           url.Values
+	expectedOK           bool
+	expectedMessage      string
+	expectedAlternatives []string
 }{
 	{
 		name: "rooms not available",
@@ -331,6 +481,16 @@ var testAvailabilityJSONData = []struct {
 			"room_id": {"1"},
 		},
 		expectedOK: false,
+	}, {
+		name: "flexible dates",
+		postedData: url.Values{
+			"start":    {"2050-01-03"},
+			"end":      {"2050-01-05"},
+			"room_id":  {"1"},
+			"flexible": {"1"},
+		},
+		expectedOK:           false,
+		expectedAlternatives: []string{"2049-12-30", "2049-12-29", "2050-01-08"},
 	}, {
 		name: "rooms are available",
 		postedData: url.Values{
@@ -386,6 +546,14 @@ func TestAvailabilityJSON(t *testing.T) {
 		if j.OK != e.expectedOK {
 			t.Errorf("%s: expected %v but got %v", e.name, e.expectedOK, j.OK)
 		}
+
+		var starts []string
+		for _, a := range j.Alternatives {
+			starts = append(starts, a.StartDate)
+		}
+		if strings.Join(starts, " ") != strings.Join(e.expectedAlternatives, " ") {
+			t.Errorf("%s: expected alternatives starting %v but got %v", e.name, e.expectedAlternatives, starts)
+		}
 	}
 }
 
@@ -610,6 +778,16 @@ var bookRoomTests = []struct {
 		url:                "/book-room?s=2040-01-01&e=2040-01-02&id=4",
 		expectedStatusCode: http.StatusSeeOther,
 	},
+	{
+		name:               "room-already-held",
+		url:                "/book-room?s=2070-01-01&e=2070-01-02&id=1",
+		expectedStatusCode: http.StatusSeeOther,
+	},
+	{
+		name:               "hold-fails",
+		url:                "/book-room?s=2050-01-01&e=2050-01-02&id=1000",
+		expectedStatusCode: http.StatusInternalServerError,
+	},
 }
 
 // TestBookRoom tests the BookRoom handler
@@ -634,7 +812,7 @@ func TestBookRoom(t *testing.T) {
 
 		handler.ServeHTTP(rr, req)
 
-		if rr.Code != http.StatusSeeOther {
+		if rr.Code != e.expectedStatusCode {
 			t.Errorf("%s failed: returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedStatusCode)
 		}
 	}
@@ -763,6 +941,166 @@ var adminPostShowReservationTests = []struct {
 		expectedLocation:     "/admin/reservations-calendar?y=2022&m=01",
 		expectedHTML:         "",
 	},
+	{
+		name: "valid-data-from-timeline",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"start":      {"2050-01-01"},
+			"weeks":      {"8"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "/admin/reservations-calendar?start=2050-01-01&weeks=8",
+		expectedHTML:         "",
+	},
+	{
+		name: "move-stay",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name":   {"John"},
+			"last_name":    {"Smith"},
+			"email":        {"john@smith.com"},
+			"phone":        {"555-555-5555"},
+			"start_date":   {"2050-01-01"},
+			"end_date":     {"2050-01-05"},
+			"room_id":      {"1"},
+			"notify_guest": {"1"},
+			"start":        {"2050-01-01"},
+			"weeks":        {"4"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "/admin/reservations-calendar?start=2050-01-01&weeks=4",
+		expectedHTML:         "",
+	},
+	{
+		name: "move-stay-room-taken",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"start_date": {"2070-01-01"},
+			"end_date":   {"2070-01-05"},
+			"room_id":    {"2"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "/admin/reservations/cal/1/show",
+		expectedHTML:         "",
+	},
+	{
+		name: "move-stay-dates-reversed",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"start_date": {"2050-01-05"},
+			"end_date":   {"2050-01-01"},
+			"room_id":    {"1"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "/admin/reservations/cal/1/show",
+		expectedHTML:         "",
+	},
+	{
+		name: "move-stay-bad-date",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"start_date": {"01/01/2050"},
+			"end_date":   {"2050-01-05"},
+			"room_id":    {"1"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "/admin/reservations/cal/1/show",
+		expectedHTML:         "",
+	},
+	{
+		name: "move-stay-unknown-room",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-05"},
+			"room_id":    {"3"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "/admin/reservations/cal/1/show",
+		expectedHTML:         "",
+	},
+	{
+		name: "move-stay-room-lookup-fails",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-05"},
+			"room_id":    {"1000"},
+		},
+		expectedResponseCode: http.StatusInternalServerError,
+		expectedLocation:     "",
+		expectedHTML:         "",
+	},
+	{
+		name: "move-stay-checked-out",
+		url:  "/admin/reservations/cal/9/show",
+		postedData: url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-05"},
+			"room_id":    {"1"},
+		},
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "/admin/reservations/cal/9/show",
+		expectedHTML:         "",
+	},
+	{
+		name: "move-stay-fails",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {"555-555-5555"},
+			"start_date": {"2060-01-01"},
+			"end_date":   {"2060-01-05"},
+			"room_id":    {"1"},
+		},
+		expectedResponseCode: http.StatusInternalServerError,
+		expectedLocation:     "",
+		expectedHTML:         "",
+	},
+	{
+		name: "special-requests-too-long",
+		url:  "/admin/reservations/cal/1/show",
+		postedData: url.Values{
+			"first_name":       {"John"},
+			"last_name":        {"Smith"},
+			"email":            {"john@smith.com"},
+			"phone":            {"555-555-5555"},
+			"special_requests": {strings.Repeat("x", maxSpecialRequests+1)},
+		},
+		expectedResponseCode: http.StatusOK,
+		expectedLocation:     "",
+		expectedHTML:         "",
+	},
 }
 
 // TestAdminPostShowReservation tests the AdminPostReservation handler
@@ -911,6 +1249,12 @@ var adminProcessReservationTests = []struct {
 		expectedResponseCode: http.StatusSeeOther,
 		expectedLocation:     "",
 	},
+	{
+		name:                 "process-reservation-back-to-timeline",
+		queryParams:          "?start=2050-01-01&weeks=8",
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "",
+	},
 }
 
 func TestAdminProcessReservation(t *testing.T) {
@@ -948,6 +1292,12 @@ var adminDeleteReservationTests = []struct {
 		expectedResponseCode: http.StatusSeeOther,
 		expectedLocation:     "",
 	},
+	{
+		name:                 "delete-reservation-back-to-timeline",
+		queryParams:          "?start=2050-01-01&weeks=8",
+		expectedResponseCode: http.StatusSeeOther,
+		expectedLocation:     "",
+	},
 }
 
 func TestAdminDeleteReservation(t *testing.T) {
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/ical"
+	"BookingProject/pkg/icalsync"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+const icalProdID = "-//Bookings//Room Occupancy//EN"
+
+// feeds cover a little history, so calendars don't drop stays that just ended, and two years ahead
+func icalRange() (time.Time, time.Time) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return today.AddDate(0, -3, 0), today.AddDate(2, 0, 0)
+}
+
+// icalEvent turns a restriction into an all day event. Restrictions end on the departure day,
+// which is already the exclusive DTEND, but an event must still last at least one day
+func icalEvent(rs models.RoomRestriction, summary, description string) ical.Event {
+	ev := ical.Event{
+		Summary:     summary,
+		Description: description,
+		Start:       rs.StartDate,
+		End:         rs.EndDate,
+	}
+
+	if !ev.End.After(ev.Start) {
+		ev.End = ev.Start.AddDate(0, 0, 1)
+	}
+
+	if rs.ReservationID > 0 {
+		ev.UID = fmt.Sprintf("reservation-%d@bookings", rs.ReservationID)
+	} else {
+		ev.UID = fmt.Sprintf("block-%d@bookings", rs.ID)
+	}
+
+	return ev
+}
+
+func (m *Repository) writeICal(w http.ResponseWriter, c ical.Calendar) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	err := ical.Encode(w, c)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+}
+
+// feed token from the url, without the .ics on the end
+func icalToken(req *http.Request) string {
+	return strings.TrimSuffix(chi.URLParam(req, "token"), ".ics")
+}
+
+// RoomICalFeed serves the secret feed for one room. It is handed to OTAs, so it carries no guest details
+func (m *Repository) RoomICalFeed(w http.ResponseWriter, req *http.Request) {
+	room, err := m.DB.GetRoomByICalToken(icalToken(req))
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	start, end := icalRange()
+	restrictions, err := m.DB.GetRestrictionsForRoomByDate(room.ID, start, end)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	c := ical.Calendar{ProdID: icalProdID, Name: room.RoomName}
+	for _, rs := range restrictions {
+		summary := "Blocked"
+		if rs.ReservationID > 0 {
+			summary = "Reserved"
+		}
+		c.Events = append(c.Events, icalEvent(rs, summary, ""))
+	}
+
+	m.writeICal(w, c)
+}
+
+// StaffICalFeed serves every room in one feed, with guest names, for a staff member's own calendar
+func (m *Repository) StaffICalFeed(w http.ResponseWriter, req *http.Request) {
+	_, err := m.DB.GetUserByICalToken(icalToken(req))
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	start, end := icalRange()
+
+	restrictions, err := m.DB.GetRestrictionsForCalendar(start, end)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	byRoom := make(map[int][]models.RoomRestriction)
+	for _, rs := range restrictions {
+		byRoom[rs.RoomID] = append(byRoom[rs.RoomID], rs)
+	}
+
+	c := ical.Calendar{ProdID: icalProdID, Name: "All Rooms"}
+	for _, room := range rooms {
+		for _, rs := range byRoom[room.ID] {
+			summary := fmt.Sprintf("%s: Blocked", room.RoomName)
+			description := ""
+			switch {
+			case rs.ReservationID > 0:
+				summary = fmt.Sprintf("%s: %s %s", room.RoomName, rs.Reservation.FirstName, rs.Reservation.LastName)
+				description = fmt.Sprintf("Reservation %d", rs.ReservationID)
+			case rs.RestrictionID == models.RestrictionExternal:
+				summary = fmt.Sprintf("%s: External Booking", room.RoomName)
+			}
+			c.Events = append(c.Events, icalEvent(rs, summary, description))
+		}
+	}
+
+	m.writeICal(w, c)
+}
+
+// AdminICal shows the feed urls for each room and the staff feed of the logged in user,
+// and the feeds imported from other sites
+func (m *Repository) AdminICal(w http.ResponseWriter, req *http.Request) {
+	m.renderAdminICal(w, req, forms.New(nil))
+}
+
+func (m *Repository) renderAdminICal(w http.ResponseWriter, req *http.Request, form *forms.Form) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	feeds, err := m.DB.AllICalFeeds()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user, err := m.DB.GetUserByID(m.App.Session.GetInt(req.Context(), "user_id"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+	data["feeds"] = feeds
+
+	stringMap := make(map[string]string)
+	stringMap["base_url"] = m.baseURL()
+	stringMap["staff_token"] = user.ICalToken
+
+	render.Template(w, req, "admin-ical.page.html", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		Form:      form,
+	})
+}
+
+// AdminPostICalFeed adds a feed to import bookings from, and syncs it straight away
+func (m *Repository) AdminPostICalFeed(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	form.Required("room_id", "name", "url")
+	form.IsURL("url")
+
+	roomID, err := strconv.Atoi(form.Get("room_id"))
+	if err != nil {
+		form.Errors.Add("room_id", "Pick a room")
+	}
+
+	if !form.Valid() {
+		m.renderAdminICal(w, req, form)
+		return
+	}
+
+	feed := models.ICalFeed{
+		RoomID: roomID,
+		Name:   form.Get("name"),
+		URL:    form.Get("url"),
+	}
+
+	feed.ID, err = m.DB.InsertICalFeed(feed)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditCreate, models.EntityICalFeed, feed.ID, nil, feed)
+
+	m.syncICalFeed(req, feed)
+	http.Redirect(w, req, "/admin/ical", http.StatusSeeOther)
+}
+
+// AdminSyncICalFeed imports a feed now rather than waiting for the importer
+func (m *Repository) AdminSyncICalFeed(w http.ResponseWriter, req *http.Request) {
+	feed, ok := m.icalFeedFromURL(w, req)
+	if !ok {
+		return
+	}
+
+	m.syncICalFeed(req, feed)
+	http.Redirect(w, req, "/admin/ical", http.StatusSeeOther)
+}
+
+// AdminDeleteICalFeed stops importing a feed and removes the bookings it brought in
+func (m *Repository) AdminDeleteICalFeed(w http.ResponseWriter, req *http.Request) {
+	feed, ok := m.icalFeedFromURL(w, req)
+	if !ok {
+		return
+	}
+
+	err := m.DB.DeleteICalFeed(feed.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditDelete, models.EntityICalFeed, feed.ID, feed, nil)
+
+	m.App.Session.Put(req.Context(), "flash", "Feed deleted")
+	http.Redirect(w, req, "/admin/ical", http.StatusSeeOther)
+}
+
+func (m *Repository) icalFeedFromURL(w http.ResponseWriter, req *http.Request) (models.ICalFeed, bool) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return models.ICalFeed{}, false
+	}
+
+	feed, err := m.DB.GetICalFeedByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return feed, false
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return feed, false
+	}
+
+	return feed, true
+}
+
+// syncICalFeed imports a feed, putting the outcome in the session for the next page
+func (m *Repository) syncICalFeed(req *http.Request, feed models.ICalFeed) {
+	s := icalsync.NewSyncer(m.App, m.DB)
+	s.Freed = m.NotifyWaitlist
+	res, err := s.Sync(feed)
+	if err != nil {
+		m.App.Session.Put(req.Context(), "error", fmt.Sprintf("Couldn't import %s: %s", feed.Name, err))
+		return
+	}
+
+	m.audit(req, models.AuditSync, models.EntityICalFeed, feed.ID, nil, res)
+	m.App.Session.Put(req.Context(), "flash",
+		fmt.Sprintf("Imported %s: %d new, %d moved, %d removed", feed.Name, res.Created, res.Updated, res.Removed))
+}
+
+// AdminRegenerateRoomICal gives a room a new feed token, so the old url stops working
+func (m *Repository) AdminRegenerateRoomICal(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	token, err := helpers.RandomToken(16)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = m.DB.UpdateRoomICalToken(id, token)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditNewToken, models.EntityRoom, id, nil, nil)
+
+	m.App.Session.Put(req.Context(), "flash", "Room feed url changed")
+	http.Redirect(w, req, "/admin/ical", http.StatusSeeOther)
+}
+
+// AdminRegenerateStaffICal gives the logged in user a new staff feed token
+func (m *Repository) AdminRegenerateStaffICal(w http.ResponseWriter, req *http.Request) {
+	token, err := helpers.RandomToken(16)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	userID := m.App.Session.GetInt(req.Context(), "user_id")
+	err = m.DB.UpdateUserICalToken(userID, token)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditNewToken, models.EntityUser, userID, nil, nil)
+
+	m.App.Session.Put(req.Context(), "flash", "Staff feed url changed")
+	http.Redirect(w, req, "/admin/ical", http.StatusSeeOther)
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the biggest file and the most rows an import takes at once
+const (
+	maxImportBytes = 1 << 20
+	maxImportRows  = 2000
+)
+
+// importColumns are the columns an import reads, by the heading on its first line. The rest can be left
+// out, and status defaults to confirmed
+var importColumns = []string{"first_name", "last_name", "email", "phone", "room", "start_date", "end_date", "status"}
+
+// importRow is a line of an import, read as a reservation, with whatever is wrong with it
+type importRow struct {
+	Line        int
+	Reservation models.Reservation
+	Errors      []string
+}
+
+// importReport is what an import will do, or would have done
+type importReport struct {
+	Rows    []importRow
+	Valid   int
+	Invalid int
+}
+
+// AdminImportReservations shows the form to import reservations from a csv file
+func (m *Repository) AdminImportReservations(w http.ResponseWriter, req *http.Request) {
+	m.renderAdminImport(w, req, forms.New(url.Values{}), nil)
+}
+
+func (m *Repository) renderAdminImport(w http.ResponseWriter, req *http.Request, form *forms.Form, report *importReport) {
+	data := make(map[string]interface{})
+	data["columns"] = strings.Join(importColumns, ",")
+	if report != nil {
+		data["report"] = report
+	}
+
+	render.Template(w, req, "admin-import.page.html", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminPostImportReservations checks an uploaded csv of reservations line by line and reports what's
+// wrong with each, without importing anything. Once step is commit it imports the lines that are
+// fine, all together, with the csv carried over from the report
+func (m *Repository) AdminPostImportReservations(w http.ResponseWriter, req *http.Request) {
+	req.Body = http.MaxBytesReader(w, req.Body, maxImportBytes+4096)
+	err := req.ParseMultipartForm(maxImportBytes)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		form := forms.New(url.Values{})
+		form.Errors.Add("file", "Upload a csv file under 1MB")
+		m.renderAdminImport(w, req, form, nil)
+		return
+	}
+	if err != nil {
+		err = req.ParseForm()
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	form := forms.New(req.PostForm)
+
+	content := form.Get("csv")
+	if file, _, err := req.FormFile("file"); err == nil {
+		b, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		content = string(b)
+		form.Set("csv", content)
+	}
+
+	if strings.TrimSpace(content) == "" {
+		form.Errors.Add("file", "Choose a csv file to import")
+		m.renderAdminImport(w, req, form, nil)
+		return
+	}
+
+	rows, problem := readImport(strings.NewReader(content))
+	if problem != "" {
+		form.Errors.Add("file", problem)
+		m.renderAdminImport(w, req, form, nil)
+		return
+	}
+
+	report, err := m.checkImport(rows)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if form.Get("step") != "commit" || report.Valid == 0 {
+		m.renderAdminImport(w, req, form, &report)
+		return
+	}
+
+	var valid []models.Reservation
+	var lines []int
+	for i := range report.Rows {
+		if len(report.Rows[i].Errors) == 0 {
+			valid = append(valid, report.Rows[i].Reservation)
+			lines = append(lines, i)
+		}
+	}
+
+	n, err := m.DB.ImportReservations(valid)
+	if errors.Is(err, sql.ErrNoRows) {
+		//nothing went in, so the report stands with that line pointed out
+		row := &report.Rows[lines[n]]
+		row.Errors = append(row.Errors, "The room was booked since this was checked")
+		report.Valid--
+		report.Invalid++
+		m.renderAdminImport(w, req, form, &report)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.audit(req, models.AuditImport, models.EntityReservation, 0, nil, map[string]int{"Imported": n, "Skipped": report.Invalid})
+
+	msg := fmt.Sprintf("%d reservations imported", n)
+	if report.Invalid > 0 {
+		msg += fmt.Sprintf(", %d lines skipped", report.Invalid)
+	}
+	m.App.Session.Put(req.Context(), "flash", msg)
+	http.Redirect(w, req, "/admin/reservations-all?source="+models.SourceImport, http.StatusSeeOther)
+}
+
+// readImport reads the lines of an import csv into the values of each, by the headings on the first
+// line. If the file can't be read, it says why in words fit to show
+func readImport(r io.Reader) ([]url.Values, string) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, "The file is empty"
+	} else if err != nil {
+		return nil, "The file isn't a csv file"
+	}
+
+	columns := make(map[int]string)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		for _, c := range importColumns {
+			if h == c {
+				columns[i] = c
+			}
+		}
+	}
+	for _, c := range []string{"first_name", "last_name", "room", "start_date", "end_date"} {
+		found := false
+		for _, h := range columns {
+			found = found || h == c
+		}
+		if !found {
+			return nil, fmt.Sprintf("The first line needs a %s heading", c)
+		}
+	}
+
+	var rows []url.Values
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Sprintf("Line %d can't be read as csv", len(rows)+2)
+		}
+
+		if len(rows) == maxImportRows {
+			return nil, fmt.Sprintf("Import no more than %d reservations at once", maxImportRows)
+		}
+
+		values := url.Values{}
+		for i, v := range record {
+			if c, ok := columns[i]; ok {
+				values.Set(c, strings.TrimSpace(v))
+			}
+		}
+		rows = append(rows, values)
+	}
+
+	if len(rows) == 0 {
+		return nil, "The file has no reservations in it"
+	}
+
+	return rows, ""
+}
+
+// checkImport reads each line of an import as a reservation, checking it the way the reservation
+// forms do, and that its room is free. Rooms can be given by id or name
+func (m *Repository) checkImport(rows []url.Values) (importReport, error) {
+	var report importReport
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		return report, err
+	}
+
+	var first, last time.Time
+	for i, values := range rows {
+		row := importRow{Line: i + 2}
+		form := forms.New(values)
+		form.Required("first_name", "last_name", "room", "start_date", "end_date")
+		if form.Get("email") != "" {
+			form.IsEmail("email")
+		}
+
+		res := models.Reservation{
+			FirstName: form.Get("first_name"),
+			LastName:  form.Get("last_name"),
+			Email:     form.Get("email"),
+			Phone:     form.Get("phone"),
+			StartDate: form.OptionalDate("start_date"),
+			EndDate:   form.OptionalDate("end_date"),
+			Status:    strings.ToLower(form.Get("status")),
+			Source:    models.SourceImport,
+			Processed: 1,
+		}
+
+		if res.Status == "" {
+			res.Status = models.ReservationConfirmed
+		}
+		if res.Status != models.ReservationConfirmed && res.Status != models.ReservationPending && res.Status != models.ReservationCancelled {
+			form.Errors.Add("status", "Use confirmed, pending or cancelled")
+		}
+
+		if !res.StartDate.IsZero() && !res.EndDate.IsZero() && !res.EndDate.After(res.StartDate) {
+			form.Errors.Add("end_date", "The departure has to be after the arrival")
+		}
+
+		if room := form.Get("room"); room != "" {
+			res.Room, err = m.importRoom(rooms, room)
+			if err != nil {
+				form.Errors.Add("room", "There's no room "+room)
+			}
+			res.RoomID = res.Room.ID
+		}
+
+		row.Reservation = res
+		row.Errors = importErrors(form)
+		report.Rows = append(report.Rows, row)
+
+		if len(row.Errors) == 0 {
+			if first.IsZero() || res.StartDate.Before(first) {
+				first = res.StartDate
+			}
+			if res.EndDate.After(last) {
+				last = res.EndDate
+			}
+		}
+	}
+
+	var taken []models.RoomRestriction
+	if !first.IsZero() {
+		taken, err = m.DB.GetRestrictionsForCalendar(first, last)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	//a line can clash with what's booked already, or with an earlier line of the import
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		res := row.Reservation
+		if len(row.Errors) > 0 || res.Status == models.ReservationCancelled {
+			continue
+		}
+
+		for _, r := range taken {
+			if r.RoomID == res.RoomID && res.StartDate.Before(r.EndDate) && res.EndDate.After(r.StartDate) {
+				row.Errors = append(row.Errors, fmt.Sprintf("%s is taken from %s to %s",
+					res.Room.RoomName, r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02")))
+				break
+			}
+		}
+
+		for _, earlier := range report.Rows[:i] {
+			o := earlier.Reservation
+			if len(earlier.Errors) == 0 && o.Status != models.ReservationCancelled && o.RoomID == res.RoomID &&
+				res.StartDate.Before(o.EndDate) && res.EndDate.After(o.StartDate) {
+				row.Errors = append(row.Errors, fmt.Sprintf("Overlaps line %d", earlier.Line))
+				break
+			}
+		}
+	}
+
+	for _, row := range report.Rows {
+		if len(row.Errors) == 0 {
+			report.Valid++
+		} else {
+			report.Invalid++
+		}
+	}
+
+	return report, nil
+}
+
+// importRoom finds the room a line of an import names, by id or by name
+func (m *Repository) importRoom(rooms []models.Room, room string) (models.Room, error) {
+	id, err := strconv.Atoi(room)
+	for _, r := range rooms {
+		if (err == nil && r.ID == id) || strings.EqualFold(r.RoomName, room) {
+			return r, nil
+		}
+	}
+
+	if err != nil {
+		return models.Room{}, sql.ErrNoRows
+	}
+
+	r, err := m.DB.GetRoomByID(id)
+	r.ID = id
+	return r, err
+}
+
+// importErrors lists what the form found wrong with a line, column by column
+func importErrors(form *forms.Form) []string {
+	var errs []string
+	for _, c := range importColumns {
+		for _, msg := range form.Errors[c] {
+			errs = append(errs, c+": "+msg)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReadImport(t *testing.T) {
+	var tests = []struct {
+		name    string
+		csv     string
+		rows    int
+		problem bool
+	}{
+		{"two lines", "first_name,last_name,room,start_date,end_date\nJohn,Smith,1,2050-01-01,2050-01-03\nJane,Doe,2,2050-01-01,2050-01-02\n", 2, false},
+		{"headings any case with a byte order mark", "\ufeffFirst_Name, Last_Name,ROOM,start_date,end_date,notes\nJohn,Smith,1,2050-01-01,2050-01-03,late\n", 1, false},
+		{"short line", "first_name,last_name,room,start_date,end_date\nJohn,Smith\n", 1, false},
+		{"empty", "", 0, true},
+		{"only headings", "first_name,last_name,room,start_date,end_date\n", 0, true},
+		{"missing heading", "first_name,last_name,start_date,end_date\nJohn,Smith,2050-01-01,2050-01-03\n", 0, true},
+		{"bad quoting", "first_name,last_name,room,start_date,end_date\n\"John,Smith,1,2050-01-01,2050-01-03\n", 0, true},
+	}
+
+	for _, e := range tests {
+		rows, problem := readImport(strings.NewReader(e.csv))
+		if (problem != "") != e.problem {
+			t.Errorf("%s: unexpected problem %q", e.name, problem)
+		}
+		if len(rows) != e.rows {
+			t.Errorf("%s: expected %d rows, got %d", e.name, e.rows, len(rows))
+		}
+	}
+
+	rows, _ := readImport(strings.NewReader("\ufeffFirst_Name, Last_Name,ROOM,start_date,end_date,notes\nJohn,Smith,1,2050-01-01,2050-01-03,late\n"))
+	if rows[0].Get("first_name") != "John" || rows[0].Get("last_name") != "Smith" || rows[0].Has("notes") {
+		t.Errorf("unexpected values %v", rows[0])
+	}
+}
+
+func TestCheckImport(t *testing.T) {
+	line := func(first, room, start, end, status string) url.Values {
+		return url.Values{"first_name": {first}, "last_name": {"Smith"}, "room": {room}, "start_date": {start}, "end_date": {end}, "status": {status}}
+	}
+
+	report, err := Repo.checkImport([]url.Values{
+		line("John", "1", "2050-01-01", "2050-01-05", ""),
+		line("Jane", "1", "2050-01-04", "2050-01-06", ""),
+		line("Jim", "1", "2050-01-04", "2050-01-06", "cancelled"),
+		line("Joe", "2", "2050-01-04", "2050-01-06", "Pending"),
+		line("Jill", "3", "2050-01-04", "2050-01-06", ""),
+		line("Jack", "2", "2050-01-06", "2050-01-04", ""),
+		line("", "2", "01/01/2050", "2050-01-04", "booked"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := []int{0, 1, 0, 0, 1, 1, 3}
+	for i, row := range report.Rows {
+		if len(row.Errors) != errs[i] {
+			t.Errorf("line %d: expected %d problems, got %v", row.Line, errs[i], row.Errors)
+		}
+	}
+
+	if report.Valid != 3 || report.Invalid != 4 {
+		t.Errorf("expected 3 valid and 4 invalid, got %d and %d", report.Valid, report.Invalid)
+	}
+	if report.Rows[1].Errors[0] != "Overlaps line 2" {
+		t.Errorf("unexpected problem %s", report.Rows[1].Errors[0])
+	}
+	if r := report.Rows[3].Reservation; r.Status != "pending" || r.Source != "import" || r.Processed != 1 || r.RoomID != 2 {
+		t.Errorf("unexpected reservation %+v", r)
+	}
+}
+
+func TestAdminPostImportReservations(t *testing.T) {
+	const heading = "first_name,last_name,email,room,start_date,end_date\n"
+
+	var tests = []struct {
+		name             string
+		csv              string
+		upload           bool
+		step             string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"check upload", heading + "John,Smith,john@smith.com,1,2050-01-01,2050-01-03\n", true, "check", http.StatusOK, ""},
+		{"import", heading + "John,Smith,john@smith.com,1,2050-01-01,2050-01-03\nJane,Doe,jane,1,2050-01-01,2050-01-03\n", false, "commit", http.StatusSeeOther, "/admin/reservations-all?source=import"},
+		{"import uploaded", heading + "John,Smith,,2,2050-01-01,2050-01-03\n", true, "commit", http.StatusSeeOther, "/admin/reservations-all?source=import"},
+		{"nothing valid", heading + "John,Smith,john,1,2050-01-01,2050-01-03\n", false, "commit", http.StatusOK, ""},
+		{"booked since the check", heading + "John,Smith,,1,2070-01-01,2070-01-03\n", false, "commit", http.StatusOK, ""},
+		{"database error", heading + "John,Smith,,1,2060-01-01,2060-01-03\n", false, "commit", http.StatusInternalServerError, ""},
+		{"no file", "", false, "check", http.StatusOK, ""},
+		{"not csv", "first_name\n", true, "check", http.StatusOK, ""},
+	}
+
+	for _, e := range tests {
+		var body bytes.Buffer
+		contentType := "application/x-www-form-urlencoded"
+
+		if e.upload {
+			mw := multipart.NewWriter(&body)
+			_ = mw.WriteField("step", e.step)
+			fw, _ := mw.CreateFormFile("file", "reservations.csv")
+			_, _ = fw.Write([]byte(e.csv))
+			_ = mw.Close()
+			contentType = mw.FormDataContentType()
+		} else {
+			body.WriteString(url.Values{"csv": {e.csv}, "step": {e.step}}.Encode())
+		}
+
+		req, _ := http.NewRequest("POST", "/admin/import-reservations", &body)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", contentType)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostImportReservations)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedCode)
+		}
+
+		if e.expectedLocation != "" {
+			location, _ := rr.Result().Location()
+			if location.String() != e.expectedLocation {
+				t.Errorf("%s: expected location %s, got %s", e.name, e.expectedLocation, location.String())
+			}
+		}
+	}
+}
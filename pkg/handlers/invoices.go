@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/invoices"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// errNoInvoice is returned for reservations that haven't been confirmed, and so can't be invoiced yet
+var errNoInvoice = errors.New("reservation has no invoice until it is confirmed")
+
+// loadInvoice gets the invoice for a reservation along with its payments, issuing it first if the
+// reservation is confirmed but was never invoiced
+func (m *Repository) loadInvoice(reservationID int) (models.Invoice, error) {
+	res, err := m.DB.GetReservationByID(reservationID)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+
+	inv, err := m.DB.GetInvoiceForReservation(reservationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if !res.Confirmed() {
+			return inv, errNoInvoice
+		}
+
+		quote, err := m.quote(res)
+		if err != nil {
+			return inv, err
+		}
+
+		err = m.DB.InsertInvoice(invoices.FromQuote(res.ID, quote))
+		if err != nil {
+			return inv, err
+		}
+
+		inv, err = m.DB.GetInvoiceForReservation(reservationID)
+	}
+	if err != nil {
+		return inv, err
+	}
+
+	inv.Reservation = res
+	inv.Payments, err = m.DB.GetPaymentsForReservation(reservationID)
+	if err != nil {
+		return inv, err
+	}
+
+	return inv, nil
+}
+
+// priceBreakdown is the invoice of a confirmed reservation, or for one that isn't confirmed yet,
+// what its invoice would say if it were issued now
+func (m *Repository) priceBreakdown(res models.Reservation) (models.Invoice, error) {
+	if res.Confirmed() {
+		return m.loadInvoice(res.ID)
+	}
+
+	quote, err := m.quote(res)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+
+	inv := invoices.FromQuote(res.ID, quote)
+	inv.Reservation = res
+	inv.Payments, err = m.DB.GetPaymentsForReservation(res.ID)
+	if err != nil {
+		return inv, err
+	}
+
+	return inv, nil
+}
+
+// guestLink is a signed link to the guest's own reservation pages, so guests don't need an account
+func (m *Repository) guestLink(reservationID int, page string) string {
+	return fmt.Sprintf("%s/reservations/%d%s?sig=%s", m.baseURL(), reservationID, page, helpers.Sign(signedReservation(reservationID)))
+}
+
+// signedReservation is what a guest link signs; the prefix keeps it from passing for other signed ids
+func signedReservation(reservationID int) string {
+	return fmt.Sprintf("reservation:%d", reservationID)
+}
+
+// guestReservationID checks the signature on a guest link. A bad one looks the same as a missing reservation
+func guestReservationID(w http.ResponseWriter, req *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil || !helpers.ValidSignature(signedReservation(id), req.URL.Query().Get("sig")) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (m *Repository) renderInvoice(w http.ResponseWriter, req *http.Request, inv models.Invoice, pdfURL, backURL string) {
+	data := make(map[string]interface{})
+	data["invoice"] = inv
+
+	stringMap := make(map[string]string)
+	stringMap["issuer"] = invoices.Issuer
+	stringMap["pdf_url"] = pdfURL
+	stringMap["back_url"] = backURL
+
+	render.Template(w, req, "invoice.page.html", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+func (m *Repository) writeInvoicePDF(w http.ResponseWriter, inv models.Invoice) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, invoices.FileName(inv)))
+
+	_, err := w.Write(invoices.PDF(inv))
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+}
+
+// GuestReservation is the guest's view of their reservation, reached from the link in their confirmation email
+func (m *Repository) GuestReservation(w http.ResponseWriter, req *http.Request) {
+	id, ok := guestReservationID(w, req)
+	if !ok {
+		return
+	}
+
+	res, err := m.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservation"] = res
+
+	inv, err := m.loadInvoice(id)
+	if err == nil {
+		data["invoice"] = inv
+	} else if !errors.Is(err, errNoInvoice) {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["invoice_url"] = m.guestLink(id, "/invoice")
+	stringMap["pdf_url"] = m.guestLink(id, "/invoice.pdf")
+
+	render.Template(w, req, "guest-reservation.page.html", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// guestInvoice loads the invoice behind a guest link
+func (m *Repository) guestInvoice(w http.ResponseWriter, req *http.Request) (models.Invoice, bool) {
+	id, ok := guestReservationID(w, req)
+	if !ok {
+		return models.Invoice{}, false
+	}
+
+	inv, err := m.loadInvoice(id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errNoInvoice) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return inv, false
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return inv, false
+	}
+
+	return inv, true
+}
+
+// GuestInvoice shows a guest the invoice for their reservation
+func (m *Repository) GuestInvoice(w http.ResponseWriter, req *http.Request) {
+	inv, ok := m.guestInvoice(w, req)
+	if !ok {
+		return
+	}
+
+	m.renderInvoice(w, req, inv, m.guestLink(inv.ReservationID, "/invoice.pdf"), m.guestLink(inv.ReservationID, ""))
+}
+
+// GuestInvoicePDF downloads the invoice for a guest's reservation
+func (m *Repository) GuestInvoicePDF(w http.ResponseWriter, req *http.Request) {
+	inv, ok := m.guestInvoice(w, req)
+	if !ok {
+		return
+	}
+
+	m.writeInvoicePDF(w, inv)
+}
+
+// adminInvoice loads the invoice for the reservation in an admin url
+func (m *Repository) adminInvoice(w http.ResponseWriter, req *http.Request) (models.Invoice, bool) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return models.Invoice{}, false
+	}
+
+	inv, err := m.loadInvoice(id)
+	if errors.Is(err, errNoInvoice) {
+		m.App.Session.Put(req.Context(), "error", "The reservation isn't confirmed, so it has no invoice yet")
+		http.Redirect(w, req, fmt.Sprintf("/admin/reservations/%s/%d/show", chi.URLParam(req, "src"), id), http.StatusSeeOther)
+		return inv, false
+	} else if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return inv, false
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return inv, false
+	}
+
+	return inv, true
+}
+
+// AdminInvoice shows the invoice for a reservation
+func (m *Repository) AdminInvoice(w http.ResponseWriter, req *http.Request) {
+	inv, ok := m.adminInvoice(w, req)
+	if !ok {
+		return
+	}
+
+	show := fmt.Sprintf("/admin/reservations/%s/%d", chi.URLParam(req, "src"), inv.ReservationID)
+	m.renderInvoice(w, req, inv, show+"/invoice.pdf", show+"/show")
+}
+
+// AdminInvoicePDF downloads the invoice for a reservation
+func (m *Repository) AdminInvoicePDF(w http.ResponseWriter, req *http.Request) {
+	inv, ok := m.adminInvoice(w, req)
+	if !ok {
+		return
+	}
+
+	m.writeInvoicePDF(w, inv)
+}
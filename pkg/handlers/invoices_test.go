@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"BookingProject/pkg/helpers"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInvoices(t *testing.T) {
+	sig1 := helpers.Sign(signedReservation(1))
+	sig101 := helpers.Sign(signedReservation(101))
+
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedType       string
+	}{
+		{"guest reservation", "/reservations/1?sig=" + sig1, http.StatusOK, ""},
+		{"guest invoice", "/reservations/1/invoice?sig=" + sig1, http.StatusOK, ""},
+		{"guest pdf", "/reservations/1/invoice.pdf?sig=" + sig1, http.StatusOK, "application/pdf"},
+		{"no signature", "/reservations/1/invoice.pdf", http.StatusNotFound, ""},
+		{"signature for another reservation", "/reservations/2/invoice.pdf?sig=" + sig1, http.StatusNotFound, ""},
+		{"missing reservation", "/reservations/101/invoice.pdf?sig=" + sig101, http.StatusNotFound, ""},
+		{"admin invoice", "/admin/reservations/all/1/invoice", http.StatusOK, ""},
+		{"admin pdf", "/admin/reservations/all/1/invoice.pdf", http.StatusOK, "application/pdf"},
+		{"admin missing reservation", "/admin/reservations/all/101/invoice.pdf", http.StatusNotFound, ""},
+	}
+
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	for _, e := range tests {
+		resp, err := ts.Client().Get(ts.URL + e.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedStatusCode)
+		}
+
+		if e.expectedType != "" {
+			if resp.Header.Get("Content-Type") != e.expectedType {
+				t.Errorf("%s returned content type %s, wanted %s", e.name, resp.Header.Get("Content-Type"), e.expectedType)
+			}
+			if !strings.HasPrefix(string(body), "%PDF-") {
+				t.Errorf("%s didn't return a pdf", e.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// the longest special requests a guest can make, and the longest note staff can leave
+const (
+	maxSpecialRequests = 1000
+	maxNoteLength      = 2000
+)
+
+// checkSpecialRequests reads what a guest asked for on a reservation form, adding an error to the form
+// if it's too long
+func checkSpecialRequests(form *forms.Form) string {
+	requests := strings.TrimSpace(form.Get("special_requests"))
+	if len(requests) > maxSpecialRequests {
+		form.Errors.Add("special_requests", fmt.Sprintf("Keep special requests under %d characters", maxSpecialRequests))
+	}
+	return requests
+}
+
+// AdminPostReservationNote leaves a staff note on a reservation, or a reply to one of its notes when
+// parent_id is given
+func (m *Repository) AdminPostReservationNote(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	src := chi.URLParam(req, "src")
+
+	_, err = m.DB.GetReservationByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	note := models.ReservationNote{
+		ReservationID: id,
+		ParentID:      form.OptionalCount("parent_id"),
+		UserID:        m.App.Session.GetInt(req.Context(), "user_id"),
+		Body:          strings.TrimSpace(form.Get("body")),
+	}
+
+	back := fmt.Sprintf("/admin/reservations/%s/%d/show#notes", src, id)
+
+	problem := ""
+	if note.Body == "" {
+		problem = "Write something to leave a note"
+	} else if len(note.Body) > maxNoteLength {
+		problem = fmt.Sprintf("Keep notes under %d characters", maxNoteLength)
+	}
+
+	if problem == "" && note.ParentID > 0 {
+		notes, err := m.DB.NotesForReservation(id)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		note.ParentID, problem = replyParent(notes, note.ParentID)
+	}
+
+	if problem != "" {
+		m.App.Session.Put(req.Context(), "error", problem)
+		http.Redirect(w, req, back, http.StatusSeeOther)
+		return
+	}
+
+	note.ID, err = m.DB.InsertReservationNote(note)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditCreate, models.EntityNote, note.ID, nil, note)
+
+	m.App.Session.Put(req.Context(), "flash", "Note added")
+	http.Redirect(w, req, back, http.StatusSeeOther)
+}
+
+// replyParent is the note a reply to parentID goes under. Threads are one deep, so replying to a reply
+// adds to the thread it's in. The parent has to be one of the reservation's notes
+func replyParent(notes []models.ReservationNote, parentID int) (int, string) {
+	for _, n := range notes {
+		if n.ID == parentID {
+			if n.ParentID > 0 {
+				return n.ParentID, ""
+			}
+			return n.ID, ""
+		}
+	}
+	return 0, "That note isn't on this reservation"
+}
+
+// threadNotes puts each reply under the note it answers, keeping them in the order they were written.
+// Replies to notes that aren't there are shown as notes of their own
+func threadNotes(notes []models.ReservationNote) []models.ReservationNote {
+	index := make(map[int]int)
+	var threads []models.ReservationNote
+
+	for _, n := range notes {
+		if i, ok := index[n.ParentID]; ok && n.ParentID > 0 {
+			threads[i].Replies = append(threads[i].Replies, n)
+			continue
+		}
+		index[n.ID] = len(threads)
+		threads = append(threads, n)
+	}
+
+	return threads
+}
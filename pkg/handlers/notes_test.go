@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"BookingProject/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestThreadNotes(t *testing.T) {
+	notes := []models.ReservationNote{
+		{ID: 1, Body: "Late arrival"},
+		{ID: 2, Body: "Allergic to feathers"},
+		{ID: 3, ParentID: 1, Body: "Key left at the bar"},
+		{ID: 4, ParentID: 9, Body: "Reply to a note that's gone"},
+		{ID: 5, ParentID: 1, Body: "Guest arrived"},
+	}
+
+	threads := threadNotes(notes)
+
+	if len(threads) != 3 || threads[0].ID != 1 || threads[1].ID != 2 || threads[2].ID != 4 {
+		t.Fatalf("unexpected threads %+v", threads)
+	}
+	if len(threads[0].Replies) != 2 || threads[0].Replies[0].ID != 3 || threads[0].Replies[1].ID != 5 {
+		t.Errorf("unexpected replies %+v", threads[0].Replies)
+	}
+}
+
+func TestReplyParent(t *testing.T) {
+	notes := []models.ReservationNote{{ID: 1}, {ID: 2, ParentID: 1}}
+
+	if id, problem := replyParent(notes, 1); id != 1 || problem != "" {
+		t.Errorf("expected a reply to note 1, got %d %q", id, problem)
+	}
+	if id, problem := replyParent(notes, 2); id != 1 || problem != "" {
+		t.Errorf("expected a reply to a reply to go under note 1, got %d %q", id, problem)
+	}
+	if _, problem := replyParent(notes, 7); problem == "" {
+		t.Error("expected a problem replying to a note on another reservation")
+	}
+}
+
+func TestAdminPostReservationNote(t *testing.T) {
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var tests = []struct {
+		name         string
+		url          string
+		postedData   url.Values
+		expectedCode int
+	}{
+		{"note", "/admin/reservations/all/1/notes", url.Values{"body": {"Allergic to feathers"}}, http.StatusSeeOther},
+		{"reply", "/admin/reservations/all/1/notes", url.Values{"body": {"Spare pillows sent up"}, "parent_id": {"2"}}, http.StatusSeeOther},
+		{"reply elsewhere", "/admin/reservations/all/1/notes", url.Values{"body": {"Hello"}, "parent_id": {"7"}}, http.StatusSeeOther},
+		{"empty", "/admin/reservations/all/1/notes", url.Values{"body": {"  "}}, http.StatusSeeOther},
+		{"too long", "/admin/reservations/all/1/notes", url.Values{"body": {strings.Repeat("x", maxNoteLength+1)}}, http.StatusSeeOther},
+		{"no reservation", "/admin/reservations/all/101/notes", url.Values{"body": {"Hello"}}, http.StatusNotFound},
+		{"database error", "/admin/reservations/all/1/notes", url.Values{"body": {"error"}}, http.StatusInternalServerError},
+	}
+
+	for _, e := range tests {
+		resp, err := client.PostForm(ts.URL+e.url, e.postedData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedCode)
+		}
+		if e.expectedCode == http.StatusSeeOther && resp.Header.Get("Location") != "/admin/reservations/all/1/show#notes" {
+			t.Errorf("%s: unexpected redirect to %s", e.name, resp.Header.Get("Location"))
+		}
+	}
+}
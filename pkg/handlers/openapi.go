@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiParam is a path or query parameter of an api operation
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// apiOperation documents one json endpoint. Request and response bodies are given as
+// values of the handler types, so the schemas in the spec follow the code
+type apiOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Scope       string
+	Public      bool
+	FormBody    interface{}
+	Params      []apiParam
+	Request     interface{}
+	Response    interface{}
+	Status      int
+	ErrorStatus []int
+	Raw         bool
+}
+
+var idParam = apiParam{Name: "id", In: "path", Type: "integer", Required: true, Description: "reservation id"}
+
+// apiOperations lists every json endpoint in routes.go
+var apiOperations = []apiOperation{
+	{
+		Method:   "GET",
+		Path:     "/api/openapi.json",
+		Summary:  "This document",
+		Public:   true,
+		Response: map[string]interface{}{},
+		Status:   http.StatusOK,
+		Raw:      true,
+	},
+	{
+		Method:   "POST",
+		Path:     "/search-availability-json",
+		Summary:  "Check one room for the room pages (form encoded, needs the csrf_token from the page)",
+		Public:   true,
+		FormBody: availabilityForm{},
+		Response: jsonResponse{},
+		Status:   http.StatusOK,
+		Raw:      true,
+	},
+	{
+		Method:  "GET",
+		Path:    "/rooms/{id}/calendar.json",
+		Summary: "The nights a room is free, a month at a time, for the room pages",
+		Public:  true,
+		Params: []apiParam{
+			{Name: "id", In: "path", Type: "integer", Required: true, Description: "room id"},
+			{Name: "month", In: "query", Type: "string", Description: "first month, YYYY-MM, this month when left out"},
+			{Name: "months", In: "query", Type: "integer", Description: "how many months, 1 to 12, one when left out"},
+		},
+		Response:    calendarResponse{},
+		Status:      http.StatusOK,
+		ErrorStatus: []int{http.StatusBadRequest, http.StatusNotFound},
+		Raw:         true,
+	},
+	{
+		Method:      "GET",
+		Path:        "/api/v1/rooms",
+		Scope:       models.ScopeRoomsRead,
+		Summary:     "List rooms",
+		Response:    []APIRoom{},
+		Status:      http.StatusOK,
+		ErrorStatus: []int{http.StatusUnauthorized},
+	},
+	{
+		Method:  "GET",
+		Path:    "/api/v1/availability",
+		Scope:   models.ScopeAvailabilityRead,
+		Summary: "Search availability for one room, or for every room when room_id is left out",
+		Params: []apiParam{
+			{Name: "start_date", In: "query", Type: "string", Required: true, Description: "arrival, YYYY-MM-DD"},
+			{Name: "end_date", In: "query", Type: "string", Required: true, Description: "departure, YYYY-MM-DD"},
+			{Name: "room_id", In: "query", Type: "integer", Description: "only check this room"},
+		},
+		Response:    APIAvailability{},
+		Status:      http.StatusOK,
+		ErrorStatus: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Method:      "POST",
+		Path:        "/api/v1/reservations",
+		Scope:       models.ScopeReservationsWrite,
+		Summary:     "Create a reservation",
+		Request:     APIReservationRequest{},
+		Response:    APIReservation{},
+		Status:      http.StatusCreated,
+		ErrorStatus: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:      "GET",
+		Path:        "/api/v1/reservations/{id}",
+		Scope:       models.ScopeReservationsRead,
+		Summary:     "Get a reservation",
+		Params:      []apiParam{idParam},
+		Response:    APIReservation{},
+		Status:      http.StatusOK,
+		ErrorStatus: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		Method:      "PUT",
+		Path:        "/api/v1/reservations/{id}",
+		Scope:       models.ScopeReservationsWrite,
+		Summary:     "Replace the guest details of a reservation",
+		Params:      []apiParam{idParam},
+		Request:     APIGuestRequest{},
+		Response:    APIReservation{},
+		Status:      http.StatusOK,
+		ErrorStatus: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:      "POST",
+		Path:        "/api/v1/reservations/{id}/cancel",
+		Scope:       models.ScopeReservationsWrite,
+		Summary:     "Cancel a reservation",
+		Params:      []apiParam{idParam},
+		Response:    APIReservation{},
+		Status:      http.StatusOK,
+		ErrorStatus: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict},
+	},
+}
+
+// availabilityForm documents the form fields read by JSONAvailability
+type availabilityForm struct {
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	RoomID    string `json:"room_id"`
+	CSRFToken string `json:"csrf_token"`
+}
+
+// OpenAPI serves the openapi 3 document for the json endpoints
+func (m *Repository) OpenAPI(w http.ResponseWriter, req *http.Request) {
+	helpers.WriteJSON(w, http.StatusOK, OpenAPISpec())
+}
+
+// OpenAPISpec builds the openapi 3 document from apiOperations
+func OpenAPISpec() map[string]interface{} {
+	g := schemaGenerator{schemas: map[string]interface{}{}}
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range apiOperations {
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = g.operation(op)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Bookings API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Keys are created by an admin under /admin/api-keys",
+				},
+			},
+		},
+	}
+}
+
+// schemaGenerator turns go types into json schemas, collecting named structs as components
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+func (g *schemaGenerator) operation(op apiOperation) map[string]interface{} {
+	out := map[string]interface{}{
+		"summary": op.Summary,
+	}
+
+	if !op.Public {
+		out["security"] = []map[string][]string{{"apiKey": {}}}
+		out["description"] = fmt.Sprintf("Needs an api key with the %s scope.", op.Scope)
+	}
+
+	var params []map[string]interface{}
+	for _, p := range op.Params {
+		params = append(params, map[string]interface{}{
+			"name":        p.Name,
+			"in":          p.In,
+			"required":    p.Required,
+			"description": p.Description,
+			"schema":      map[string]string{"type": p.Type},
+		})
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	if op.Request != nil {
+		out["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	if op.FormBody != nil {
+		out["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/x-www-form-urlencoded": map[string]interface{}{"schema": g.schema(reflect.TypeOf(op.FormBody))},
+			},
+		}
+	}
+
+	body := g.schema(reflect.TypeOf(op.Response))
+	if !op.Raw {
+		body = map[string]interface{}{
+			"type":       "object",
+			"required":   []string{"data"},
+			"properties": map[string]interface{}{"data": body},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	responses[strconv.Itoa(op.Status)] = map[string]interface{}{
+		"description": http.StatusText(op.Status),
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": body},
+		},
+	}
+
+	errBody := g.schema(reflect.TypeOf(helpers.APIErrorResponse{}))
+	errStatuses := append([]int{}, op.ErrorStatus...)
+	if !op.Raw {
+		errStatuses = append(errStatuses, http.StatusInternalServerError)
+	}
+	for _, status := range errStatuses {
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": errBody},
+			},
+		}
+	}
+	out["responses"] = responses
+
+	return out
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the json schema for t, using a $ref for named structs
+func (g *schemaGenerator) schema(t reflect.Type) interface{} {
+	switch {
+	case t == timeType:
+		return map[string]string{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Ptr:
+		return g.schema(t.Elem())
+	case t.Kind() == reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = nil
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]string{"$ref": "#/components/schemas/" + name}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case t.Kind() == reflect.Bool:
+		return map[string]string{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]string{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]string{"type": "number"}
+	case t.Kind() == reflect.String:
+		return map[string]string{"type": "string"}
+	}
+
+	return map[string]interface{}{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) interface{} {
+	props := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		optional := false
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, p := range parts[1:] {
+				if p == "omitempty" {
+					optional = true
+				}
+			}
+		}
+
+		props[name] = g.schema(f.Type)
+		if !optional {
+			required = append(required, name)
+		}
+	}
+
+	out := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		out["required"] = required
+	}
+
+	return out
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/payments"
+	"BookingProject/pkg/pricing"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// pendingReservation gets the reservation waiting for payment from the session
+func (m *Repository) pendingReservation(w http.ResponseWriter, req *http.Request) (models.Reservation, bool) {
+	res, ok := m.App.Session.Get(req.Context(), "reservation").(models.Reservation)
+	if !ok || res.ID == 0 {
+		m.App.Session.Put(req.Context(), "error", "Can't get reservation from session")
+		http.Redirect(w, req, "/", http.StatusSeeOther)
+		return res, false
+	}
+
+	return res, true
+}
+
+// quote prices a reservation with the current taxes and fees, and its promo code if it has one
+func (m *Repository) quote(res models.Reservation) (pricing.Quote, error) {
+	room, err := m.DB.GetRoomByID(res.RoomID)
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+
+	rules, err := m.DB.AllChargeRules()
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+
+	//a code deleted since the guest booked no longer gives a discount
+	var promo *models.PromoCode
+	if res.PromoCodeID != 0 {
+		p, err := m.DB.GetPromoCodeByID(res.PromoCodeID)
+		if err == nil {
+			promo = &p
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return pricing.Quote{}, err
+		}
+	}
+
+	return pricing.QuoteStay(room, res.StartDate, res.EndDate, rules, promo), nil
+}
+
+// Checkout shows the price of the stay and lets the guest pay a deposit or the full amount
+func (m *Repository) Checkout(w http.ResponseWriter, req *http.Request) {
+	res, ok := m.pendingReservation(w, req)
+	if !ok {
+		return
+	}
+
+	if res.Status != models.ReservationPending {
+		http.Redirect(w, req, "/reservation-summary", http.StatusSeeOther)
+		return
+	}
+
+	m.renderCheckout(w, req, res, forms.New(nil))
+}
+
+func (m *Repository) renderCheckout(w http.ResponseWriter, req *http.Request, res models.Reservation, form *forms.Form) {
+	quote, err := m.quote(res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservation"] = res
+	data["quote"] = quote
+
+	intMap := make(map[string]int)
+	intMap["deposit_percent"] = pricing.DepositPercent
+
+	render.Template(w, req, "checkout.page.html", &models.TemplateData{
+		Data:   data,
+		IntMap: intMap,
+		Form:   form,
+	})
+}
+
+// PostCheckout records the payment and sends the guest to the payment provider
+func (m *Repository) PostCheckout(w http.ResponseWriter, req *http.Request) {
+	res, ok := m.pendingReservation(w, req)
+	if !ok {
+		return
+	}
+
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	kind := form.Get("payment")
+	if kind != models.PaymentDeposit && kind != models.PaymentFull {
+		form.Errors.Add("payment", "Choose how much to pay now")
+		m.renderCheckout(w, req, res, form)
+		return
+	}
+
+	room, err := m.DB.GetRoomByID(res.RoomID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	quote, err := m.quote(res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	amount := quote.Amount(kind)
+
+	//nothing to pay for, or no provider to pay through, so there is nothing to wait for.
+	//without a provider the guest settles the bill at the front desk
+	gateway := m.App.Payments
+	if gateway == nil || amount == 0 {
+		m.confirmReservation(res.ID)
+		res.Status = models.ReservationConfirmed
+		m.App.Session.Put(req.Context(), "reservation", res)
+		http.Redirect(w, req, "/reservation-summary", http.StatusSeeOther)
+		return
+	}
+
+	payment := models.Payment{
+		ReservationID: res.ID,
+		Provider:      gateway.Name(),
+		Kind:          kind,
+		Amount:        amount,
+		Currency:      pricing.Currency,
+	}
+
+	payment.ID, err = m.DB.InsertPayment(payment)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	base := m.baseURL()
+	checkout, err := gateway.CreateCheckout(payments.CheckoutRequest{
+		PaymentID:   payment.ID,
+		Amount:      amount,
+		Currency:    pricing.Currency,
+		Description: fmt.Sprintf("%s, %s to %s", room.RoomName, res.StartDate.Format("2006-01-02"), res.EndDate.Format("2006-01-02")),
+		Email:       res.Email,
+		ReturnURL:   base + "/payments/return",
+		CallbackURL: base + "/payments/webhook/" + gateway.Name(),
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = m.DB.UpdatePaymentProviderRef(payment.ID, checkout.Ref)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(req.Context(), "payment_id", payment.ID)
+	http.Redirect(w, req, checkout.RedirectURL, http.StatusSeeOther)
+}
+
+// PaymentReturn is where the guest lands after the payment provider. The webhook may not
+// have arrived yet, in which case the page waits for it
+func (m *Repository) PaymentReturn(w http.ResponseWriter, req *http.Request) {
+	res, ok := m.pendingReservation(w, req)
+	if !ok {
+		return
+	}
+
+	payment, err := m.DB.GetPaymentByID(m.App.Session.GetInt(req.Context(), "payment_id"))
+	if errors.Is(err, sql.ErrNoRows) || payment.ReservationID != res.ID {
+		m.App.Session.Put(req.Context(), "error", "Can't find your payment")
+		http.Redirect(w, req, "/checkout", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	switch payment.Status {
+	case models.PaymentPaid:
+		res.Status = models.ReservationConfirmed
+		m.App.Session.Put(req.Context(), "reservation", res)
+		http.Redirect(w, req, "/reservation-summary", http.StatusSeeOther)
+	case models.PaymentFailed:
+		m.App.Session.Remove(req.Context(), "payment_id")
+		m.App.Session.Put(req.Context(), "error", "Your payment didn't go through, please try again")
+		http.Redirect(w, req, "/checkout", http.StatusSeeOther)
+	default:
+		render.Template(w, req, "payment-pending.page.html", &models.TemplateData{})
+	}
+}
+
+// PaymentWebhook takes the outcome of a payment from the provider. Paying confirms the reservation
+func (m *Repository) PaymentWebhook(w http.ResponseWriter, req *http.Request) {
+	gateway := m.App.Payments
+	if gateway == nil || chi.URLParam(req, "provider") != gateway.Name() {
+		helpers.ErrorJSON(w, http.StatusNotFound, helpers.APIError{Code: "not_found", Message: "Unknown payment provider"})
+		return
+	}
+
+	event, err := gateway.ParseWebhook(req)
+	if err != nil {
+		helpers.ErrorJSON(w, http.StatusBadRequest, helpers.APIError{Code: "invalid_webhook", Message: err.Error()})
+		return
+	}
+
+	payment, err := m.DB.GetPaymentByProviderRef(gateway.Name(), event.Ref)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusNotFound, helpers.APIError{Code: "not_found", Message: "Unknown payment"})
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	if event.Amount != payment.Amount || !strings.EqualFold(event.Currency, payment.Currency) {
+		helpers.ErrorJSON(w, http.StatusBadRequest, helpers.APIError{Code: "amount_mismatch", Message: "Amount or currency doesn't match the payment"})
+		return
+	}
+
+	//providers retry webhooks, so a payment that is already settled is left alone
+	if payment.Status == models.PaymentPending {
+		err = m.DB.UpdatePaymentStatus(payment.ID, event.Status)
+		if err != nil {
+			helpers.ServerErrorJSON(w, err)
+			return
+		}
+
+		if event.Status == models.PaymentPaid {
+			m.confirmReservation(payment.ReservationID)
+		}
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, map[string]bool{"received": true})
+}
+
+// confirmReservation confirms a pending reservation and emails the guest, once
+func (m *Repository) confirmReservation(id int) {
+	confirmed, err := m.DB.ConfirmReservation(id)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+
+	if !confirmed {
+		//paid for after it ran out, which staff have to sort out with the guest
+		res, err := m.DB.GetReservationByID(id)
+		if err == nil && res.Status == models.ReservationCancelled {
+			m.App.ErrorLog.Printf("reservation %d was paid for after it was cancelled for not being paid", id)
+		}
+		return
+	}
+
+	res, err := m.DB.GetReservationByID(id)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+
+	m.sendConfirmation(res)
+}
+
+// fakeGateway returns the fake gateway, if that is the one in use. Otherwise its pages don't exist
+func (m *Repository) fakeGateway(w http.ResponseWriter) (*payments.FakeGateway, bool) {
+	g, ok := m.App.Payments.(*payments.FakeGateway)
+	if !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+	}
+	return g, ok
+}
+
+// FakeGatewayPage stands in for a provider's hosted checkout page
+func (m *Repository) FakeGatewayPage(w http.ResponseWriter, req *http.Request) {
+	g, ok := m.fakeGateway(w)
+	if !ok {
+		return
+	}
+
+	ref := chi.URLParam(req, "ref")
+	checkout, ok := g.Lookup(ref)
+	if !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["checkout"] = checkout
+
+	stringMap := make(map[string]string)
+	stringMap["ref"] = ref
+
+	render.Template(w, req, "fake-gateway.page.html", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// PostFakeGateway pays or declines a fake checkout, which sends the webhook, then returns the guest
+func (m *Repository) PostFakeGateway(w http.ResponseWriter, req *http.Request) {
+	g, ok := m.fakeGateway(w)
+	if !ok {
+		return
+	}
+
+	ref := chi.URLParam(req, "ref")
+	checkout, ok := g.Lookup(ref)
+	if !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err := g.Complete(ref, req.PostFormValue("outcome") == "pay")
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+
+	http.Redirect(w, req, checkout.ReturnURL, http.StatusSeeOther)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/payments"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var pendingRes = models.Reservation{
+	ID:        1,
+	RoomID:    1,
+	Status:    models.ReservationPending,
+	StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+	EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+}
+
+// checkoutTests is the data for the Checkout handlers
+var checkoutTests = []struct {
+	name               string
+	method             string
+	reservation        models.Reservation
+	postedData         url.Values
+	expectedStatusCode int
+	expectedLocation   string
+}{
+	{"show", "GET", pendingRes, nil, http.StatusOK, ""},
+	{"not in session", "GET", models.Reservation{}, nil, http.StatusSeeOther, "/"},
+	{"already confirmed", "GET", models.Reservation{ID: 1, RoomID: 1, Status: models.ReservationConfirmed}, nil, http.StatusSeeOther, "/reservation-summary"},
+	{"no choice", "POST", pendingRes, url.Values{}, http.StatusOK, ""},
+	{"nothing to pay", "POST", pendingRes, url.Values{"payment": {"full"}}, http.StatusSeeOther, "/reservation-summary"},
+}
+
+func TestCheckout(t *testing.T) {
+	for _, e := range checkoutTests {
+		var req *http.Request
+		if e.method == "POST" {
+			req, _ = http.NewRequest("POST", "/checkout", strings.NewReader(e.postedData.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req, _ = http.NewRequest("GET", "/checkout", nil)
+		}
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+
+		if e.reservation.ID > 0 {
+			session.Put(ctx, "reservation", e.reservation)
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.Checkout)
+		if e.method == "POST" {
+			handler = Repo.PostCheckout
+		}
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedStatusCode)
+		}
+
+		if e.expectedLocation != "" {
+			actualLoc, _ := rr.Result().Location()
+			if actualLoc.String() != e.expectedLocation {
+				t.Errorf("failed %s: expected location %s, but got location %s", e.name, e.expectedLocation, actualLoc.String())
+			}
+		}
+	}
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	g := app.Payments.(*payments.FakeGateway)
+
+	signed := func(e payments.Event) ([]byte, string) {
+		body, sig, err := g.Sign(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body, sig
+	}
+
+	paidBody, paidSig := signed(payments.Event{Ref: "fake_1", Status: models.PaymentPaid, Amount: 1790, Currency: "USD"})
+	unknownBody, unknownSig := signed(payments.Event{Ref: "fake_2", Status: models.PaymentPaid, Amount: 1790, Currency: "USD"})
+	shortBody, shortSig := signed(payments.Event{Ref: "fake_1", Status: models.PaymentPaid, Amount: 10, Currency: "USD"})
+	yenBody, yenSig := signed(payments.Event{Ref: "fake_1", Status: models.PaymentPaid, Amount: 1790, Currency: "JPY"})
+
+	var tests = []struct {
+		name               string
+		provider           string
+		body               []byte
+		sig                string
+		expectedStatusCode int
+	}{
+		{"paid", "fake", paidBody, paidSig, http.StatusOK},
+		{"bad signature", "fake", paidBody, "nope", http.StatusBadRequest},
+		{"unknown provider", "other", paidBody, paidSig, http.StatusNotFound},
+		{"unknown payment", "fake", unknownBody, unknownSig, http.StatusNotFound},
+		{"wrong amount", "fake", shortBody, shortSig, http.StatusBadRequest},
+		{"wrong currency", "fake", yenBody, yenSig, http.StatusBadRequest},
+	}
+
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", ts.URL+"/payments/webhook/"+e.provider, bytes.NewReader(e.body))
+		req.Header.Set(payments.FakeSignatureHeader, e.sig)
+
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedStatusCode)
+		}
+	}
+}
+
+func TestFakeGatewayOff(t *testing.T) {
+	gateway := app.Payments
+	app.Payments = nil
+	defer func() { app.Payments = gateway }()
+
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	var tests = []struct {
+		name   string
+		method string
+		url    string
+	}{
+		{"checkout page", "GET", "/payments/fake/fake_1"},
+		{"pay", "POST", "/payments/fake/fake_1"},
+		{"webhook", "POST", "/payments/webhook/fake"},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest(e.method, ts.URL+e.url, strings.NewReader("outcome=pay"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s with the fake gateway off: got %d, wanted %d", e.name, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
+
+func TestAdminDeletePaidReservation(t *testing.T) {
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var tests = []struct {
+		name             string
+		url              string
+		expectedLocation string
+	}{
+		{"unpaid", "/admin/delete-reservation/all/1/do", "/admin/reservations-all"},
+		{"paid", "/admin/delete-reservation/all/7/do", "/admin/reservations/all/7/show"},
+	}
+
+	for _, e := range tests {
+		resp, err := client.Get(ts.URL + e.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != e.expectedLocation {
+			t.Errorf("%s: expected a redirect to %s, got %d to %s", e.name, e.expectedLocation, resp.StatusCode, resp.Header.Get("Location"))
+		}
+	}
+}
+
+func TestCheckoutWithoutGateway(t *testing.T) {
+	gateway := app.Payments
+	app.Payments = nil
+	defer func() { app.Payments = gateway }()
+
+	res := pendingRes
+	res.RoomID = 5
+
+	postedData := url.Values{"payment": {"full"}}
+	req, _ := http.NewRequest("POST", "/checkout", strings.NewReader(postedData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	session.Put(ctx, "reservation", res)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.PostCheckout)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("checkout without a payment provider: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	actualLoc, _ := rr.Result().Location()
+	if actualLoc.String() != "/reservation-summary" {
+		t.Errorf("checkout without a payment provider: expected location /reservation-summary, but got %s", actualLoc.String())
+	}
+
+	confirmed, ok := session.Get(ctx, "reservation").(models.Reservation)
+	if !ok || confirmed.Status != models.ReservationConfirmed {
+		t.Errorf("checkout without a payment provider did not confirm the reservation")
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/pricing"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// AdminPromoCodes shows the promo codes and the form to create one
+func (m *Repository) AdminPromoCodes(w http.ResponseWriter, req *http.Request) {
+	m.renderAdminPromoCodes(w, req, forms.New(nil))
+}
+
+func (m *Repository) renderAdminPromoCodes(w http.ResponseWriter, req *http.Request, form *forms.Form) {
+	codes, err := m.DB.AllPromoCodes()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["promo_codes"] = codes
+	data["rooms"] = rooms
+
+	render.Template(w, req, "admin-promo-codes.page.html", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminPostPromoCode creates a promo code
+func (m *Repository) AdminPostPromoCode(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	form.Required("code", "amount")
+	form.IsCode("code")
+
+	promo := models.PromoCode{
+		Code:        strings.ToUpper(form.Get("code")),
+		Description: form.Get("description"),
+		Method:      form.Get("method"),
+	}
+
+	if promo.Method != models.ChargePercent && promo.Method != models.ChargeFlat {
+		form.Errors.Add("method", "Choose a percentage or a fixed amount")
+	}
+
+	if form.Get("amount") != "" {
+		promo.Amount, err = pricing.ParseAmount(form.Get("amount"))
+		if err != nil || promo.Amount == 0 {
+			form.Errors.Add("amount", "Enter an amount like 15 or 25.00")
+		} else if promo.Method == models.ChargePercent && promo.Amount > 10000 {
+			form.Errors.Add("amount", "A percentage can't be more than 100")
+		}
+	}
+
+	promo.ValidFrom = form.OptionalDate("valid_from")
+	promo.ValidTo = form.OptionalDate("valid_to")
+	if !promo.ValidFrom.IsZero() && !promo.ValidTo.IsZero() && promo.ValidTo.Before(promo.ValidFrom) {
+		form.Errors.Add("valid_to", "The end has to be after the start")
+	}
+
+	promo.MaxUses = form.OptionalCount("max_uses")
+	promo.MinNights = form.OptionalCount("min_nights")
+
+	promo.RoomID, err = strconv.Atoi(form.Get("room_id"))
+	if err != nil {
+		form.Errors.Add("room_id", "Choose a room")
+	} else if promo.RoomID != 0 {
+		_, err = m.DB.GetRoomByID(promo.RoomID)
+		if err != nil {
+			form.Errors.Add("room_id", "Choose a room")
+		}
+	}
+
+	if form.Valid() {
+		_, err = m.DB.GetPromoCodeByCode(promo.Code)
+		if err == nil {
+			form.Errors.Add("code", "That code already exists")
+		}
+	}
+
+	if !form.Valid() {
+		m.renderAdminPromoCodes(w, req, form)
+		return
+	}
+
+	promo.ID, err = m.DB.InsertPromoCode(promo)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditCreate, models.EntityPromoCode, promo.ID, nil, promo)
+
+	m.App.Session.Put(req.Context(), "flash", promo.Code+" created")
+	http.Redirect(w, req, "/admin/promo-codes", http.StatusSeeOther)
+}
+
+// AdminDeletePromoCode deletes a promo code, so it can't be used any more
+func (m *Repository) AdminDeletePromoCode(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	before, err := m.DB.GetPromoCodeByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = m.DB.DeletePromoCode(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	m.audit(req, models.AuditDelete, models.EntityPromoCode, id, before, nil)
+
+	m.App.Session.Put(req.Context(), "flash", "Promo code deleted")
+	http.Redirect(w, req, "/admin/promo-codes", http.StatusSeeOther)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminPostPromoCode(t *testing.T) {
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+	}{
+		{"percentage code", url.Values{"code": {"summer"}, "method": {"percent"}, "amount": {"15"}, "room_id": {"0"}}, http.StatusSeeOther},
+		{"fixed code with limits", url.Values{"code": {"WEEKEND-25"}, "method": {"flat"}, "amount": {"25"}, "room_id": {"1"}, "valid_from": {"2050-01-01"}, "valid_to": {"2050-03-31"}, "max_uses": {"10"}, "min_nights": {"2"}}, http.StatusSeeOther},
+		{"missing code", url.Values{"method": {"percent"}, "amount": {"15"}, "room_id": {"0"}}, http.StatusOK},
+		{"bad code", url.Values{"code": {"ten off!"}, "method": {"percent"}, "amount": {"10"}, "room_id": {"0"}}, http.StatusOK},
+		{"existing code", url.Values{"code": {"spring"}, "method": {"percent"}, "amount": {"10"}, "room_id": {"0"}}, http.StatusOK},
+		{"over 100 percent", url.Values{"code": {"FREE"}, "method": {"percent"}, "amount": {"150"}, "room_id": {"0"}}, http.StatusOK},
+		{"zero amount", url.Values{"code": {"NOTHING"}, "method": {"flat"}, "amount": {"0"}, "room_id": {"0"}}, http.StatusOK},
+		{"dates reversed", url.Values{"code": {"SUMMER"}, "method": {"percent"}, "amount": {"10"}, "room_id": {"0"}, "valid_from": {"2050-03-31"}, "valid_to": {"2050-01-01"}}, http.StatusOK},
+		{"bad date", url.Values{"code": {"SUMMER"}, "method": {"percent"}, "amount": {"10"}, "room_id": {"0"}, "valid_from": {"01/01/2050"}}, http.StatusOK},
+		{"negative max uses", url.Values{"code": {"SUMMER"}, "method": {"percent"}, "amount": {"10"}, "room_id": {"0"}, "max_uses": {"-1"}}, http.StatusOK},
+		{"unknown room", url.Values{"code": {"SUMMER"}, "method": {"percent"}, "amount": {"10"}, "room_id": {"3"}}, http.StatusOK},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/admin/promo-codes", strings.NewReader(e.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostPromoCode)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedStatusCode)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// how many reservations the admin lists show a page unless asked, and the other page sizes offered
+const defaultPerPage = 25
+
+var perPageOptions = []int{25, 50, 100}
+
+// reservationSort is a column the admin lists can be sorted on
+type reservationSort struct {
+	Key   string
+	Label string
+}
+
+// reservationSorts are the columns the admin lists can be sorted on, in the order they're offered
+var reservationSorts = []reservationSort{
+	{models.SortArrival, "Arrival"},
+	{models.SortDeparture, "Departure"},
+	{models.SortLastName, "Last Name"},
+	{models.SortRoom, "Room"},
+	{models.SortBooked, "Booked"},
+}
+
+// listPage is where a page of reservations sits in the whole list, with links to the pages either side
+type listPage struct {
+	Number   int
+	Pages    int
+	Total    int
+	First    int
+	Last     int
+	Previous string
+	Next     string
+}
+
+// AdminNewReservations lists the reservations nobody has processed yet
+func (m *Repository) AdminNewReservations(w http.ResponseWriter, req *http.Request) {
+	m.renderReservationList(w, req, "new", "admin-new-reservations.page.html")
+}
+
+// AdminAllReservations lists every reservation
+func (m *Repository) AdminAllReservations(w http.ResponseWriter, req *http.Request) {
+	m.renderReservationList(w, req, "all", "admin-all-reservations.page.html")
+}
+
+// renderReservationList shows a page of the reservations matching the filters in the query. Filters
+// that can't be read are pointed out and left off
+func (m *Repository) renderReservationList(w http.ResponseWriter, req *http.Request, src, page string) {
+	form := forms.New(req.URL.Query())
+	filter := reservationFilterFromForm(form)
+	filter.NewOnly = src == "new"
+
+	number, perPage := pageFromForm(form)
+	filter.Limit = perPage
+	filter.Offset = (number - 1) * perPage
+
+	reservations, total, err := m.DB.SearchReservations(filter)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservations"] = reservations
+	data["rooms"] = rooms
+	data["statuses"] = []string{models.ReservationPending, models.ReservationConfirmed, models.ReservationCheckedIn,
+		models.ReservationCheckedOut, models.ReservationCancelled}
+	data["sources"] = []string{models.SourceWebsite, models.SourceAPI, models.SourcePhone, models.SourceWalkIn, models.SourceEmail,
+		models.SourceImport}
+	data["sorts"] = reservationSorts
+	data["sort_links"] = sortLinks(req.URL, filter)
+	data["per_page"] = perPageOptions
+	data["page"] = buildListPage(req.URL, number, perPage, len(reservations), total)
+
+	//exports have every page of the list
+	q := req.URL.Query()
+	q.Del("page")
+	q.Del("per_page")
+
+	stringMap := make(map[string]string)
+	stringMap["src"] = src
+	stringMap["export_csv"] = "/admin/export-reservations/" + src + "/csv?" + q.Encode()
+	stringMap["export_xlsx"] = "/admin/export-reservations/" + src + "/xlsx?" + q.Encode()
+
+	render.Template(w, req, page, &models.TemplateData{
+		Form:      form,
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// reservationFilterFromForm reads the search, filters and sort of a reservation list, adding errors
+// to the form for any that can't be read
+func reservationFilterFromForm(form *forms.Form) models.ReservationFilter {
+	filter := models.ReservationFilter{
+		Search: form.Get("q"),
+		From:   form.OptionalDate("from"),
+		To:     form.OptionalDate("to"),
+		Status: form.Get("status"),
+		Source: form.Get("source"),
+		Sort:   form.Get("sort"),
+		Desc:   form.Get("dir") == "desc",
+	}
+
+	if form.Get("room_id") != "" {
+		id, err := strconv.Atoi(form.Get("room_id"))
+		if err != nil {
+			form.Errors.Add("room_id", "Choose a room")
+		}
+		filter.RoomID = id
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		form.Errors.Add("to", "The end has to be after the start")
+		filter.To = time.Time{}
+	}
+
+	return filter
+}
+
+// pageFromForm reads which page of a list to show and how long pages are, falling back to the first
+// page of the default length
+func pageFromForm(form *forms.Form) (int, int) {
+	number, err := strconv.Atoi(form.Get("page"))
+	if err != nil || number < 1 {
+		number = 1
+	}
+
+	perPage := defaultPerPage
+	if n, err := strconv.Atoi(form.Get("per_page")); err == nil {
+		for _, o := range perPageOptions {
+			if n == o {
+				perPage = n
+			}
+		}
+	}
+
+	return number, perPage
+}
+
+// buildListPage works out where a page of shown reservations sits among total, linking the pages
+// either side with the rest of the query kept
+func buildListPage(u *url.URL, number, perPage, shown, total int) listPage {
+	p := listPage{
+		Number: number,
+		Pages:  (total + perPage - 1) / perPage,
+		Total:  total,
+	}
+
+	if shown > 0 {
+		p.First = (number-1)*perPage + 1
+		p.Last = p.First + shown - 1
+	}
+
+	if number > 1 {
+		p.Previous = withQuery(u, "page", strconv.Itoa(number-1))
+	}
+	if number < p.Pages {
+		p.Next = withQuery(u, "page", strconv.Itoa(number+1))
+	}
+
+	return p
+}
+
+// sortLinks links each sortable column to the list sorted on it, from the first page. The column
+// already sorted on flips direction
+func sortLinks(u *url.URL, filter models.ReservationFilter) map[string]string {
+	current := filter.Sort
+	if current == "" {
+		current = models.SortArrival
+	}
+
+	links := make(map[string]string)
+	for _, s := range reservationSorts {
+		dir := "asc"
+		if s.Key == current && !filter.Desc {
+			dir = "desc"
+		}
+
+		q := u.Query()
+		q.Set("sort", s.Key)
+		q.Set("dir", dir)
+		q.Del("page")
+		links[s.Key] = u.Path + "?" + q.Encode()
+	}
+
+	return links
+}
+
+// withQuery is the url with one query value changed
+func withQuery(u *url.URL, key, value string) string {
+	q := u.Query()
+	q.Set(key, value)
+	return u.Path + "?" + q.Encode()
+}
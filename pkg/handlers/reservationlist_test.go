@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/models"
+	"net/url"
+	"testing"
+)
+
+func TestReservationFilterFromForm(t *testing.T) {
+	form := forms.New(url.Values{
+		"q":       {"smith"},
+		"room_id": {"2"},
+		"from":    {"2050-02-01"},
+		"to":      {"2050-01-01"},
+		"sort":    {"room"},
+		"dir":     {"desc"},
+	})
+
+	filter := reservationFilterFromForm(form)
+
+	if filter.Search != "smith" || filter.RoomID != 2 || filter.Sort != models.SortRoom || !filter.Desc {
+		t.Errorf("unexpected filter %+v", filter)
+	}
+	if form.Errors.Get("to") == "" || !filter.To.IsZero() || filter.From.IsZero() {
+		t.Errorf("expected the reversed end date to be pointed out and left off, got %+v", filter)
+	}
+}
+
+func TestPageFromForm(t *testing.T) {
+	var tests = []struct {
+		query   url.Values
+		number  int
+		perPage int
+	}{
+		{url.Values{}, 1, defaultPerPage},
+		{url.Values{"page": {"3"}, "per_page": {"50"}}, 3, 50},
+		{url.Values{"page": {"0"}, "per_page": {"7"}}, 1, defaultPerPage},
+		{url.Values{"page": {"two"}}, 1, defaultPerPage},
+	}
+
+	for _, e := range tests {
+		number, perPage := pageFromForm(forms.New(e.query))
+		if number != e.number || perPage != e.perPage {
+			t.Errorf("%v: expected page %d of %d, got %d of %d", e.query, e.number, e.perPage, number, perPage)
+		}
+	}
+}
+
+func TestBuildListPage(t *testing.T) {
+	u, _ := url.Parse("/admin/reservations-all?q=smith&page=2")
+
+	p := buildListPage(u, 2, 25, 25, 60)
+	if p.Pages != 3 || p.First != 26 || p.Last != 50 {
+		t.Errorf("unexpected page %+v", p)
+	}
+	if p.Previous != "/admin/reservations-all?page=1&q=smith" || p.Next != "/admin/reservations-all?page=3&q=smith" {
+		t.Errorf("unexpected links %q and %q", p.Previous, p.Next)
+	}
+
+	p = buildListPage(u, 3, 25, 10, 60)
+	if p.Next != "" || p.Last != 60 {
+		t.Errorf("expected no next page on the last one, got %+v", p)
+	}
+
+	p = buildListPage(u, 1, 25, 0, 0)
+	if p.Pages != 0 || p.First != 0 || p.Previous != "" || p.Next != "" {
+		t.Errorf("unexpected empty page %+v", p)
+	}
+}
+
+func TestSortLinks(t *testing.T) {
+	u, _ := url.Parse("/admin/reservations-new?q=smith&page=4")
+
+	links := sortLinks(u, models.ReservationFilter{})
+	if links[models.SortArrival] != "/admin/reservations-new?dir=desc&q=smith&sort=arrival" {
+		t.Errorf("expected the default sort to flip, got %s", links[models.SortArrival])
+	}
+	if links[models.SortRoom] != "/admin/reservations-new?dir=asc&q=smith&sort=room" {
+		t.Errorf("unexpected link %s", links[models.SortRoom])
+	}
+
+	links = sortLinks(u, models.ReservationFilter{Sort: models.SortRoom, Desc: true})
+	if links[models.SortRoom] != "/admin/reservations-new?dir=asc&q=smith&sort=room" {
+		t.Errorf("expected a descending sort to flip back, got %s", links[models.SortRoom])
+	}
+}
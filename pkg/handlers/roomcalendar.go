@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"BookingProject/pkg/availability"
+	"BookingProject/pkg/helpers"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// the most months the room calendar can ask for at once
+const maxCalendarMonths = 12
+
+// calendarResponse is the nights a room is free for, shown on the room pages. It says nothing about
+// who has the room on the other nights
+type calendarResponse struct {
+	RoomID    int           `json:"room_id"`
+	StartDate string        `json:"start_date"`
+	EndDate   string        `json:"end_date"`
+	Days      []calendarDay `json:"days"`
+}
+
+type calendarDay struct {
+	Date      string `json:"date"`
+	Available bool   `json:"available"`
+}
+
+// RoomCalendarJSON returns whether a room is free each night over whole months. month is the first of
+// them, as 2050-01, and defaults to this month; months is how many, one unless asked for. Nights already
+// gone are never free
+func (m *Repository) RoomCalendarJSON(w http.ResponseWriter, req *http.Request) {
+	roomID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ErrorJSON(w, http.StatusNotFound, helpers.APIError{Code: "not_found", Message: "room not found"})
+		return
+	}
+
+	_, err = m.DB.GetRoomByID(roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ErrorJSON(w, http.StatusNotFound, helpers.APIError{Code: "not_found", Message: "room not found"})
+		return
+	} else if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if month := req.URL.Query().Get("month"); month != "" {
+		start, err = time.Parse("2006-01", month)
+		if err != nil {
+			helpers.ErrorJSON(w, http.StatusBadRequest, helpers.APIError{Code: "invalid", Message: "month must look like 2050-01"})
+			return
+		}
+	}
+
+	months := 1
+	if n := req.URL.Query().Get("months"); n != "" {
+		months, err = strconv.Atoi(n)
+		if err != nil || months < 1 || months > maxCalendarMonths {
+			helpers.ErrorJSON(w, http.StatusBadRequest, helpers.APIError{
+				Code:    "invalid",
+				Message: "months must be between 1 and " + strconv.Itoa(maxCalendarMonths),
+			})
+			return
+		}
+	}
+	end := start.AddDate(0, months, 0)
+
+	restrictions, err := m.DB.GetTakenRestrictionsForRoomByDate(roomID, start, end)
+	if err != nil {
+		helpers.ServerErrorJSON(w, err)
+		return
+	}
+
+	resp := calendarResponse{
+		RoomID:    roomID,
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Format("2006-01-02"),
+	}
+	for _, d := range availability.Days(restrictions, roomID, start, end) {
+		resp.Days = append(resp.Days, calendarDay{
+			Date:      d.Date.Format("2006-01-02"),
+			Available: d.Available && !d.Date.Before(today),
+		})
+	}
+
+	_ = helpers.WriteJSON(w, http.StatusOK, resp)
+}
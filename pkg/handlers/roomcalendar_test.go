@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoomCalendarJSON(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedDays       int
+		expectedFree       map[string]bool
+	}{
+		{"this month", "/rooms/1/calendar.json", http.StatusOK, -1, nil},
+		{"one month", "/rooms/1/calendar.json?month=2050-01", http.StatusOK, 31, map[string]bool{
+			"2050-01-01": false,
+			"2050-01-07": false,
+			"2050-01-08": true,
+		}},
+		{"other room", "/rooms/2/calendar.json?month=2050-01", http.StatusOK, 31, map[string]bool{"2050-01-01": true}},
+		{"two months", "/rooms/1/calendar.json?month=2050-01&months=2", http.StatusOK, 59, nil},
+		{"past", "/rooms/2/calendar.json?month=2000-01", http.StatusOK, 31, map[string]bool{"2000-01-15": false}},
+		{"bad month", "/rooms/1/calendar.json?month=january", http.StatusBadRequest, 0, nil},
+		{"too many months", "/rooms/1/calendar.json?months=13", http.StatusBadRequest, 0, nil},
+		{"unknown room", "/rooms/3/calendar.json", http.StatusNotFound, 0, nil},
+		{"room lookup fails", "/rooms/1000/calendar.json", http.StatusInternalServerError, 0, nil},
+	}
+
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	for _, e := range tests {
+		resp, err := ts.Client().Get(ts.URL + e.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var cal calendarResponse
+		_ = json.NewDecoder(resp.Body).Decode(&cal)
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedStatusCode)
+			continue
+		}
+
+		if e.expectedDays >= 0 && e.expectedStatusCode == http.StatusOK && len(cal.Days) != e.expectedDays {
+			t.Errorf("%s returned %d days, wanted %d", e.name, len(cal.Days), e.expectedDays)
+		}
+
+		for _, d := range cal.Days {
+			if free, ok := e.expectedFree[d.Date]; ok && free != d.Available {
+				t.Errorf("%s: %s available is %t, wanted %t", e.name, d.Date, d.Available, free)
+			}
+		}
+	}
+}
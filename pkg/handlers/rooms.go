@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// AdminRooms shows the rooms with the shortest stay each one takes
+func (m *Repository) AdminRooms(w http.ResponseWriter, req *http.Request) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+
+	render.Template(w, req, "admin-rooms.page.html", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminPostRoom changes the shortest stay guests can book a room for. Stays already booked are left as they are
+func (m *Repository) AdminPostRoom(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before, err := m.DB.GetRoomByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	minNights, err := strconv.Atoi(strings.TrimSpace(req.Form.Get("min_nights")))
+	if err != nil || minNights < 1 {
+		m.App.Session.Put(req.Context(), "error", "Minimum nights has to be a whole number, 1 or more")
+		http.Redirect(w, req, "/admin/rooms", http.StatusSeeOther)
+		return
+	}
+
+	err = m.DB.UpdateRoomMinNights(id, minNights)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	after := before
+	after.MinNights = minNights
+	m.audit(req, models.AuditUpdate, models.EntityRoom, id, before, after)
+
+	m.App.Session.Put(req.Context(), "flash", fmt.Sprintf("%s now takes stays of %d nights or more", before.RoomName, minNights))
+	http.Redirect(w, req, "/admin/rooms", http.StatusSeeOther)
+}
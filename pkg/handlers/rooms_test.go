@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAdminPostRoom(t *testing.T) {
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var tests = []struct {
+		name               string
+		url                string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"valid", "/admin/rooms/1", url.Values{"min_nights": {"3"}}, http.StatusSeeOther, "/admin/rooms"},
+		{"not a number", "/admin/rooms/1", url.Values{"min_nights": {"three"}}, http.StatusSeeOther, "/admin/rooms"},
+		{"zero nights", "/admin/rooms/1", url.Values{"min_nights": {"0"}}, http.StatusSeeOther, "/admin/rooms"},
+		{"unknown room", "/admin/rooms/3", url.Values{"min_nights": {"3"}}, http.StatusNotFound, ""},
+		{"database error", "/admin/rooms/1000", url.Values{"min_nights": {"3"}}, http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range tests {
+		resp, err := client.PostForm(ts.URL+e.url, e.postedData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedStatusCode)
+		}
+		if e.expectedLocation != "" && resp.Header.Get("Location") != e.expectedLocation {
+			t.Errorf("%s redirected to %s, wanted %s", e.name, resp.Header.Get("Location"), e.expectedLocation)
+		}
+	}
+}
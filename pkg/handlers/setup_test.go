@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"BookingProject/pkg/config"
+	"BookingProject/pkg/helpers"
 	"BookingProject/pkg/models"
+	"BookingProject/pkg/payments"
+	"BookingProject/pkg/pricing"
 	"BookingProject/pkg/render"
 	"encoding/gob"
 	"fmt"
@@ -28,6 +31,8 @@ var functions = template.FuncMap{
 	"humanDate":  render.HumanDate,
 	"formatDate": render.FormatDate,
 	"iterate":    render.Iterate,
+	"money":      pricing.Money,
+	"percent":    pricing.Percent,
 }
 
 func TestMain(m *testing.M) {
@@ -54,6 +59,11 @@ func TestMain(m *testing.M) {
 
 	app.Session = session
 
+	app.Payments = payments.NewFakeGateway()
+	app.SigningKey = []byte("test-signing-key")
+	app.SearchWindowDays = 7
+	app.BaseURL = "http://localhost:8080"
+
 	mailChan := make(chan models.MailData)
 	app.MailChan = mailChan
 	defer close(mailChan)
@@ -71,6 +81,7 @@ func TestMain(m *testing.M) {
 	repo := NewTestRepo(&app)
 	NewHandlers(repo)
 	render.NewRenderer(&app)
+	helpers.NewHelpers(&app)
 
 	os.Exit(m.Run())
 }
@@ -98,21 +109,45 @@ func getRoutes() http.Handler {
 	mux.Get("/search-availability", Repo.Availability)
 	mux.Post("/search-availability", Repo.PostAvailability)
 	mux.Post("/search-availability-json", Repo.JSONAvailability)
+	mux.Get("/rooms/{id}/calendar.json", Repo.RoomCalendarJSON)
+
+	mux.Get("/waitlist", Repo.Waitlist)
+	mux.Post("/waitlist", Repo.PostWaitlist)
+	mux.Get("/waitlist/{id}/book", Repo.WaitlistBook)
 
 	mux.Get("/contact", Repo.Contact)
 
 	mux.Get("/make-reservation", Repo.Reservation)
 	mux.Post("/make-reservation", Repo.PostReservation)
 	mux.Get("/reservation-summary", Repo.ReservationSummary)
+	mux.Get("/reservations/{id}", Repo.GuestReservation)
+	mux.Get("/reservations/{id}/invoice", Repo.GuestInvoice)
+	mux.Get("/reservations/{id}/invoice.pdf", Repo.GuestInvoicePDF)
+
+	mux.Get("/checkout", Repo.Checkout)
+	mux.Post("/checkout", Repo.PostCheckout)
+	mux.Get("/payments/return", Repo.PaymentReturn)
+	mux.Post("/payments/webhook/{provider}", Repo.PaymentWebhook)
+	mux.Get("/payments/fake/{ref}", Repo.FakeGatewayPage)
+	mux.Post("/payments/fake/{ref}", Repo.PostFakeGateway)
 
 	mux.Get("/user/login", Repo.ShowLogin)
 	mux.Post("/user/login", Repo.PostShowLogin)
 	mux.Get("/user/logout", Repo.Logout)
 
 	mux.Get("/admin/dashboard", Repo.AdminDashboard)
+	mux.Get("/admin/audit-log", Repo.AdminAuditLog)
+	mux.Get("/admin/today", Repo.AdminToday)
+	mux.Get("/admin/check-in/{id}/do", Repo.AdminCheckIn)
+	mux.Get("/admin/check-out/{id}/do", Repo.AdminCheckOut)
 
 	mux.Get("/admin/reservations-new", Repo.AdminNewReservations)
+	mux.Get("/admin/add-reservation", Repo.AdminAddReservation)
+	mux.Post("/admin/add-reservation", Repo.AdminPostAddReservation)
+	mux.Get("/admin/import-reservations", Repo.AdminImportReservations)
+	mux.Post("/admin/import-reservations", Repo.AdminPostImportReservations)
 	mux.Get("/admin/reservations-all", Repo.AdminAllReservations)
+	mux.Get("/admin/export-reservations/{src}/{format}", Repo.AdminExportReservations)
 	mux.Get("/admin/reservations-calendar", Repo.AdminReservationsCalendar)
 	mux.Post("/admin/reservations-calendar", Repo.AdminPostReservationsCalendar)
 	mux.Get("/admin/process-reservation/{src}/{id}/do", Repo.AdminProcessReservation)
@@ -120,6 +155,45 @@ func getRoutes() http.Handler {
 
 	mux.Get("/admin/reservations/{src}/{id}/show", Repo.AdminShowReservation)
 	mux.Post("/admin/reservations/{src}/{id}", Repo.AdminPostShowReservation)
+	mux.Post("/admin/reservations/{src}/{id}/notes", Repo.AdminPostReservationNote)
+	mux.Get("/admin/reservations/{src}/{id}/invoice", Repo.AdminInvoice)
+	mux.Get("/admin/reservations/{src}/{id}/invoice.pdf", Repo.AdminInvoicePDF)
+
+	mux.Get("/admin/api-keys", Repo.AdminAPIKeys)
+	mux.Post("/admin/api-keys", Repo.AdminPostAPIKey)
+	mux.Get("/admin/revoke-api-key/{id}/do", Repo.AdminRevokeAPIKey)
+
+	mux.Get("/admin/ical", Repo.AdminICal)
+	mux.Get("/admin/regenerate-room-ical/{id}/do", Repo.AdminRegenerateRoomICal)
+	mux.Get("/admin/regenerate-staff-ical/do", Repo.AdminRegenerateStaffICal)
+	mux.Post("/admin/ical", Repo.AdminPostICalFeed)
+	mux.Get("/admin/sync-ical-feed/{id}/do", Repo.AdminSyncICalFeed)
+	mux.Get("/admin/delete-ical-feed/{id}/do", Repo.AdminDeleteICalFeed)
+	mux.Get("/admin/rooms", Repo.AdminRooms)
+	mux.Post("/admin/rooms/{id}", Repo.AdminPostRoom)
+	mux.Get("/admin/taxes-fees", Repo.AdminCharges)
+	mux.Post("/admin/taxes-fees", Repo.AdminPostCharge)
+	mux.Get("/admin/delete-charge/{id}/do", Repo.AdminDeleteCharge)
+	mux.Get("/admin/promo-codes", Repo.AdminPromoCodes)
+	mux.Post("/admin/promo-codes", Repo.AdminPostPromoCode)
+	mux.Get("/admin/delete-promo-code/{id}/do", Repo.AdminDeletePromoCode)
+	mux.Get("/admin/blocks", Repo.AdminBlocks)
+	mux.Post("/admin/blocks", Repo.AdminPostBlock)
+	mux.Get("/admin/blocks/bulk", Repo.AdminBulkBlocks)
+	mux.Post("/admin/blocks/bulk", Repo.AdminPostBulkBlocks)
+	mux.Get("/admin/blocks/{id}", Repo.AdminShowBlock)
+	mux.Post("/admin/blocks/{id}", Repo.AdminPostUpdateBlock)
+	mux.Get("/admin/delete-block/{id}/do", Repo.AdminDeleteBlock)
+	mux.Get("/ical/rooms/{token}", Repo.RoomICalFeed)
+	mux.Get("/ical/staff/{token}", Repo.StaffICalFeed)
+
+	mux.Get("/api/openapi.json", Repo.OpenAPI)
+	mux.Get("/api/v1/rooms", Repo.APIRooms)
+	mux.Get("/api/v1/availability", Repo.APIAvailability)
+	mux.Post("/api/v1/reservations", Repo.APICreateReservation)
+	mux.Get("/api/v1/reservations/{id}", Repo.APIReservation)
+	mux.Put("/api/v1/reservations/{id}", Repo.APIUpdateReservation)
+	mux.Post("/api/v1/reservations/{id}/cancel", Repo.APICancelReservation)
 
 	fileServer := http.FileServer(http.Dir("./static/"))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"BookingProject/pkg/forms"
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/render"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// how long the link emailed to a guest on the waitlist can be used to book
+const waitlistLinkDuration = 24 * time.Hour
+
+// Waitlist shows the form to join the waitlist, with the dates that were searched for filled in
+func (m *Repository) Waitlist(w http.ResponseWriter, req *http.Request) {
+	m.renderWaitlist(w, req, forms.New(req.URL.Query()))
+}
+
+func (m *Repository) renderWaitlist(w http.ResponseWriter, req *http.Request, form *forms.Form) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+
+	render.Template(w, req, "waitlist.page.html", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// PostWaitlist puts a guest on the waitlist for their dates
+func (m *Repository) PostWaitlist(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(req.PostForm)
+	form.Required("first_name", "last_name", "email", "start", "end")
+	form.IsEmail("email")
+
+	entry := models.WaitlistEntry{
+		FirstName: form.Get("first_name"),
+		LastName:  form.Get("last_name"),
+		Email:     form.Get("email"),
+		StartDate: form.OptionalDate("start"),
+		EndDate:   form.OptionalDate("end"),
+	}
+
+	if !entry.StartDate.IsZero() && entry.StartDate.Before(time.Now().Truncate(24*time.Hour)) {
+		form.Errors.Add("start", "Arrival can't be in the past")
+	}
+	if !entry.StartDate.IsZero() && !entry.EndDate.IsZero() && !entry.EndDate.After(entry.StartDate) {
+		form.Errors.Add("end", "Departure has to be after arrival")
+	}
+
+	entry.RoomID, err = strconv.Atoi(form.Get("room_id"))
+	if err != nil {
+		form.Errors.Add("room_id", "Choose a room")
+	} else if entry.RoomID != 0 {
+		_, err = m.DB.GetRoomByID(entry.RoomID)
+		if err != nil {
+			form.Errors.Add("room_id", "Choose a room")
+		}
+	}
+
+	if !form.Valid() {
+		m.renderWaitlist(w, req, form)
+		return
+	}
+
+	_, err = m.DB.InsertWaitlistEntry(entry)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(req.Context(), "flash", "You're on the waitlist, we'll email you if a room comes free")
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+// NotifyWaitlist emails a link to book to the guests waiting for dates that have come free, longest
+// waiting first. A room offered for some dates isn't offered again to the guests behind them until
+// the link sent for it has run out
+func (m *Repository) NotifyWaitlist() {
+	open, err := m.DB.OpenWaitlistOffers(time.Now().Add(-waitlistLinkDuration))
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+
+	var offered []models.RoomRestriction
+	for _, e := range open {
+		offered = append(offered, models.RoomRestriction{RoomID: e.OfferedRoomID, StartDate: e.StartDate, EndDate: e.EndDate})
+	}
+
+	entries, err := m.DB.WaitingWaitlistEntries()
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+
+	for _, e := range entries {
+		roomID, err := m.freeRoomFor(e, offered)
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+			return
+		}
+		if roomID == 0 {
+			continue
+		}
+
+		notified, err := m.DB.UpdateWaitlistEntryNotified(e.ID, roomID)
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+			return
+		}
+		//another run got to them first
+		if !notified {
+			continue
+		}
+
+		offered = append(offered, models.RoomRestriction{RoomID: roomID, StartDate: e.StartDate, EndDate: e.EndDate})
+		m.sendWaitlistLink(e)
+	}
+}
+
+// freeRoomFor finds a room free for the whole of a waitlisted stay, other than the ones already
+// offered for overlapping dates. It returns 0 when there isn't one
+func (m *Repository) freeRoomFor(e models.WaitlistEntry, offered []models.RoomRestriction) (int, error) {
+	var roomIDs []int
+	if e.RoomID != 0 {
+		available, err := m.DB.SearchAvailabilityByDatesByRoomID(e.StartDate, e.EndDate, e.RoomID)
+		if err != nil || !available {
+			return 0, err
+		}
+		roomIDs = append(roomIDs, e.RoomID)
+	} else {
+		rooms, err := m.DB.SearchAvailabilityForAllRooms(e.StartDate, e.EndDate)
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range rooms {
+			roomIDs = append(roomIDs, r.ID)
+		}
+	}
+
+	for _, id := range roomIDs {
+		taken := false
+		for _, o := range offered {
+			if o.RoomID == id && e.StartDate.Before(o.EndDate) && e.EndDate.After(o.StartDate) {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return id, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (m *Repository) sendWaitlistLink(e models.WaitlistEntry) {
+	expires := time.Now().Add(waitlistLinkDuration)
+	link := m.waitlistLink(e.ID, expires)
+
+	htmlMessage := fmt.Sprintf(`<strong>A room has come free</strong><br>
+	Dear %s,<br>
+	A room is now free from %s to %s. It isn't held for you, so book soon if you still want it.
+	This link works until %s: <a href="%s">%s</a>`,
+		html.EscapeString(e.FirstName), e.StartDate.Format("2006-01-02"), e.EndDate.Format("2006-01-02"),
+		expires.Format("2006-01-02 15:04"), link, link)
+
+	m.App.MailChan <- models.MailData{
+		To:      e.Email,
+		From:    "me@here.com",
+		Subject: "A room is free for your dates",
+		Content: htmlMessage,
+	}
+}
+
+func (m *Repository) waitlistLink(entryID int, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return fmt.Sprintf("%s/waitlist/%d/book?expires=%s&sig=%s", m.baseURL(), entryID, exp, helpers.Sign(signedWaitlistLink(entryID, exp)))
+}
+
+// signedWaitlistLink is what a waitlist link signs, so the expiry can't be pushed back
+func signedWaitlistLink(entryID int, expires string) string {
+	return fmt.Sprintf("waitlist:%d:%s", entryID, expires)
+}
+
+// WaitlistBook is where the link emailed to a guest on the waitlist goes. It holds a free room for their
+// dates and takes them on to make the reservation
+func (m *Repository) WaitlistBook(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	exp := req.URL.Query().Get("expires")
+	if err != nil || !helpers.ValidSignature(signedWaitlistLink(id, exp), req.URL.Query().Get("sig")) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		m.App.Session.Put(req.Context(), "error", "That link has expired, please search again")
+		http.Redirect(w, req, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	entry, err := m.DB.GetWaitlistEntryByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	roomID, err := m.freeRoomFor(entry, nil)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if roomID == 0 {
+		m.App.Session.Put(req.Context(), "error", "Sorry, the room has been booked again")
+		http.Redirect(w, req, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	res := models.Reservation{
+		FirstName: entry.FirstName,
+		LastName:  entry.LastName,
+		Email:     entry.Email,
+		StartDate: entry.StartDate,
+		EndDate:   entry.EndDate,
+		RoomID:    roomID,
+	}
+
+	if !m.holdRoom(w, req, res) {
+		return
+	}
+
+	m.App.Session.Put(req.Context(), "reservation", res)
+	http.Redirect(w, req, "/make-reservation", http.StatusSeeOther)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"BookingProject/pkg/helpers"
+	"BookingProject/pkg/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostWaitlist(t *testing.T) {
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+	}{
+		{"any room", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "start": {"2050-01-01"}, "end": {"2050-01-03"}, "room_id": {"0"}}, http.StatusSeeOther},
+		{"one room", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "start": {"2050-01-01"}, "end": {"2050-01-03"}, "room_id": {"2"}}, http.StatusSeeOther},
+		{"bad email", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john"}, "start": {"2050-01-01"}, "end": {"2050-01-03"}, "room_id": {"0"}}, http.StatusOK},
+		{"missing dates", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "room_id": {"0"}}, http.StatusOK},
+		{"dates reversed", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "start": {"2050-01-03"}, "end": {"2050-01-01"}, "room_id": {"0"}}, http.StatusOK},
+		{"in the past", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "start": {"2000-01-01"}, "end": {"2000-01-03"}, "room_id": {"0"}}, http.StatusOK},
+		{"unknown room", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "start": {"2050-01-01"}, "end": {"2050-01-03"}, "room_id": {"3"}}, http.StatusOK},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/waitlist", strings.NewReader(e.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostWaitlist)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, rr.Code, e.expectedStatusCode)
+		}
+	}
+}
+
+func TestWaitlistBook(t *testing.T) {
+	link := func(id int, expires time.Time) string {
+		exp := strconv.FormatInt(expires.Unix(), 10)
+		return fmt.Sprintf("/waitlist/%d/book?expires=%s&sig=%s", id, exp, helpers.Sign(signedWaitlistLink(id, exp)))
+	}
+	later := time.Now().Add(time.Hour)
+
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"room free", link(1, later), http.StatusSeeOther, "/make-reservation"},
+		{"room booked again", link(2, later), http.StatusSeeOther, "/search-availability"},
+		{"expired", link(1, time.Now().Add(-time.Hour)), http.StatusSeeOther, "/search-availability"},
+		{"no signature", "/waitlist/1/book?expires=" + strconv.FormatInt(later.Unix(), 10), http.StatusNotFound, ""},
+		{"signature for another entry", strings.Replace(link(1, later), "/waitlist/1/", "/waitlist/2/", 1), http.StatusNotFound, ""},
+		{"missing entry", link(101, later), http.StatusNotFound, ""},
+	}
+
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for _, e := range tests {
+		resp, err := client.Get(ts.URL + e.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != e.expectedStatusCode {
+			t.Errorf("%s returned wrong response code: got %d, wanted %d", e.name, resp.StatusCode, e.expectedStatusCode)
+		}
+
+		if e.expectedLocation != "" && resp.Header.Get("Location") != e.expectedLocation {
+			t.Errorf("%s redirected to %s, wanted %s", e.name, resp.Header.Get("Location"), e.expectedLocation)
+		}
+	}
+}
+
+func TestFreeRoomFor(t *testing.T) {
+	e, _ := Repo.DB.GetWaitlistEntryByID(1)
+
+	var tests = []struct {
+		name           string
+		offered        []models.RoomRestriction
+		expectedRoomID int
+	}{
+		{"nothing offered", nil, 1},
+		{"offered for the same dates", []models.RoomRestriction{{RoomID: 1, StartDate: e.StartDate, EndDate: e.EndDate}}, 0},
+		{"offered for other dates", []models.RoomRestriction{{RoomID: 1, StartDate: e.EndDate, EndDate: e.EndDate.AddDate(0, 0, 1)}}, 1},
+		{"other room offered", []models.RoomRestriction{{RoomID: 2, StartDate: e.StartDate, EndDate: e.EndDate}}, 1},
+	}
+
+	for _, tt := range tests {
+		roomID, err := Repo.freeRoomFor(e, tt.offered)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if roomID != tt.expectedRoomID {
+			t.Errorf("%s: got room %d, wanted %d", tt.name, roomID, tt.expectedRoomID)
+		}
+	}
+}
@@ -2,6 +2,11 @@ package helpers
 
 import (
 	"BookingProject/pkg/config"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"runtime/debug"
@@ -33,3 +38,82 @@ func IsAuthenticated(req *http.Request) bool {
 
 	return exists
 }
+
+// APIError is the error envelope returned by json endpoints
+type APIError struct {
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Fields  map[string][]string `json:"fields,omitempty"`
+}
+
+// APIErrorResponse wraps every failed api response
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// writes data as json with the given status code
+func WriteJSON(w http.ResponseWriter, status int, data interface{}) error {
+	out, err := json.MarshalIndent(data, "", "     ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(out)
+
+	return err
+}
+
+// writes an error envelope for json clients
+func ErrorJSON(w http.ResponseWriter, status int, apiErr APIError) {
+	_ = WriteJSON(w, status, APIErrorResponse{Error: apiErr})
+}
+
+func ServerErrorJSON(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.ErrorLog.Println(trace)
+	ErrorJSON(w, http.StatusInternalServerError, APIError{
+		Code:    "server_error",
+		Message: http.StatusText(http.StatusInternalServerError),
+	})
+}
+
+// returns n random bytes as a hex string, for secrets that end up in urls and headers
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// generates a new api key, returning the key to hand out, its display prefix and the hash to store
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	token, err := RandomToken(24)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key = "bk_" + token
+	return key, key[:11], HashAPIKey(key), nil
+}
+
+// api keys are long random strings, so a plain sha256 is enough to keep them safe at rest
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// signs a value, such as a reservation id, so it can be handed out in a link and trusted when it comes back
+func Sign(value string) string {
+	mac := hmac.New(sha256.New, app.SigningKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// reports whether sig is the signature of value
+func ValidSignature(value, sig string) bool {
+	return hmac.Equal([]byte(Sign(value)), []byte(sig))
+}
@@ -0,0 +1,289 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const localDateTimeLayout = "20060102T150405"
+
+// ErrNotCalendar is returned by Decode when the stream has no VCALENDAR in it
+var ErrNotCalendar = errors.New("ical: not an iCalendar stream")
+
+var errNoUID = errors.New("ical: event without a UID")
+
+// Decode reads the events of an iCalendar stream. Every event is turned into whole days: timed events
+// cover the days they start and end on, and events with no DTEND last one day. Cancelled events are left out,
+// and so are events without a UID, which are counted in Skipped
+func Decode(r io.Reader) (Calendar, error) {
+	var c Calendar
+
+	lines, err := unfold(r)
+	if err != nil {
+		return c, err
+	}
+
+	var stack []string
+	var ev *rawEvent
+	found := false
+
+	for n, l := range lines {
+		if l == "" {
+			continue
+		}
+
+		name, params, value, ok := splitLine(l)
+		if !ok && !found {
+			return c, ErrNotCalendar
+		} else if !ok {
+			return c, fmt.Errorf("ical: line %d is not a content line", n+1)
+		}
+
+		switch name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(value))
+			switch {
+			case len(stack) == 1 && stack[0] == "VCALENDAR":
+				found = true
+			case len(stack) == 2 && stack[1] == "VEVENT":
+				ev = &rawEvent{}
+			}
+			continue
+		case "END":
+			if len(stack) == 2 && stack[1] == "VEVENT" && ev != nil {
+				e, keep, err := ev.event()
+				if err == errNoUID {
+					//without a UID the event can't be matched up on the next import, but the rest of the feed can be
+					c.Skipped++
+				} else if err != nil {
+					return c, err
+				}
+				if keep {
+					c.Events = append(c.Events, e)
+				}
+				ev = nil
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		switch {
+		case len(stack) == 1 && name == "PRODID":
+			c.ProdID = unescape(value)
+		case len(stack) == 1 && name == "X-WR-CALNAME":
+			c.Name = unescape(value)
+		case len(stack) == 2 && ev != nil:
+			//properties of nested components such as VALARM are skipped by the depth check
+			ev.set(name, params, value)
+		}
+	}
+
+	if !found {
+		return c, ErrNotCalendar
+	}
+
+	return c, nil
+}
+
+// unfold joins folded lines back together
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		l := strings.TrimRight(s.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+
+	return lines, s.Err()
+}
+
+// splitLine splits "NAME;PARAM=x:value", skipping colons inside quoted parameter values
+func splitLine(l string) (name string, params map[string]string, value string, ok bool) {
+	quoted := false
+	colon := -1
+	for i, ch := range l {
+		if ch == '"' {
+			quoted = !quoted
+		}
+		if ch == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(l[:colon], ";")
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	return strings.ToUpper(parts[0]), params, l[colon+1:], true
+}
+
+// rawEvent holds the properties of a VEVENT until its END line
+type rawEvent struct {
+	uid, summary, description, status string
+	start, end, stamp                 string
+	startParams, endParams            map[string]string
+	duration                          string
+}
+
+func (e *rawEvent) set(name string, params map[string]string, value string) {
+	switch name {
+	case "UID":
+		e.uid = value
+	case "SUMMARY":
+		e.summary = unescape(value)
+	case "DESCRIPTION":
+		e.description = unescape(value)
+	case "STATUS":
+		e.status = strings.ToUpper(value)
+	case "DTSTART":
+		e.start, e.startParams = value, params
+	case "DTEND":
+		e.end, e.endParams = value, params
+	case "DURATION":
+		e.duration = value
+	case "DTSTAMP":
+		e.stamp = value
+	}
+}
+
+// event turns the raw properties into an all day event, keep is false for cancelled events
+func (e *rawEvent) event() (ev Event, keep bool, err error) {
+	if e.status == "CANCELLED" {
+		return ev, false, nil
+	}
+
+	if e.uid == "" {
+		return ev, false, errNoUID
+	}
+
+	ev.UID = e.uid
+	ev.Summary = e.summary
+	ev.Description = e.description
+
+	ev.Start, err = parseDay(e.start, e.startParams)
+	if err != nil {
+		return ev, false, fmt.Errorf("ical: event %s: bad DTSTART: %w", e.uid, err)
+	}
+
+	switch {
+	case e.end != "":
+		ev.End, err = parseDay(e.end, e.endParams)
+		if err != nil {
+			return ev, false, fmt.Errorf("ical: event %s: bad DTEND: %w", e.uid, err)
+		}
+	case e.duration != "":
+		days, err := durationDays(e.duration)
+		if err != nil {
+			return ev, false, fmt.Errorf("ical: event %s: bad DURATION: %w", e.uid, err)
+		}
+		ev.End = ev.Start.AddDate(0, 0, days)
+	}
+
+	if !ev.End.After(ev.Start) {
+		ev.End = ev.Start.AddDate(0, 0, 1)
+	}
+
+	if e.stamp != "" {
+		ev.Stamp, _ = time.Parse(dateTimeLayout, e.stamp)
+	}
+
+	return ev, true, nil
+}
+
+// parseDay reads a DATE or DATE-TIME value and returns the day it falls on, at midnight UTC
+func parseDay(value string, params map[string]string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("missing")
+	}
+
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		return time.Parse(dateLayout, value)
+	}
+
+	var t time.Time
+	var err error
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(dateTimeLayout, value)
+	} else {
+		loc := time.UTC
+		if tz, ok := params["TZID"]; ok {
+			if l, lerr := time.LoadLocation(tz); lerr == nil {
+				loc = l
+			}
+		}
+		t, err = time.ParseInLocation(localDateTimeLayout, value, loc)
+	}
+	if err != nil {
+		return t, err
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// durationDays reads the whole days of a DURATION such as P3D or P1W, rounding part days up
+func durationDays(value string) (int, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	if v == value || strings.HasPrefix(value, "-") {
+		return 0, fmt.Errorf("unsupported duration %q", value)
+	}
+
+	days := 0
+	date, clock, timed := strings.Cut(v, "T")
+
+	for date != "" {
+		i := strings.IndexAny(date, "DW")
+		if i < 1 {
+			return 0, fmt.Errorf("unsupported duration %q", value)
+		}
+		n, err := strconv.Atoi(date[:i])
+		if err != nil {
+			return 0, err
+		}
+		if date[i] == 'W' {
+			n *= 7
+		}
+		days += n
+		date = date[i+1:]
+	}
+
+	if timed && clock != "" {
+		days++
+	}
+
+	return days, nil
+}
+
+var unescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, `;`,
+	`\,`, `,`,
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+// unescape reverses escape for a TEXT value
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
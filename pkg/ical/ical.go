@@ -0,0 +1,126 @@
+// Package ical reads and writes the parts of RFC 5545 calendars that room bookings need:
+// all-day events with a start and an exclusive end date.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+
+	// lines longer than this many octets must be folded
+	maxLineOctets = 75
+)
+
+// Calendar is a VCALENDAR
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+
+	// Skipped counts the events Decode left out because they had no UID
+	Skipped int
+}
+
+// Event is an all day VEVENT. End is exclusive, so a one night stay ends on the departure day
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Stamp       time.Time
+}
+
+// Encode writes c to w as an iCalendar stream
+func Encode(w io.Writer, c Calendar) error {
+	bw := bufio.NewWriter(w)
+	e := encoder{w: bw}
+
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:" + escape(c.ProdID))
+	e.line("CALSCALE:GREGORIAN")
+	e.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME:" + escape(c.Name))
+	}
+
+	for _, ev := range c.Events {
+		stamp := ev.Stamp
+		if stamp.IsZero() {
+			stamp = time.Now()
+		}
+
+		e.line("BEGIN:VEVENT")
+		e.line("UID:" + escape(ev.UID))
+		e.line("DTSTAMP:" + stamp.UTC().Format(dateTimeLayout))
+		e.line("DTSTART;VALUE=DATE:" + ev.Start.Format(dateLayout))
+		e.line("DTEND;VALUE=DATE:" + ev.End.Format(dateLayout))
+		e.line("SUMMARY:" + escape(ev.Summary))
+		if ev.Description != "" {
+			e.line("DESCRIPTION:" + escape(ev.Description))
+		}
+		e.line("TRANSP:OPAQUE")
+		e.line("END:VEVENT")
+	}
+
+	e.line("END:VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// encoder writes content lines, keeping the first error
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes one content line, folding it so no physical line is over 75 octets
+func (e *encoder) line(s string) {
+	if e.err != nil {
+		return
+	}
+
+	limit := maxLineOctets
+	for len(s) > limit {
+		//don't split a multi byte character
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		_, e.err = e.w.WriteString(s[:cut] + "\r\n ")
+		if e.err != nil {
+			return
+		}
+		s = s[cut:]
+
+		//continuation lines start with a space, which counts towards the limit
+		limit = maxLineOctets - 1
+	}
+
+	_, e.err = e.w.WriteString(s + "\r\n")
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// escape makes s safe for a TEXT value
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,184 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	stamp := time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	c := Calendar{
+		ProdID: "-//Bookings//EN",
+		Name:   "General's Quarters",
+		Events: []Event{
+			{
+				UID:     "reservation-1@bookings",
+				Summary: "Reserved; late arrival, maybe",
+				Start:   time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+				End:     time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+				Stamp:   stamp,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"BEGIN:VCALENDAR\r\n",
+		"VERSION:2.0\r\n",
+		"UID:reservation-1@bookings\r\n",
+		"DTSTAMP:20500101T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20500101\r\n",
+		"DTEND;VALUE=DATE:20500103\r\n",
+		`SUMMARY:Reserved\; late arrival\, maybe` + "\r\n",
+		"END:VCALENDAR\r\n",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("expected output to contain %q, got:\n%s", e, out)
+		}
+	}
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	c := Calendar{
+		ProdID: "-//Bookings//EN",
+		Events: []Event{
+			{
+				UID:         "block-1@bookings",
+				Summary:     "Blocked",
+				Description: strings.Repeat("é", 100),
+				Start:       time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+				End:         time.Date(2050, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line is %d octets long: %q", len(line), line)
+		}
+	}
+
+	unfolded := strings.ReplaceAll(buf.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:"+strings.Repeat("é", 100)+"\r\n") {
+		t.Error("folded description doesn't unfold back to the original")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	in := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"PRODID:-//Other Site//EN",
+		"X-WR-CALNAME:Listing 42",
+		"BEGIN:VEVENT",
+		"UID:abc@other",
+		"DTSTART;VALUE=DATE:20500110",
+		"DTEND;VALUE=DATE:20500113",
+		"SUMMARY:Reserved\\, paid",
+		"DESCRIPTION:a long description that has been folded over",
+		"  two lines",
+		"BEGIN:VALARM",
+		"UID:not-an-event",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:timed@other",
+		"DTSTART;TZID=\"Europe/London\":20500201T150000",
+		"DTEND:20500203T100000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:one-day@other",
+		"DTSTART:20500301",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:cancelled@other",
+		"STATUS:CANCELLED",
+		"DTSTART:20500401",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20500501",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Name != "Listing 42" {
+		t.Errorf("expected calendar name Listing 42, got %q", c.Name)
+	}
+
+	if c.Skipped != 1 {
+		t.Errorf("expected 1 event skipped for having no UID, got %d", c.Skipped)
+	}
+
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	expected := []Event{
+		{UID: "abc@other", Summary: "Reserved, paid", Description: "a long description that has been folded over two lines", Start: day(2050, 1, 10), End: day(2050, 1, 13)},
+		{UID: "timed@other", Start: day(2050, 2, 1), End: day(2050, 2, 3)},
+		{UID: "one-day@other", Start: day(2050, 3, 1), End: day(2050, 3, 2)},
+	}
+
+	if len(c.Events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(c.Events), c.Events)
+	}
+
+	for i, e := range expected {
+		if c.Events[i] != e {
+			t.Errorf("event %d: expected %+v, got %+v", i, e, c.Events[i])
+		}
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	c := Calendar{
+		ProdID: "-//Bookings//EN",
+		Events: []Event{
+			{
+				UID:     "block-7@bookings",
+				Summary: "Blocked; owner, staying",
+				Start:   time.Date(2050, 5, 1, 0, 0, 0, 0, time.UTC),
+				End:     time.Date(2050, 5, 4, 0, 0, 0, 0, time.UTC),
+				Stamp:   time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out.Events) != 1 || out.Events[0] != c.Events[0] {
+		t.Errorf("expected %+v back, got %+v", c.Events, out.Events)
+	}
+}
+
+func TestDecodeNotCalendar(t *testing.T) {
+	_, err := Decode(strings.NewReader("<html>not found</html>"))
+	if err != ErrNotCalendar {
+		t.Errorf("expected ErrNotCalendar, got %v", err)
+	}
+}
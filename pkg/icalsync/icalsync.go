@@ -0,0 +1,191 @@
+// Package icalsync imports the bookings that other booking sites publish as iCal feeds,
+// keeping one External Booking restriction per event in room_restrictions
+package icalsync
+
+import (
+	"BookingProject/pkg/config"
+	"BookingProject/pkg/ical"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/repository"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// feeds bigger than this are refused
+const maxFeedBytes = 5 << 20
+
+// events we export ourselves end with this, so a site that echoes our feed back isn't imported twice
+const ownUIDSuffix = "@bookings"
+
+// Syncer fetches feeds and applies them to the database
+type Syncer struct {
+	App    *config.AppConfig
+	DB     repository.DatabaseRepo
+	Client *http.Client
+
+	// Freed is called after a sync removes bookings, so the nights they free can be offered to the waitlist
+	Freed func()
+}
+
+// Result counts the external bookings a sync changed
+type Result struct {
+	Created int
+	Updated int
+	Removed int
+}
+
+// NewSyncer creates a syncer
+func NewSyncer(a *config.AppConfig, db repository.DatabaseRepo) *Syncer {
+	return &Syncer{
+		App:    a,
+		DB:     db,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SyncAll syncs every feed, logging the ones that fail
+func (s *Syncer) SyncAll() {
+	feeds, err := s.DB.AllICalFeeds()
+	if err != nil {
+		s.App.ErrorLog.Println(err)
+		return
+	}
+
+	for _, f := range feeds {
+		res, err := s.Sync(f)
+		if err != nil {
+			s.App.ErrorLog.Printf("ical import of %q for %s: %v", f.Name, f.Room.RoomName, err)
+			continue
+		}
+
+		if res != (Result{}) {
+			s.App.InfoLog.Printf("ical import of %q for %s: %d new, %d moved, %d removed",
+				f.Name, f.Room.RoomName, res.Created, res.Updated, res.Removed)
+		}
+	}
+}
+
+// Sync brings the external bookings of one feed in line with what it publishes now,
+// and records the outcome on the feed
+func (s *Syncer) Sync(feed models.ICalFeed) (Result, error) {
+	res, err := s.sync(feed)
+	if res.Removed > 0 && s.Freed != nil {
+		s.Freed()
+	}
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+
+	if uerr := s.DB.UpdateICalFeedSynced(feed.ID, msg); uerr != nil && err == nil {
+		err = uerr
+	}
+
+	return res, err
+}
+
+func (s *Syncer) sync(feed models.ICalFeed) (Result, error) {
+	var res Result
+
+	c, err := s.fetch(feed.URL)
+	if err != nil {
+		return res, err
+	}
+	if c.Skipped > 0 {
+		s.App.ErrorLog.Printf("ical import of %q: skipped %d events without a UID", feed.Name, c.Skipped)
+	}
+
+	existing, err := s.DB.GetRestrictionsForICalFeed(feed.ID)
+	if err != nil {
+		return res, err
+	}
+
+	create, update, remove := plan(feed, existing, c.Events, time.Now())
+
+	for _, r := range create {
+		if err := s.DB.InsertExternalBooking(r); err != nil {
+			return res, err
+		}
+		res.Created++
+	}
+
+	for _, r := range update {
+		if err := s.DB.UpdateExternalBooking(r); err != nil {
+			return res, err
+		}
+		res.Updated++
+	}
+
+	for _, r := range remove {
+		if err := s.DB.DeleteExternalBooking(r.ID); err != nil {
+			return res, err
+		}
+		res.Removed++
+	}
+
+	return res, nil
+}
+
+func (s *Syncer) fetch(url string) (ical.Calendar, error) {
+	resp, err := s.Client.Get(url)
+	if err != nil {
+		return ical.Calendar{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ical.Calendar{}, fmt.Errorf("feed returned %s", resp.Status)
+	}
+
+	body := io.LimitReader(resp.Body, maxFeedBytes)
+
+	return ical.Decode(body)
+}
+
+// plan works out which restrictions to create, move and remove so the feed's bookings match events.
+// Bookings that ended before today are left alone, as feeds usually drop them
+func plan(feed models.ICalFeed, existing []models.RoomRestriction, events []ical.Event, now time.Time) (create, update, remove []models.RoomRestriction) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	current := make(map[string]models.RoomRestriction)
+	for _, r := range existing {
+		current[r.ExternalUID] = r
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range events {
+		if seen[e.UID] || strings.HasSuffix(e.UID, ownUIDSuffix) {
+			continue
+		}
+		seen[e.UID] = true
+
+		r, ok := current[e.UID]
+		switch {
+		case !ok:
+			create = append(create, models.RoomRestriction{
+				StartDate:     e.Start,
+				EndDate:       e.End,
+				RoomID:        feed.RoomID,
+				RestrictionID: models.RestrictionExternal,
+				ICalFeedID:    feed.ID,
+				ExternalUID:   e.UID,
+			})
+		case !r.StartDate.Equal(e.Start) || !r.EndDate.Equal(e.End):
+			r.StartDate = e.Start
+			r.EndDate = e.End
+			update = append(update, r)
+		}
+	}
+
+	for _, r := range existing {
+		if !seen[r.ExternalUID] && !r.EndDate.Before(today) {
+			remove = append(remove, r)
+		}
+	}
+
+	return create, update, remove
+}
@@ -0,0 +1,117 @@
+package icalsync
+
+import (
+	"BookingProject/pkg/config"
+	"BookingProject/pkg/ical"
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/repository/dbrepo"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPlan(t *testing.T) {
+	feed := models.ICalFeed{ID: 4, RoomID: 2}
+	now := day(2050, 6, 1)
+
+	existing := []models.RoomRestriction{
+		{ID: 1, ExternalUID: "same", StartDate: day(2050, 6, 10), EndDate: day(2050, 6, 12)},
+		{ID: 2, ExternalUID: "moved", StartDate: day(2050, 6, 20), EndDate: day(2050, 6, 22)},
+		{ID: 3, ExternalUID: "gone", StartDate: day(2050, 7, 1), EndDate: day(2050, 7, 3)},
+		{ID: 4, ExternalUID: "past", StartDate: day(2050, 5, 1), EndDate: day(2050, 5, 3)},
+	}
+
+	events := []ical.Event{
+		{UID: "same", Start: day(2050, 6, 10), End: day(2050, 6, 12)},
+		{UID: "moved", Start: day(2050, 6, 21), End: day(2050, 6, 24)},
+		{UID: "new", Start: day(2050, 8, 1), End: day(2050, 8, 5)},
+		{UID: "new", Start: day(2050, 8, 1), End: day(2050, 8, 5)},
+		{UID: "reservation-9@bookings", Start: day(2050, 9, 1), End: day(2050, 9, 2)},
+	}
+
+	create, update, remove := plan(feed, existing, events, now)
+
+	if len(create) != 1 || create[0].ExternalUID != "new" || create[0].RoomID != 2 || create[0].ICalFeedID != 4 ||
+		create[0].RestrictionID != models.RestrictionExternal {
+		t.Errorf("expected one new external booking, got %+v", create)
+	}
+
+	if len(update) != 1 || update[0].ID != 2 || !update[0].StartDate.Equal(day(2050, 6, 21)) || !update[0].EndDate.Equal(day(2050, 6, 24)) {
+		t.Errorf("expected restriction 2 to move to the 21st-24th, got %+v", update)
+	}
+
+	if len(remove) != 1 || remove[0].ID != 3 {
+		t.Errorf("expected only restriction 3 to be removed, got %+v", remove)
+	}
+}
+
+func TestSync(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/listing.ics":
+			w.Header().Set("Content-Type", "text/calendar")
+			fmt.Fprint(w, "BEGIN:VCALENDAR\r\n"+
+				"BEGIN:VEVENT\r\nUID:a@other\r\nDTSTART;VALUE=DATE:20500101\r\nDTEND;VALUE=DATE:20500103\r\nEND:VEVENT\r\n"+
+				"BEGIN:VEVENT\r\nUID:b@other\r\nDTSTART;VALUE=DATE:20500110\r\nDTEND;VALUE=DATE:20500111\r\nEND:VEVENT\r\n"+
+				"END:VCALENDAR\r\n")
+		case "/no-uid.ics":
+			fmt.Fprint(w, "BEGIN:VCALENDAR\r\n"+
+				"BEGIN:VEVENT\r\nUID:a@other\r\nDTSTART;VALUE=DATE:20500101\r\nDTEND;VALUE=DATE:20500103\r\nEND:VEVENT\r\n"+
+				"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20500110\r\nDTEND;VALUE=DATE:20500111\r\nEND:VEVENT\r\n"+
+				"END:VCALENDAR\r\n")
+		case "/html":
+			fmt.Fprint(w, "<html></html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	app := config.AppConfig{
+		InfoLog:  log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+		ErrorLog: log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime),
+	}
+	s := NewSyncer(&app, dbrepo.NewTestingRepo(&app))
+
+	freed := 0
+	s.Freed = func() { freed++ }
+
+	var tests = []struct {
+		name     string
+		feedID   int
+		path     string
+		expected Result
+		fails    bool
+	}{
+		{"feed", 1, "/listing.ics", Result{Created: 2}, false},
+		{"booking gone from the feed", 2, "/listing.ics", Result{Created: 2, Removed: 1}, false},
+		{"event without a uid", 1, "/no-uid.ics", Result{Created: 1}, false},
+		{"not found", 1, "/missing.ics", Result{}, true},
+		{"not a calendar", 1, "/html", Result{}, true},
+	}
+
+	for _, e := range tests {
+		res, err := s.Sync(models.ICalFeed{ID: e.feedID, RoomID: 1, URL: srv.URL + e.path})
+		if e.fails && err == nil {
+			t.Errorf("%s: expected an error", e.name)
+		}
+		if !e.fails && err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+		}
+		if res != e.expected {
+			t.Errorf("%s: expected %+v, got %+v", e.name, e.expected, res)
+		}
+	}
+
+	if freed != 1 {
+		t.Errorf("expected the waitlist to be told about freed nights once, got %d", freed)
+	}
+}
@@ -0,0 +1,111 @@
+// Package invoices builds receipts for reservations and lays them out as pdf
+package invoices
+
+import (
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/pdf"
+	"BookingProject/pkg/pricing"
+	"fmt"
+	"strings"
+)
+
+// Issuer is the business named at the top of every invoice
+const Issuer = "Fort Smythe Bed and Breakfast"
+
+// FromQuote builds the invoice for a reservation from the price of its stay
+func FromQuote(reservationID int, q pricing.Quote) models.Invoice {
+	inv := models.Invoice{
+		ReservationID: reservationID,
+		Total:         q.Total,
+		Currency:      pricing.Currency,
+	}
+
+	for _, l := range q.Lines {
+		inv.Lines = append(inv.Lines, models.InvoiceLine{
+			Description: l.Description,
+			Quantity:    l.Quantity,
+			UnitAmount:  l.UnitAmount,
+			Amount:      l.Amount,
+		})
+	}
+
+	return inv
+}
+
+// FileName is what a downloaded invoice is saved as
+func FileName(inv models.Invoice) string {
+	return inv.Number() + ".pdf"
+}
+
+// column positions, in points from the left of the page
+const (
+	left     = 50.0
+	right    = pdf.PageWidth - 50
+	colQty   = 360.0
+	colUnit  = 450.0
+	pageFoot = pdf.PageHeight - 60
+)
+
+// PDF lays out an invoice. The invoice needs its reservation and payments filled in
+func PDF(inv models.Invoice) []byte {
+	doc := pdf.New()
+	res := inv.Reservation
+
+	doc.Text(left, 70, pdf.Bold, 20, "Invoice")
+	doc.TextRight(right, 70, pdf.Bold, 12, inv.Number())
+	doc.Text(left, 92, pdf.Regular, 10, Issuer)
+	doc.TextRight(right, 92, pdf.Regular, 10, "Issued "+inv.CreatedAt.Format("January 2, 2006"))
+
+	doc.Text(left, 130, pdf.Bold, 10, "Billed to")
+	doc.Text(left, 145, pdf.Regular, 10, strings.TrimSpace(res.FirstName+" "+res.LastName))
+	doc.Text(left, 159, pdf.Regular, 10, res.Email)
+	doc.Text(colQty, 130, pdf.Bold, 10, "Reservation")
+	doc.Text(colQty, 145, pdf.Regular, 10, fmt.Sprintf("#%d, %s", res.ID, res.Room.RoomName))
+	doc.Text(colQty, 159, pdf.Regular, 10, res.StartDate.Format("Jan 2, 2006")+" to "+res.EndDate.Format("Jan 2, 2006"))
+
+	y := 200.0
+	header := func() {
+		doc.Text(left, y, pdf.Bold, 10, "Description")
+		doc.TextRight(colQty+30, y, pdf.Bold, 10, "Qty")
+		doc.TextRight(colUnit+40, y, pdf.Bold, 10, "Unit")
+		doc.TextRight(right, y, pdf.Bold, 10, "Amount")
+		doc.Line(left, y+6, right, y+6)
+		y += 22
+	}
+
+	//long invoices carry on over a new page, repeating the column headings
+	row := func(font pdf.Font, desc, qty, unit, amount string) {
+		if y > pageFoot {
+			doc.AddPage()
+			y = 70
+			header()
+		}
+		doc.Text(left, y, font, 10, desc)
+		doc.TextRight(colQty+30, y, font, 10, qty)
+		doc.TextRight(colUnit+40, y, font, 10, unit)
+		doc.TextRight(right, y, font, 10, amount)
+		y += 18
+	}
+
+	header()
+	for _, l := range inv.Lines {
+		row(pdf.Regular, l.Description, fmt.Sprint(l.Quantity), pricing.Money(l.UnitAmount), pricing.Money(l.Amount))
+	}
+
+	doc.Line(colUnit-60, y-8, right, y-8)
+	y += 4
+	row(pdf.Bold, "", "", "Total", pricing.Money(inv.Total))
+
+	for _, p := range inv.Payments {
+		if p.Status != models.PaymentPaid {
+			continue
+		}
+		row(pdf.Regular, "", "", "Paid "+p.PaidAt.Format("Jan 2"), "-"+pricing.Money(p.Amount))
+	}
+
+	row(pdf.Bold, "", "", "Balance due", pricing.Money(inv.Balance()))
+
+	doc.Text(left, pdf.PageHeight-40, pdf.Regular, 8, "All amounts are in "+inv.Currency+".")
+
+	return doc.Bytes()
+}
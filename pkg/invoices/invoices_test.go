@@ -0,0 +1,64 @@
+package invoices
+
+import (
+	"BookingProject/pkg/models"
+	"BookingProject/pkg/pricing"
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFromQuote(t *testing.T) {
+	room := models.Room{RoomName: "General's Quarters", Price: 8900}
+	start := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := pricing.QuoteStay(room, start, start.AddDate(0, 0, 2), nil, nil)
+
+	inv := FromQuote(7, q)
+
+	if inv.ReservationID != 7 || inv.Total != 17800 || inv.Currency != pricing.Currency {
+		t.Errorf("unexpected invoice %+v", inv)
+	}
+
+	if len(inv.Lines) != 1 || inv.Lines[0].Quantity != 2 || inv.Lines[0].UnitAmount != 8900 || inv.Lines[0].Amount != 17800 {
+		t.Errorf("unexpected lines %+v", inv.Lines)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	inv := models.Invoice{
+		ID:       12,
+		Total:    17800,
+		Currency: "USD",
+		Lines:    []models.InvoiceLine{{Description: "General's Quarters", Quantity: 2, UnitAmount: 8900, Amount: 17800}},
+		Reservation: models.Reservation{
+			ID:        7,
+			FirstName: "John",
+			LastName:  "Smith",
+			Room:      models.Room{RoomName: "General's Quarters"},
+		},
+		Payments: []models.Payment{
+			{Amount: 3560, Status: models.PaymentPaid},
+			{Amount: 14240, Status: models.PaymentFailed},
+		},
+	}
+
+	out := PDF(inv)
+
+	if !bytes.HasPrefix(out, []byte("%PDF-")) {
+		t.Fatal("not a pdf")
+	}
+
+	for _, want := range []string{"INV-000012", "John Smith", "$178.00", "-$35.60", "$142.40"} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("expected %q in the pdf", want)
+		}
+	}
+
+	if bytes.Contains(out, []byte("-$142.40")) {
+		t.Error("failed payments shouldn't be listed")
+	}
+
+	if FileName(inv) != "INV-000012.pdf" {
+		t.Errorf("unexpected file name %s", FileName(inv))
+	}
+}
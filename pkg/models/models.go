@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -11,6 +12,7 @@ type User struct {
 	Email       string
 	Password    string
 	AccessLevel int
+	ICalToken   string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
@@ -18,6 +20,9 @@ type User struct {
 type Room struct {
 	ID        int
 	RoomName  string
+	ICalToken string
+	Price     int // per night, in cents
+	MinNights int // the shortest stay guests can book
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -29,6 +34,14 @@ type Restriction struct {
 	UpdatedAt       time.Time
 }
 
+// ids of the rows in the restrictions table
+const (
+	RestrictionReservation = 1
+	RestrictionOwnerBlock  = 2
+	RestrictionExternal    = 3
+	RestrictionHold        = 4
+)
+
 type Reservation struct {
 	ID        int
 	FirstName string
@@ -42,8 +55,91 @@ type Reservation struct {
 	UpdatedAt time.Time
 	Room      Room
 	Processed int
+	Status    string
+
+	// PromoCodeID is the promo code the guest booked with, if any
+	PromoCodeID int
+	PromoCode   PromoCode
+
+	// Source is how the reservation was made. MinStayOverride is why staff booked it for fewer nights
+	// than the room's minimum, if they did
+	Source          string
+	MinStayOverride string
+
+	// CheckedInAt and CheckedOutAt are when the front desk checked the guest in and out, if it has
+	CheckedInAt  time.Time
+	CheckedOutAt time.Time
+
+	// SpecialRequests is what the guest asked for when booking. The guest sees it, unlike staff notes
+	SpecialRequests string
+}
+
+// Confirmed reports whether the reservation is confirmed, including once the guest has checked in or out
+func (r Reservation) Confirmed() bool {
+	return r.Status == ReservationConfirmed || r.Status == ReservationCheckedIn || r.Status == ReservationCheckedOut
 }
 
+// Open reports whether the reservation can still be moved or cancelled, which it can't once it's been
+// cancelled or the guest has checked in
+func (r Reservation) Open() bool {
+	return r.Status == ReservationPending || r.Status == ReservationConfirmed
+}
+
+// where reservations come from. Staff book the phone, walk-in and email ones, and imported ones were
+// brought over from somewhere else
+const (
+	SourceWebsite = "website"
+	SourceAPI     = "api"
+	SourcePhone   = "phone"
+	SourceWalkIn  = "walk-in"
+	SourceEmail   = "email"
+	SourceImport  = "import"
+)
+
+// reservation statuses. Pending reservations hold the room while the guest pays, and confirmed ones are
+// checked in and then out by the front desk
+const (
+	ReservationPending    = "pending"
+	ReservationConfirmed  = "confirmed"
+	ReservationCancelled  = "cancelled"
+	ReservationCheckedIn  = "checked-in"
+	ReservationCheckedOut = "checked-out"
+)
+
+// ReservationFilter picks out, orders and pages the reservations staff look through. Zero fields don't
+// filter, and a zero Limit returns every match
+type ReservationFilter struct {
+	Search  string // part of the guest's name, email or phone
+	RoomID  int
+	From    time.Time // stays still going on this day or later
+	To      time.Time // stays that start before this day
+	Status  string
+	Source  string
+	NewOnly bool // only the ones not processed yet
+	Sort    string
+	Desc    bool
+	Offset  int
+	Limit   int
+}
+
+// ReservationAmounts is a reservation with what it's been invoiced and what's been paid towards it, as
+// exported for the books. Invoiced is zero until there's an invoice
+type ReservationAmounts struct {
+	Reservation
+	Invoiced int
+	Paid     int
+	Currency string
+}
+
+// what reservations can be sorted by
+const (
+	SortArrival   = "arrival"
+	SortDeparture = "departure"
+	SortLastName  = "last_name"
+	SortRoom      = "room"
+	SortBooked    = "booked"
+)
+
 type RoomRestriction struct {
 	ID            int
 	StartDate     time.Time
@@ -53,14 +149,349 @@ type RoomRestriction struct {
 	RoomID        int
 	ReservationID int
 	RestrictionID int
+	ICalFeedID    int
+	ExternalUID   string
+	ExpiresAt     time.Time // holds, and reservations waiting to be paid for, expire
+	Reason        string    // why an owner block is there
 	Room          Room
 	Reservation   Reservation
 	Restriction   Restriction
 }
 
+// ICalFeed is a calendar on another booking site whose bookings are imported as external bookings
+type ICalFeed struct {
+	ID           int
+	RoomID       int
+	Name         string
+	URL          string
+	LastSyncedAt time.Time
+	LastError    string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Room         Room
+}
+
+// BookingConflict is an imported external booking that overlaps a restriction made here
+type BookingConflict struct {
+	Feed     ICalFeed
+	External RoomRestriction
+	Local    RoomRestriction
+}
+
+// RoomOccupancy is how a room's nights over a range of dates went. Nights are every night in the range,
+// Booked the ones reservations here or on other sites had, and Blocked the ones it was blocked for
+type RoomOccupancy struct {
+	Room    Room
+	Nights  int
+	Booked  int
+	Blocked int
+}
+
+// Rate is the share of the nights the room could be booked for that it was, in hundredths of a percent
+func (o RoomOccupancy) Rate() int {
+	open := o.Nights - o.Blocked
+	if open <= 0 {
+		return 0
+	}
+	return o.Booked * 10000 / open
+}
+
+// StayStats sums up the reservations arriving over a range of dates. Lengths and lead times are
+// averaged over those that weren't cancelled
+type StayStats struct {
+	Reservations  int
+	Cancellations int
+	AverageNights float64
+	AverageLead   float64 // days from booking to arrival
+}
+
+// MonthRevenue is what the reservations arriving in a month were invoiced, and what's been paid towards
+// them, in cents
+type MonthRevenue struct {
+	Month        time.Time
+	Reservations int
+	Invoiced     int
+	Paid         int
+}
+
+// DayCounts is how many guests arrive, leave and stay over on a day
+type DayCounts struct {
+	Arrivals   int
+	Departures int
+	InHouse    int
+}
+
+// Payment is money taken, or being taken, for a reservation. Amounts are in cents
+type Payment struct {
+	ID            int
+	ReservationID int
+	Provider      string
+	ProviderRef   string
+	Kind          string
+	Amount        int
+	Currency      string
+	Status        string
+	PaidAt        time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// payment kinds
+const (
+	PaymentDeposit = "deposit"
+	PaymentFull    = "full"
+)
+
+// payment statuses
+const (
+	PaymentPending = "pending"
+	PaymentPaid    = "paid"
+	PaymentFailed  = "failed"
+)
+
+// Invoice is a numbered receipt for a reservation. Its lines are copied from the price of the
+// stay when it is issued, so later price changes don't alter it. Amounts are in cents
+type Invoice struct {
+	ID            int
+	ReservationID int
+	Total         int
+	Currency      string
+	Lines         []InvoiceLine
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Reservation   Reservation
+	Payments      []Payment
+}
+
+// InvoiceLine is one charge on an invoice
+type InvoiceLine struct {
+	ID          int
+	InvoiceID   int
+	Description string
+	Quantity    int
+	UnitAmount  int
+	Amount      int
+}
+
+// Number is the invoice number printed on it
+func (i Invoice) Number() string {
+	return fmt.Sprintf("INV-%06d", i.ID)
+}
+
+// Paid adds up the payments that went through
+func (i Invoice) Paid() int {
+	paid := 0
+	for _, p := range i.Payments {
+		if p.Status == PaymentPaid {
+			paid += p.Amount
+		}
+	}
+	return paid
+}
+
+// Balance is what is still owed on the invoice
+func (i Invoice) Balance() int {
+	return i.Total - i.Paid()
+}
+
+// ChargeRule is a tax or fee added to the price of a stay. Flat amounts are in cents and
+// percentages in hundredths of a percent, so 1250 is 12.5%
+type ChargeRule struct {
+	ID        int
+	Name      string
+	Kind      string
+	Method    string
+	Per       string
+	Amount    int
+	RoomID    int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Room      Room
+}
+
+// charge kinds. Taxes are worked out after fees, so a percentage tax also applies to the fees
+const (
+	ChargeFee = "fee"
+	ChargeTax = "tax"
+)
+
+// how a charge is worked out
+const (
+	ChargePercent = "percent"
+	ChargeFlat    = "flat"
+)
+
+// what a flat charge is counted against
+const (
+	ChargePerNight = "night"
+	ChargePerStay  = "stay"
+)
+
+// PromoCode is a discount guests enter when they book. Like charges, fixed amounts are in cents and
+// percentages in hundredths of a percent, and Method is ChargePercent or ChargeFlat. Zero values for the
+// window, room, uses and nights mean no limit
+type PromoCode struct {
+	ID          int
+	Code        string
+	Description string
+	Method      string
+	Amount      int
+	ValidFrom   time.Time
+	ValidTo     time.Time
+	RoomID      int
+	MaxUses     int
+	MinNights   int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Room        Room
+
+	// Uses counts the confirmed reservations made with the code
+	Uses int
+}
+
+// WaitlistEntry is a guest waiting for a room to free up for their dates. A zero RoomID means any room
+// will do, and NotifiedAt is set once they've been sent a link to book
+type WaitlistEntry struct {
+	ID         int
+	FirstName  string
+	LastName   string
+	Email      string
+	StartDate  time.Time
+	EndDate    time.Time
+	RoomID        int
+	NotifiedAt    time.Time
+	OfferedRoomID int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Room          Room
+}
+
+// Attachment is a file sent along with an email
+type Attachment struct {
+	Name     string
+	MimeType string
+	Data     []byte
+}
+
 type MailData struct {
-	To      string
-	From    string
-	Subject string
-	Content string
+	To          string
+	From        string
+	Subject     string
+	Content     string
+	Attachments []Attachment
+}
+
+// APIKey lets a machine client use the json api. Only a hash of the key is stored
+type APIKey struct {
+	ID         int
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     []string
+	UserID     int
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// api key scopes
+const (
+	ScopeRoomsRead         = "rooms:read"
+	ScopeAvailabilityRead  = "availability:read"
+	ScopeReservationsRead  = "reservations:read"
+	ScopeReservationsWrite = "reservations:write"
+)
+
+// APIScopes lists every scope a key can be given
+var APIScopes = []string{
+	ScopeRoomsRead,
+	ScopeAvailabilityRead,
+	ScopeReservationsRead,
+	ScopeReservationsWrite,
+}
+
+// HasScope reports whether the key was granted scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked reports whether the key has been revoked
+func (k APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// ReservationNote is a note staff left on a reservation, which guests never see. A reply has the note
+// it answers as its parent, and notes are threaded one deep, so a top level note carries its replies
+type ReservationNote struct {
+	ID            int
+	ReservationID int
+	ParentID      int
+	UserID        int
+	Author        User
+	Body          string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Replies       []ReservationNote
+}
+
+// AuditEntry is a change someone made from the admin pages. Before and After are what was changed, as
+// json, left empty when it didn't exist before or doesn't after
+type AuditEntry struct {
+	ID        int
+	UserID    int
+	User      User
+	Action    string
+	Entity    string
+	EntityID  int
+	Before    string
+	After     string
+	IP        string
+	CreatedAt time.Time
+}
+
+// what an audit entry says was done
+const (
+	AuditCreate   = "create"
+	AuditUpdate   = "update"
+	AuditDelete   = "delete"
+	AuditProcess  = "process"
+	AuditMove     = "move"
+	AuditImport   = "import"
+	AuditCheckIn  = "check-in"
+	AuditCheckOut = "check-out"
+	AuditRevoke   = "revoke"
+	AuditSync     = "sync"
+	AuditNewToken = "new-token"
+)
+
+// what an audit entry says it was done to
+const (
+	EntityReservation = "reservation"
+	EntityBlock       = "block"
+	EntityAPIKey      = "api_key"
+	EntityICalFeed    = "ical_feed"
+	EntityRoom        = "room"
+	EntityUser        = "user"
+	EntityCharge      = "charge"
+	EntityPromoCode   = "promo_code"
+	EntityNote        = "reservation_note"
+)
+
+// AuditFilter picks out and pages the audit log, newest first. Zero fields don't filter, and a zero Limit
+// returns every entry
+type AuditFilter struct {
+	UserID   int
+	Action   string
+	Entity   string
+	EntityID int
+	From     time.Time
+	To       time.Time
+	Offset   int
+	Limit    int
 }
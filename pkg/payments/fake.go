@@ -0,0 +1,155 @@
+package payments
+
+import (
+	"BookingProject/pkg/models"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FakeSignatureHeader carries the signature of a fake webhook
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakeGateway is an in-process provider for development and tests. Its checkout page is served by the
+// app itself, and it remembers checkouts in memory, so they are lost on restart
+type FakeGateway struct {
+	secret []byte
+	client *http.Client
+
+	mu        sync.Mutex
+	checkouts map[string]CheckoutRequest
+}
+
+// NewFakeGateway creates a fake gateway with a fresh signing secret
+func NewFakeGateway() *FakeGateway {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+
+	return &FakeGateway{
+		secret:    secret,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		checkouts: make(map[string]CheckoutRequest),
+	}
+}
+
+// Name identifies the fake gateway
+func (g *FakeGateway) Name() string {
+	return "fake"
+}
+
+// CreateCheckout remembers the payment and sends the guest to the fake checkout page
+func (g *FakeGateway) CreateCheckout(req CheckoutRequest) (Checkout, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return Checkout{}, err
+	}
+	ref := "fake_" + hex.EncodeToString(b)
+
+	g.mu.Lock()
+	g.checkouts[ref] = req
+	g.mu.Unlock()
+
+	return Checkout{Ref: ref, RedirectURL: "/payments/fake/" + ref}, nil
+}
+
+// Lookup returns a checkout that hasn't been completed yet
+func (g *FakeGateway) Lookup(ref string) (CheckoutRequest, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	req, ok := g.checkouts[ref]
+	return req, ok
+}
+
+// Complete finishes a checkout the way a real provider would, by posting a signed event to its callback url
+func (g *FakeGateway) Complete(ref string, paid bool) error {
+	req, ok := g.Lookup(ref)
+	if !ok {
+		return fmt.Errorf("payments: no fake checkout %s", ref)
+	}
+
+	e := Event{Ref: ref, Status: models.PaymentFailed, Amount: req.Amount, Currency: req.Currency}
+	if paid {
+		e.Status = models.PaymentPaid
+	}
+
+	body, sig, err := g.Sign(e)
+	if err != nil {
+		return err
+	}
+
+	callback, err := http.NewRequest("POST", req.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	callback.Header.Set("Content-Type", "application/json")
+	callback.Header.Set(FakeSignatureHeader, sig)
+
+	resp, err := g.client.Do(callback)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("payments: webhook returned %s", resp.Status)
+	}
+
+	g.mu.Lock()
+	delete(g.checkouts, ref)
+	g.mu.Unlock()
+
+	return nil
+}
+
+// Sign encodes an event and signs it, returning the webhook body and its signature header
+func (g *FakeGateway) Sign(e Event) ([]byte, string, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return body, g.signature(body), nil
+}
+
+// ParseWebhook checks the signature of a fake webhook and decodes its event
+func (g *FakeGateway) ParseWebhook(req *http.Request) (Event, error) {
+	var e Event
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, 64*1024))
+	if err != nil {
+		return e, err
+	}
+
+	if !hmac.Equal([]byte(req.Header.Get(FakeSignatureHeader)), []byte(g.signature(body))) {
+		return e, ErrBadSignature
+	}
+
+	err = json.Unmarshal(body, &e)
+	if err != nil {
+		return e, err
+	}
+
+	if e.Status != models.PaymentPaid && e.Status != models.PaymentFailed {
+		return e, errors.New("payments: unknown event status " + e.Status)
+	}
+
+	return e, nil
+}
+
+func (g *FakeGateway) signature(body []byte) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
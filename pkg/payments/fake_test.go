@@ -0,0 +1,83 @@
+package payments
+
+import (
+	"BookingProject/pkg/models"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFakeWebhookSignature(t *testing.T) {
+	g := NewFakeGateway()
+
+	body, sig, err := g.Sign(Event{Ref: "fake_1", Status: models.PaymentPaid, Amount: 1790, Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name  string
+		body  []byte
+		sig   string
+		valid bool
+	}{
+		{"signed", body, sig, true},
+		{"no signature", body, "", false},
+		{"tampered", bytes.Replace(body, []byte("1790"), []byte("1"), 1), sig, false},
+		{"other gateway", body, NewFakeGateway().signature(body), false},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/payments/webhook/fake", bytes.NewReader(e.body))
+		req.Header.Set(FakeSignatureHeader, e.sig)
+
+		ev, err := g.ParseWebhook(req)
+		if e.valid && (err != nil || ev.Ref != "fake_1" || ev.Amount != 1790) {
+			t.Errorf("%s: expected the event back, got %+v, %v", e.name, ev, err)
+		}
+		if !e.valid && err != ErrBadSignature {
+			t.Errorf("%s: expected ErrBadSignature, got %v", e.name, err)
+		}
+	}
+}
+
+func TestFakeComplete(t *testing.T) {
+	g := NewFakeGateway()
+
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		got, err = g.ParseWebhook(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := g.CreateCheckout(CheckoutRequest{PaymentID: 1, Amount: 5000, Currency: "USD", CallbackURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.RedirectURL != "/payments/fake/"+c.Ref {
+		t.Errorf("unexpected redirect url %s", c.RedirectURL)
+	}
+
+	err = g.Complete(c.Ref, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Ref != c.Ref || got.Status != models.PaymentPaid || got.Amount != 5000 {
+		t.Errorf("webhook got %+v", got)
+	}
+
+	if _, ok := g.Lookup(c.Ref); ok {
+		t.Error("completed checkout is still pending")
+	}
+
+	if err := g.Complete(c.Ref, true); err == nil {
+		t.Error("expected an error completing a checkout twice")
+	}
+}
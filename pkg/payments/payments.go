@@ -0,0 +1,52 @@
+// Package payments talks to payment providers. Each provider is a Gateway; checkouts are
+// started with CreateCheckout and the provider reports the outcome later through a webhook
+package payments
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrBadSignature is returned by ParseWebhook when a callback isn't signed by the provider
+var ErrBadSignature = errors.New("payments: bad webhook signature")
+
+// Gateway is a payment provider
+type Gateway interface {
+	// Name identifies the provider in urls and in the payments table
+	Name() string
+
+	// CreateCheckout starts taking a payment and returns where to send the guest to pay
+	CreateCheckout(req CheckoutRequest) (Checkout, error)
+
+	// ParseWebhook checks a callback from the provider and returns the event in it
+	ParseWebhook(req *http.Request) (Event, error)
+}
+
+// CheckoutRequest describes a payment to take
+type CheckoutRequest struct {
+	PaymentID   int
+	Amount      int
+	Currency    string
+	Description string
+	Email       string
+
+	// ReturnURL is where the guest comes back to once they are done with the provider
+	ReturnURL string
+
+	// CallbackURL is where the provider posts its webhook
+	CallbackURL string
+}
+
+// Checkout is a payment started with a provider
+type Checkout struct {
+	Ref         string
+	RedirectURL string
+}
+
+// Event is the outcome of a payment, as reported by a provider
+type Event struct {
+	Ref      string `json:"ref"`
+	Status   string `json:"status"`
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
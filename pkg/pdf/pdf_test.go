@@ -0,0 +1,78 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestBytes(t *testing.T) {
+	d := New()
+	d.Text(50, 50, Bold, 18, "Invoice (copy)")
+	d.Line(50, 60, 545, 60)
+	d.AddPage()
+	d.TextRight(545, 50, Regular, 10, "$1,234.00")
+
+	out := d.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("output isn't framed as a pdf")
+	}
+
+	if !bytes.Contains(out, []byte(`(Invoice \(copy\)) Tj`)) {
+		t.Error("parentheses in text weren't escaped")
+	}
+
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Error("expected two pages")
+	}
+
+	//every entry in the cross reference table has to point at the start of its object
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatal("startxref doesn't point at the xref table")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+	if len(entries) != 8 {
+		t.Fatalf("expected 8 objects, got %d", len(entries))
+	}
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		want := fmt.Sprintf("%d 0 obj", i+1)
+		if !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Errorf("xref entry %d doesn't point at %q", i+1, want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	var tests = []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{`a\b`, `a\\b`},
+		{"café", `caf\351`},
+		{"line\nbreak", "line break"},
+		{"snow ☃", "snow ?"},
+	}
+
+	for _, e := range tests {
+		if got := escape(e.in); got != e.want {
+			t.Errorf("escape(%q) = %q, wanted %q", e.in, got, e.want)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	if w := Width("100", 10); w != 16.68 {
+		t.Errorf("expected 16.68, got %v", w)
+	}
+}
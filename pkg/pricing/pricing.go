@@ -0,0 +1,225 @@
+// Package pricing works out what a stay costs. All amounts are in cents
+package pricing
+
+import (
+	"BookingProject/pkg/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Currency is the currency every price is in
+const Currency = "USD"
+
+// DepositPercent is the share of the total taken at checkout when a guest pays a deposit
+const DepositPercent = 20
+
+// Quote is the price of a stay in one room
+type Quote struct {
+	Nights      int
+	NightlyRate int
+	Total       int
+
+	// Lines break the total down, the way it is shown on invoices
+	Lines []Line
+}
+
+// Line is one charge in a quote
+type Line struct {
+	Kind        string
+	Description string
+	Quantity    int
+	UnitAmount  int
+	Amount      int
+}
+
+// kinds of the lines for the room itself and for a promo code; fees and taxes use the kind of their rule
+const (
+	LineRoom     = "room"
+	LineDiscount = "discount"
+)
+
+// QuoteStay prices a stay from start to the departure day end, taking off promo, which may be nil,
+// then adding the fees and taxes among rules that apply to the room
+func QuoteStay(room models.Room, start, end time.Time, rules []models.ChargeRule, promo *models.PromoCode) Quote {
+	nights := Nights(start, end)
+
+	q := Quote{
+		Nights:      nights,
+		NightlyRate: room.Price,
+	}
+
+	q.add(Line{
+		Kind:        LineRoom,
+		Description: fmt.Sprintf("%s, %s to %s", room.RoomName, start.Format("Jan 2, 2006"), end.Format("Jan 2, 2006")),
+		Quantity:    nights,
+		UnitAmount:  room.Price,
+		Amount:      nights * room.Price,
+	})
+
+	if promo != nil {
+		q.add(discount(*promo, q.Total))
+	}
+
+	//fees go first, so that percentage taxes are charged on the room and the fees together
+	for _, kind := range []string{models.ChargeFee, models.ChargeTax} {
+		base := q.Total
+		for _, r := range rules {
+			if r.Kind != kind || (r.RoomID != 0 && r.RoomID != room.ID) {
+				continue
+			}
+			q.add(charge(r, nights, base))
+		}
+	}
+
+	return q
+}
+
+func (q *Quote) add(l Line) {
+	q.Lines = append(q.Lines, l)
+	q.Total += l.Amount
+}
+
+// charge works out one rule. Percentages are of base and ignore per, since a share of every night
+// is the same as a share of the stay
+func charge(r models.ChargeRule, nights, base int) Line {
+	l := Line{Kind: r.Kind, Description: r.Name, Quantity: 1, UnitAmount: r.Amount}
+
+	switch {
+	case r.Method == models.ChargePercent:
+		l.Description = fmt.Sprintf("%s (%s)", r.Name, Percent(r.Amount))
+		l.UnitAmount = PercentOf(base, r.Amount)
+	case r.Per == models.ChargePerNight:
+		l.Quantity = nights
+	}
+
+	l.Amount = l.Quantity * l.UnitAmount
+	return l
+}
+
+// discount takes a promo code off the room charge. A fixed discount never takes it below zero
+func discount(p models.PromoCode, roomCharge int) Line {
+	l := Line{Kind: LineDiscount, Description: "Promo code " + p.Code, Quantity: 1}
+
+	off := p.Amount
+	if p.Method == models.ChargePercent {
+		l.Description = fmt.Sprintf("Promo code %s (%s off)", p.Code, Percent(p.Amount))
+		off = PercentOf(roomCharge, p.Amount)
+	}
+	if off > roomCharge {
+		off = roomCharge
+	}
+
+	l.UnitAmount = -off
+	l.Amount = -off
+	return l
+}
+
+// PromoProblem says why a promo code can't be used for a stay in a room, in words fit to show the
+// guest, or returns an empty string when it can
+func PromoProblem(p models.PromoCode, roomID int, start, end time.Time) string {
+	if p.MaxUses > 0 && p.Uses >= p.MaxUses {
+		return "This code has already been used up"
+	}
+
+	if p.RoomID != 0 && p.RoomID != roomID {
+		return "This code isn't valid for this room"
+	}
+
+	if n := Nights(start, end); n < p.MinNights {
+		return fmt.Sprintf("This code needs a stay of at least %d nights", p.MinNights)
+	}
+
+	//every night of the stay has to be inside the window, so the last one is the day before departure
+	if (!p.ValidFrom.IsZero() && start.Before(p.ValidFrom)) || (!p.ValidTo.IsZero() && end.AddDate(0, 0, -1).After(p.ValidTo)) {
+		return "This code isn't valid for these dates"
+	}
+
+	return ""
+}
+
+// MinStayProblem says why a stay is too short for a room's minimum, in words fit to show the guest, or
+// returns an empty string when it's long enough
+func MinStayProblem(room models.Room, start, end time.Time) string {
+	if room.MinNights > 1 && Nights(start, end) < room.MinNights {
+		return fmt.Sprintf("%s needs a stay of at least %d nights", room.RoomName, room.MinNights)
+	}
+	return ""
+}
+
+// PercentOf takes a percentage, in hundredths of a percent, of amount, rounding half a cent up
+func PercentOf(amount, hundredths int) int {
+	return (amount*hundredths + 5000) / 10000
+}
+
+// ParseAmount reads a number with up to two decimals, like 12.5 or 25.00, as hundredths. It is
+// how both dollar amounts and percentages are entered
+func ParseAmount(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+
+	if s == "" || s == "." || len(frac) > 2 || strings.HasPrefix(frac, "-") || strings.HasPrefix(frac, "+") {
+		return 0, fmt.Errorf("pricing: can't read %q as an amount", s)
+	}
+
+	w, err := strconv.Atoi(whole)
+	if err != nil || w < 0 || strings.HasPrefix(whole, "+") {
+		return 0, fmt.Errorf("pricing: can't read %q as an amount", s)
+	}
+
+	f := 0
+	if frac != "" {
+		f, err = strconv.Atoi(frac)
+		if err != nil {
+			return 0, fmt.Errorf("pricing: can't read %q as an amount", s)
+		}
+		if len(frac) == 1 {
+			f *= 10
+		}
+	}
+
+	return w*100 + f, nil
+}
+
+// Nights counts the nights between arrival and departure
+func Nights(start, end time.Time) int {
+	n := int(end.Sub(start).Hours()+12) / 24
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Deposit is the amount taken up front when paying a deposit, rounded up to the cent
+func (q Quote) Deposit() int {
+	return (q.Total*DepositPercent + 99) / 100
+}
+
+// Amount is what a payment of the given kind takes now
+func (q Quote) Amount(kind string) int {
+	if kind == models.PaymentDeposit {
+		return q.Deposit()
+	}
+	return q.Total
+}
+
+// Money formats an amount in cents as dollars
+func Money(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, cents/100, cents%100)
+}
+
+// Percent formats a percentage kept in hundredths of a percent, dropping trailing zeros
+func Percent(hundredths int) string {
+	s := fmt.Sprintf("%d.%02d", hundredths/100, hundredths%100)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return s + "%"
+}
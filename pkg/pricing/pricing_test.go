@@ -0,0 +1,256 @@
+package pricing
+
+import (
+	"BookingProject/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestQuoteStay(t *testing.T) {
+	room := models.Room{Price: 8950}
+	start := time.Date(2050, 3, 27, 0, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name    string
+		end     time.Time
+		nights  int
+		total   int
+		deposit int
+	}{
+		{"one night", start.AddDate(0, 0, 1), 1, 8950, 1790},
+		{"three nights", start.AddDate(0, 0, 3), 3, 26850, 5370},
+		{"no nights", start, 0, 0, 0},
+	}
+
+	for _, e := range tests {
+		q := QuoteStay(room, start, e.end, nil, nil)
+		if q.Nights != e.nights || q.Total != e.total || q.Deposit() != e.deposit {
+			t.Errorf("%s: expected %d nights, %d total, %d deposit, got %+v with %d deposit",
+				e.name, e.nights, e.total, e.deposit, q, q.Deposit())
+		}
+
+		sum := 0
+		for _, l := range q.Lines {
+			sum += l.Amount
+		}
+		if sum != q.Total {
+			t.Errorf("%s: lines add up to %d, not the total %d", e.name, sum, q.Total)
+		}
+	}
+}
+
+func TestDepositRoundsUp(t *testing.T) {
+	q := Quote{Total: 1001}
+	if q.Amount(models.PaymentDeposit) != 201 {
+		t.Errorf("expected a deposit of 201, got %d", q.Amount(models.PaymentDeposit))
+	}
+	if q.Amount(models.PaymentFull) != 1001 {
+		t.Errorf("expected full payment of 1001, got %d", q.Amount(models.PaymentFull))
+	}
+}
+
+func TestQuoteStayCharges(t *testing.T) {
+	room := models.Room{ID: 1, Price: 10000}
+	start := time.Date(2050, 3, 27, 0, 0, 0, 0, time.UTC)
+
+	rules := []models.ChargeRule{
+		{Name: "Occupancy tax", Kind: models.ChargeTax, Method: models.ChargePercent, Amount: 1000},
+		{Name: "Cleaning", Kind: models.ChargeFee, Method: models.ChargeFlat, Per: models.ChargePerStay, Amount: 2500},
+		{Name: "Resort fee", Kind: models.ChargeFee, Method: models.ChargeFlat, Per: models.ChargePerNight, Amount: 500},
+		{Name: "Suite surcharge", Kind: models.ChargeFee, Method: models.ChargePercent, Amount: 1250, RoomID: 2},
+	}
+
+	q := QuoteStay(room, start, start.AddDate(0, 0, 3), rules, nil)
+
+	//room 30000, cleaning 2500, resort 3 x 500, then 10% tax on 34000
+	var expected = []struct {
+		kind   string
+		amount int
+	}{
+		{LineRoom, 30000},
+		{models.ChargeFee, 2500},
+		{models.ChargeFee, 1500},
+		{models.ChargeTax, 3400},
+	}
+
+	if len(q.Lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %+v", len(expected), q.Lines)
+	}
+
+	for i, e := range expected {
+		if q.Lines[i].Kind != e.kind || q.Lines[i].Amount != e.amount {
+			t.Errorf("line %d: expected %s of %d, got %+v", i, e.kind, e.amount, q.Lines[i])
+		}
+	}
+
+	if q.Lines[2].Quantity != 3 || q.Lines[2].UnitAmount != 500 {
+		t.Errorf("per night fee should be 3 x 500, got %+v", q.Lines[2])
+	}
+
+	if q.Lines[3].Description != "Occupancy tax (10%)" {
+		t.Errorf("unexpected tax description %q", q.Lines[3].Description)
+	}
+
+	if q.Total != 37400 {
+		t.Errorf("expected a total of 37400, got %d", q.Total)
+	}
+}
+
+func TestPercentOf(t *testing.T) {
+	if got := PercentOf(1999, 1250); got != 250 {
+		t.Errorf("12.5%% of 1999 should round to 250, got %d", got)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected int
+		valid    bool
+	}{
+		{"25", 2500, true},
+		{"12.5", 1250, true},
+		{" 7.25 ", 725, true},
+		{".5", 50, true},
+		{"0", 0, true},
+		{"", 0, false},
+		{"1.234", 0, false},
+		{"-3", 0, false},
+		{"abc", 0, false},
+		{"1.-5", 0, false},
+	}
+
+	for _, e := range tests {
+		got, err := ParseAmount(e.in)
+		if e.valid && (err != nil || got != e.expected) {
+			t.Errorf("ParseAmount(%q): expected %d, got %d, %v", e.in, e.expected, got, err)
+		}
+		if !e.valid && err == nil {
+			t.Errorf("ParseAmount(%q): expected an error, got %d", e.in, got)
+		}
+	}
+}
+
+func TestQuoteStayPromo(t *testing.T) {
+	room := models.Room{ID: 1, Price: 10000}
+	start := time.Date(2050, 3, 27, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 2)
+	tax := []models.ChargeRule{{Name: "Tax", Kind: models.ChargeTax, Method: models.ChargePercent, Amount: 1000}}
+
+	var tests = []struct {
+		name     string
+		promo    models.PromoCode
+		discount int
+		total    int
+	}{
+		{"percentage", models.PromoCode{Code: "SPRING", Method: models.ChargePercent, Amount: 1500}, -3000, 18700},
+		{"fixed", models.PromoCode{Code: "WELCOME", Method: models.ChargeFlat, Amount: 2500}, -2500, 19250},
+		{"more than the stay", models.PromoCode{Code: "FREE", Method: models.ChargeFlat, Amount: 50000}, -20000, 0},
+	}
+
+	for _, e := range tests {
+		q := QuoteStay(room, start, end, tax, &e.promo)
+		if len(q.Lines) != 3 || q.Lines[1].Kind != LineDiscount || q.Lines[1].Amount != e.discount {
+			t.Errorf("%s: expected a discount of %d, got %+v", e.name, e.discount, q.Lines)
+		}
+		if q.Total != e.total {
+			t.Errorf("%s: expected a total of %d, got %d", e.name, e.total, q.Total)
+		}
+	}
+}
+
+func TestPromoProblem(t *testing.T) {
+	start := time.Date(2050, 3, 27, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 3)
+
+	var tests = []struct {
+		name  string
+		promo models.PromoCode
+		ok    bool
+	}{
+		{"no limits", models.PromoCode{}, true},
+		{"used up", models.PromoCode{MaxUses: 5, Uses: 5}, false},
+		{"uses left", models.PromoCode{MaxUses: 5, Uses: 4}, true},
+		{"other room", models.PromoCode{RoomID: 2}, false},
+		{"this room", models.PromoCode{RoomID: 1}, true},
+		{"too short", models.PromoCode{MinNights: 4}, false},
+		{"long enough", models.PromoCode{MinNights: 3}, true},
+		{"before window", models.PromoCode{ValidFrom: start.AddDate(0, 0, 1)}, false},
+		{"last night is the last valid day", models.PromoCode{ValidFrom: start, ValidTo: end.AddDate(0, 0, -1)}, true},
+		{"runs past window", models.PromoCode{ValidTo: end.AddDate(0, 0, -2)}, false},
+	}
+
+	for _, e := range tests {
+		problem := PromoProblem(e.promo, 1, start, end)
+		if e.ok && problem != "" {
+			t.Errorf("%s: expected the code to apply, got %q", e.name, problem)
+		}
+		if !e.ok && problem == "" {
+			t.Errorf("%s: expected the code to be refused", e.name)
+		}
+	}
+}
+
+func TestMinStayProblem(t *testing.T) {
+	start := time.Date(2050, 3, 27, 0, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name   string
+		room   models.Room
+		nights int
+		ok     bool
+	}{
+		{"no minimum", models.Room{}, 1, true},
+		{"minimum of one", models.Room{MinNights: 1}, 1, true},
+		{"too short", models.Room{MinNights: 3}, 2, false},
+		{"long enough", models.Room{MinNights: 3}, 3, true},
+		{"longer", models.Room{MinNights: 3}, 7, true},
+	}
+
+	for _, e := range tests {
+		problem := MinStayProblem(e.room, start, start.AddDate(0, 0, e.nights))
+		if e.ok && problem != "" {
+			t.Errorf("%s: expected the stay to be long enough, got %q", e.name, problem)
+		}
+		if !e.ok && problem == "" {
+			t.Errorf("%s: expected the stay to be too short", e.name)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	var tests = []struct {
+		cents    int
+		expected string
+	}{
+		{0, "$0.00"},
+		{8900, "$89.00"},
+		{1795, "$17.95"},
+		{5, "$0.05"},
+		{-250, "-$2.50"},
+	}
+
+	for _, e := range tests {
+		if got := Money(e.cents); got != e.expected {
+			t.Errorf("Money(%d): expected %s, got %s", e.cents, e.expected, got)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	var tests = []struct {
+		hundredths int
+		expected   string
+	}{
+		{0, "0%"},
+		{2000, "20%"},
+		{1250, "12.5%"},
+		{725, "7.25%"},
+	}
+
+	for _, e := range tests {
+		if got := Percent(e.hundredths); got != e.expected {
+			t.Errorf("Percent(%d): expected %s, got %s", e.hundredths, e.expected, got)
+		}
+	}
+}
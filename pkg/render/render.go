@@ -3,6 +3,7 @@ package render
 import (
 	"BookingProject/pkg/config"
 	"BookingProject/pkg/models"
+	"BookingProject/pkg/pricing"
 	"bytes"
 	"errors"
 	"fmt"
@@ -21,6 +22,8 @@ var functions = template.FuncMap{
 	"humanDate":  HumanDate,
 	"formatDate": FormatDate,
 	"iterate":    Iterate,
+	"money":      pricing.Money,
+	"percent":    pricing.Percent,
 }
 
 var app *config.AppConfig
@@ -116,7 +119,7 @@ func CreateTemplateCache() (map[string]*template.Template, error) {
 	// range through all files ending with *.page.html
 	for _, page := range pages {
 		name := filepath.Base(page)
-		ts, err := template.New(name).ParseFiles(page)
+		ts, err := template.New(name).Funcs(functions).ParseFiles(page)
 
 		if err != nil {
 
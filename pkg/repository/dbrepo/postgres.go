@@ -2,8 +2,13 @@ package dbrepo
 
 import (
 	"BookingProject/pkg/models"
+	"BookingProject/pkg/repository"
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -18,30 +23,218 @@ func (m *postgresDBRepo) InsertReservation(res models.Reservation) (int, error)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	//first two lines help to cancel the user's request if net is lost for more than 3s
-	var newID int
+	newID, err := insertReservation(ctx, m.DB, res)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// execer is what a reservation is inserted with, the database or a transaction
+type execer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertReservation adds a reservation and returns its id. A promo code that has been used up by the time
+// the reservation goes in gives repository.ErrPromoUsedUp, and nothing is inserted
+func insertReservation(ctx context.Context, db execer, res models.Reservation) (int, error) {
+	status := res.Status
+	if status == "" {
+		status = models.ReservationConfirmed
+	}
+
+	source := res.Source
+	if source == "" {
+		source = models.SourceWebsite
+	}
 
 	stmt := `insert into reservations (first_name, last_name,email,phone,
-		start_date,end_date,room_id,created_at,updated_at)
-		values ($1,$2,$3,$4,$5,$6,$7,$8,$9) returning id`
+		start_date,end_date,room_id,status,promo_code_id,source,min_stay_override,processed,special_requests,
+		created_at,updated_at)
+		select $1,$2,$3,$4,$5,$6,$7,$8,nullif($9, 0),$10,$11,$12,$13,$14,$15
+		where $9 = 0 or exists (select 1 from promo_codes p where p.id = $9
+			and (p.max_uses = 0 or p.max_uses > ` + promoUses + `))
+		returning id`
+
+	var id int
+	err := db.QueryRowContext(ctx, stmt, res.FirstName, res.LastName, res.Email, res.Phone, res.StartDate, res.EndDate,
+		res.RoomID, status, res.PromoCodeID, source, res.MinStayOverride, res.Processed, res.SpecialRequests,
+		time.Now(), time.Now()).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, repository.ErrPromoUsedUp
+	}
+
+	return id, err
+}
+
+// roomLocks is the advisory lock space taken by room id
+const roomLocks = 1
+
+// lockRooms keeps anything else from taking the rooms until the transaction ends. Checking a room is
+// free and taking it in one statement isn't enough under read committed, as two transactions can both
+// see it free, so whatever takes a room locks it first. Rooms are locked in order so two transactions
+// taking several can't deadlock
+func lockRooms(ctx context.Context, tx *sql.Tx, roomIDs ...int) error {
+	sort.Ints(roomIDs)
+	for _, id := range roomIDs {
+		_, err := tx.ExecContext(ctx, `select pg_advisory_xact_lock($1, $2)`, roomLocks, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertReservationRestriction takes the room for a reservation, unless something else has it for any of
+// the nights, in which case it returns sql.ErrNoRows. Expired holds don't count. The room has to be
+// locked already
+func insertReservationRestriction(ctx context.Context, db execer, r models.RoomRestriction) error {
+	stmt := `insert into room_restrictions (start_date, end_date, room_id, reservation_id, restriction_id,
+		expires_at, created_at, updated_at)
+		select $1, $2, $3, $4, $5, $6, $7, $8
+		where not exists (select 1 from room_restrictions rr where rr.room_id = $3
+			and $1 < rr.end_date and $2 > rr.start_date
+			and (rr.expires_at is null or rr.expires_at > now()))
+		returning id`
+
+	var id int
+	expires := sql.NullTime{Time: r.ExpiresAt, Valid: !r.ExpiresAt.IsZero()}
+	return db.QueryRowContext(ctx, stmt, r.StartDate, r.EndDate, r.RoomID, r.ReservationID, models.RestrictionReservation,
+		expires, time.Now(), time.Now()).Scan(&id)
+}
+
+// reservationRestriction is the restriction that takes the room for a reservation
+func reservationRestriction(res models.Reservation) models.RoomRestriction {
+	return models.RoomRestriction{
+		StartDate:     res.StartDate,
+		EndDate:       res.EndDate,
+		RoomID:        res.RoomID,
+		ReservationID: res.ID,
+		RestrictionID: models.RestrictionReservation,
+	}
+}
 
-	err := m.DB.QueryRowContext(ctx, stmt, res.FirstName, res.LastName, res.Email, res.Phone, res.StartDate, res.EndDate, res.RoomID, time.Now(), time.Now()).Scan(&newID)
+// inserts a reservation together with the room restriction for it, unless the room is taken for the
+// dates, in which case it returns sql.ErrNoRows and nothing is inserted
+func (m *postgresDBRepo) InsertReservationWithRestriction(res models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
-	return newID, nil
+	err = lockRooms(ctx, tx, res.RoomID)
+	if err != nil {
+		return 0, err
+	}
+
+	res.ID, err = insertReservation(ctx, tx, res)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertReservationRestriction(ctx, tx, reservationRestriction(res))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.ID, tx.Commit()
+}
+
+// withRoomLocked runs fn in a transaction with the room locked, committing if fn succeeds
+func (m *postgresDBRepo) withRoomLocked(ctx context.Context, roomID int, fn func(tx *sql.Tx) error) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockRooms(ctx, tx, roomID)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// takes the room for a reservation that's already in, unless something else has it for any of the
+// nights, in which case it returns sql.ErrNoRows
+func (m *postgresDBRepo) InsertReservationRestriction(r models.RoomRestriction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.withRoomLocked(ctx, r.RoomID, func(tx *sql.Tx) error {
+		return insertReservationRestriction(ctx, tx, r)
+	})
+}
+
+// ImportReservations inserts the reservations, and the room restrictions for those that aren't
+// cancelled, all or none of them. If a room is taken it returns sql.ErrNoRows along with the index of
+// the reservation that wanted it
+func (m *postgresDBRepo) ImportReservations(rs []models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var roomIDs []int
+	locked := make(map[int]bool)
+	for _, res := range rs {
+		if !locked[res.RoomID] {
+			locked[res.RoomID] = true
+			roomIDs = append(roomIDs, res.RoomID)
+		}
+	}
+	err = lockRooms(ctx, tx, roomIDs...)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, res := range rs {
+		res.ID, err = insertReservation(ctx, tx, res)
+		if err != nil {
+			return i, err
+		}
+
+		if res.Status == models.ReservationCancelled {
+			continue
+		}
+
+		err = insertReservationRestriction(ctx, tx, reservationRestriction(res))
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(rs), tx.Commit()
 }
 
 func (m *postgresDBRepo) InsertRoomRestriction(r models.RoomRestriction) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	stmt := `insert into room_restrictions (start_date,end_date,room_id,reservation_id.
-		created_at, updated_at,restriction_id)
-		values ($1,$2,$3,$4,$5,$6,$7)`
+	stmt := `insert into room_restrictions (start_date,end_date,room_id,reservation_id,
+		created_at, updated_at,restriction_id,expires_at)
+		values ($1,$2,$3,$4,$5,$6,$7,$8)`
 
-	_, err := m.DB.ExecContext(ctx, stmt, r.StartDate, r.EndDate, r.RoomID, r.ReservationID, time.Now(), time.Now(), r.RestrictionID)
+	expires := sql.NullTime{Time: r.ExpiresAt, Valid: !r.ExpiresAt.IsZero()}
+	_, err := m.DB.ExecContext(ctx, stmt, r.StartDate, r.EndDate, r.RoomID, r.ReservationID, time.Now(), time.Now(), r.RestrictionID,
+		expires)
 
 	if err != nil {
 		return err
@@ -57,13 +250,14 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time,
 	var numRows int
 	query := `select count(id) from room_restrictions
 		where room_id = $1 and
-		$2 < end_date and $3 > start_date;`
+		$2 < end_date and $3 > start_date and
+		(expires_at is null or expires_at > now());`
 
 	row := m.DB.QueryRowContext(ctx, query, roomID, start, end)
 	err := row.Scan(&numRows)
 
 	if err != nil {
-		return false, nil
+		return false, err
 	}
 
 	return numRows == 0, nil
@@ -78,7 +272,8 @@ func (m *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]
 
 	query := `select r.id,r.room_name from rooms r
 				where r.id not in 
-				(select room_id from room_restrictions rr where $1 < rr.end_date and $2 > rr.start_date)`
+				(select room_id from room_restrictions rr where $1 < rr.end_date and $2 > rr.start_date
+				and (rr.expires_at is null or rr.expires_at > now()))`
 
 	rows, err := m.DB.QueryContext(ctx, query, start, end)
 	if err != nil {
@@ -109,12 +304,14 @@ func (m *postgresDBRepo) GetRoomByID(id int) (models.Room, error) {
 	var room models.Room
 
 	query := `
-	select id,room_name,created_at,updated_at from room where id= $1`
+	select id,room_name,price,min_nights,created_at,updated_at from rooms where id= $1`
 
 	row := m.DB.QueryRowContext(ctx, query, id)
 	err := row.Scan(
 		&room.ID,
 		&room.RoomName,
+		&room.Price,
+		&room.MinNights,
 		&room.CreatedAt,
 		&room.UpdatedAt,
 	)
@@ -130,7 +327,7 @@ func (m *postgresDBRepo) GetUserByID(id int) (models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `select id,first_name,last_name,email, password, access_level, created_at, updated_at
+	query := `select id,first_name,last_name,email, password, access_level, ical_token, created_at, updated_at
 	 from users where id = $1`
 
 	row := m.DB.QueryRowContext(ctx, query, id)
@@ -143,6 +340,7 @@ func (m *postgresDBRepo) GetUserByID(id int) (models.User, error) {
 		&u.Email,
 		&u.Password,
 		&u.AccessLevel,
+		&u.ICalToken,
 		&u.CreatedAt,
 		&u.UpdatedAt,
 	)
@@ -195,19 +393,53 @@ func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string,
 
 //returns slice of all reservations
 
-func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
+// reservationSortColumns are what each sort key orders reservations by
+var reservationSortColumns = map[string]string{
+	models.SortArrival:   "r.start_date",
+	models.SortDeparture: "r.end_date",
+	models.SortLastName:  "lower(r.last_name)",
+	models.SortRoom:      "rm.room_name",
+	models.SortBooked:    "r.created_at",
+}
+
+// SearchReservations returns the page of reservations the filter asks for, along with how many match
+// it in all
+func (m *postgresDBRepo) SearchReservations(f models.ReservationFilter) ([]models.Reservation, int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var reservations []models.Reservation
 
-	query := `select r.id,r.first_name,r.email,r.phone,r.start_date,r.end_date, r.room_id, r.created_at, r.updated_at, rm.id,rm.room_name
-	 from reservations r left join rooms rm on (r.room_id = rm.id)
-	  order by r.start_date asc`
+	where, args := reservationWhere(f)
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	var total int
+	err := m.DB.QueryRowContext(ctx, `select count(*) from reservations r left join rooms rm on (r.room_id = rm.id) `+where,
+		args...).Scan(&total)
 	if err != nil {
-		return reservations, err
+		return reservations, 0, err
+	}
+
+	sort, ok := reservationSortColumns[f.Sort]
+	if !ok {
+		sort = reservationSortColumns[models.SortArrival]
+	}
+	if f.Desc {
+		sort += " desc"
+	}
+
+	query := `select r.id,r.first_name,r.last_name,r.email,r.phone,r.start_date,r.end_date, r.room_id, r.created_at,
+	r.updated_at,r.processed,r.status,r.source, rm.id,rm.room_name
+	 from reservations r left join rooms rm on (r.room_id = rm.id) ` + where + `
+	  order by ` + sort + `, r.id`
+
+	if f.Limit > 0 {
+		args = append(args, f.Limit, f.Offset)
+		query += fmt.Sprintf(" limit $%d offset $%d", len(args)-1, len(args))
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return reservations, 0, err
 	}
 	defer rows.Close()
 
@@ -224,42 +456,62 @@ func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
 			&i.RoomID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Processed,
+			&i.Status,
+			&i.Source,
 			&i.Room.ID,
 			&i.Room.RoomName,
 		)
 
 		if err != nil {
-			return reservations, err
+			return reservations, 0, err
 		}
 
 		reservations = append(reservations, i)
 	}
 
 	if err = rows.Err(); err != nil {
-		return reservations, err
+		return reservations, 0, err
 	}
 
-	return reservations, nil
+	return reservations, total, nil
 }
 
-func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// how long an export has to stream every reservation it matches, or an import to put them all in
+const bulkTimeout = 2 * time.Minute
+
+// EachReservation calls fn with every reservation the filter matches, in order, along with what it's
+// been invoiced and paid, reading them one at a time. It stops at the first error fn returns
+func (m *postgresDBRepo) EachReservation(f models.ReservationFilter, fn func(models.ReservationAmounts) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
 	defer cancel()
 
-	var reservations []models.Reservation
+	//the payment status comes before the filter's arguments
+	where, args := reservationWhere(f, models.PaymentPaid)
+
+	sort, ok := reservationSortColumns[f.Sort]
+	if !ok {
+		sort = reservationSortColumns[models.SortArrival]
+	}
+	if f.Desc {
+		sort += " desc"
+	}
 
-	query := `select r.id,r.first_name,r.email,r.phone,r.start_date,r.end_date, r.room_id, r.created_at, r.updated_at,r.processed, rm.id,rm.room_name
-	 from reservations r left join rooms rm on (r.room_id = rm.id) where processed = 0 
-	  order by r.start_date asc`
+	query := `select r.id,r.first_name,r.last_name,r.email,r.phone,r.start_date,r.end_date, r.room_id, r.created_at,
+	r.updated_at,r.processed,r.status,r.source, rm.id,rm.room_name, coalesce(i.total, 0), coalesce(i.currency, ''),
+	(select coalesce(sum(p.amount), 0) from payments p where p.reservation_id = r.id and p.status = $1)
+	 from reservations r left join rooms rm on (r.room_id = rm.id)
+	 left join invoices i on (i.reservation_id = r.id) ` + where + `
+	  order by ` + sort + `, r.id`
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return reservations, err
+		return err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var i models.Reservation
+		var i models.ReservationAmounts
 		err := rows.Scan(
 			&i.ID,
 			&i.FirstName,
@@ -272,32 +524,78 @@ func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Processed,
+			&i.Status,
+			&i.Source,
 			&i.Room.ID,
 			&i.Room.RoomName,
+			&i.Invoiced,
+			&i.Currency,
+			&i.Paid,
 		)
-
 		if err != nil {
-			return reservations, err
+			return err
 		}
 
-		reservations = append(reservations, i)
+		if err = fn(i); err != nil {
+			return err
+		}
 	}
 
-	if err = rows.Err(); err != nil {
-		return reservations, err
+	return rows.Err()
+}
+
+// reservationWhere builds the where clause for a reservation filter, with the arguments for it following
+// any the rest of the query already takes
+func reservationWhere(f models.ReservationFilter, args ...interface{}) (string, []interface{}) {
+	var conds []string
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", fmt.Sprintf("$%d", len(args))))
 	}
 
-	return reservations, nil
+	if f.Search != "" {
+		add(`((r.first_name || ' ' || r.last_name) ilike ? or r.email ilike ? or r.phone ilike ?)`,
+			"%"+likeEscaper.Replace(f.Search)+"%")
+	}
+	if f.RoomID > 0 {
+		add("r.room_id = ?", f.RoomID)
+	}
+	if !f.From.IsZero() {
+		add("r.end_date > ?", f.From)
+	}
+	if !f.To.IsZero() {
+		add("r.start_date < ?", f.To)
+	}
+	if f.Status != "" {
+		add("r.status = ?", f.Status)
+	}
+	if f.Source != "" {
+		add("r.source = ?", f.Source)
+	}
+	if f.NewOnly {
+		conds = append(conds, "r.processed = 0")
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "where " + strings.Join(conds, " and "), args
 }
 
+// likeEscaper escapes what like and ilike would otherwise treat as wildcards
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var res models.Reservation
+	var checkedIn, checkedOut sql.NullTime
 
 	query := `select r.id,r.first_name,r.last_name,r.email,r.phone,r.start_date,r.end_date,
-	r.room_id,r.created_at,r.updated_at,r.processed, rm.id,rm.room_name 
+	r.room_id,r.created_at,r.updated_at,r.processed,r.status,coalesce(r.promo_code_id, 0), r.source,
+	r.min_stay_override, r.checked_in_at, r.checked_out_at, r.special_requests, rm.id,rm.room_name 
 	from reservations r
 	 left join rooms rm on (r.room_id = rm.id) 
 	 where r.id = $1`
@@ -315,6 +613,13 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 		&res.CreatedAt,
 		&res.UpdatedAt,
 		&res.Processed,
+		&res.Status,
+		&res.PromoCodeID,
+		&res.Source,
+		&res.MinStayOverride,
+		&checkedIn,
+		&checkedOut,
+		&res.SpecialRequests,
 		&res.Room.ID,
 		&res.Room.RoomName,
 	)
@@ -323,6 +628,9 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 		return res, err
 	}
 
+	res.CheckedInAt = checkedIn.Time
+	res.CheckedOutAt = checkedOut.Time
+
 	return res, nil
 }
 
@@ -331,8 +639,9 @@ func (m *postgresDBRepo) UpdateReservation(u models.Reservation) error {
 	defer cancel()
 
 	query := `
-	update reservations set first_name=$1, last_name= $2, email=$3, phone=$4,updated_at=$5 where id = $6`
-	_, err := m.DB.ExecContext(ctx, query, u.FirstName, u.LastName, u.Email, u.Phone, time.Now(), u.ID)
+	update reservations set first_name=$1, last_name= $2, email=$3, phone=$4, special_requests=$5, updated_at=$6
+	where id = $7`
+	_, err := m.DB.ExecContext(ctx, query, u.FirstName, u.LastName, u.Email, u.Phone, u.SpecialRequests, time.Now(), u.ID)
 
 	if err != nil {
 		return err
@@ -355,18 +664,110 @@ func (m *postgresDBRepo) DeleteReservation(id int) error {
 	return nil
 }
 
-func (m *postgresDBRepo) UpdateProcessedForReservation(id, processed int) error {
+// marks a reservation as cancelled and frees its room restriction
+func (m *postgresDBRepo) CancelReservation(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `update from reservations set processed = $1 where id = $2`
-	_, err := m.DB.ExecContext(ctx, query, processed, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	query := `update reservations set status = $1, updated_at = $2 where id = $3`
+	_, err = tx.ExecContext(ctx, query, models.ReservationCancelled, time.Now(), id)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	query = `delete from room_restrictions where reservation_id = $1`
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// moves a reservation to other dates or another room along with its room restriction, saving the guest's
+// details with it, and reissues its invoice as inv if it has been invoiced. It reports false, and saves
+// nothing, when the room is taken for the new dates
+func (m *postgresDBRepo) MoveReservation(res models.Reservation, inv models.Invoice) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = lockRooms(ctx, tx, res.RoomID)
+	if err != nil {
+		return false, err
+	}
+
+	query := `update room_restrictions set start_date = $1, end_date = $2, room_id = $3, updated_at = $4
+		where reservation_id = $5
+		and not exists (select 1 from room_restrictions rr where rr.room_id = $3
+			and (rr.reservation_id is null or rr.reservation_id <> $5)
+			and $1 < rr.end_date and $2 > rr.start_date
+			and (rr.expires_at is null or rr.expires_at > now()))`
+
+	result, err := tx.ExecContext(ctx, query, res.StartDate, res.EndDate, res.RoomID, time.Now(), res.ID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	query = `update reservations set start_date = $1, end_date = $2, room_id = $3, first_name = $4, last_name = $5,
+		email = $6, phone = $7, special_requests = $8, updated_at = $9 where id = $10`
+	_, err = tx.ExecContext(ctx, query, res.StartDate, res.EndDate, res.RoomID, res.FirstName, res.LastName,
+		res.Email, res.Phone, res.SpecialRequests, time.Now(), res.ID)
+	if err != nil {
+		return false, err
+	}
+
+	var invoiceID int
+	err = tx.QueryRowContext(ctx, `delete from invoices where reservation_id = $1 returning id`, res.ID).Scan(&invoiceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, tx.Commit()
+	} else if err != nil {
+		return false, err
+	}
+
+	stmt := `insert into invoices (reservation_id, total, currency, created_at, updated_at)
+		values ($1, $2, $3, $4, $5) returning id`
+
+	err = tx.QueryRowContext(ctx, stmt, res.ID, inv.Total, inv.Currency, time.Now(), time.Now()).Scan(&invoiceID)
+	if err != nil {
+		return false, err
+	}
+
+	err = insertInvoiceLines(ctx, tx, invoiceID, inv.Lines)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (m *postgresDBRepo) UpdateProcessedForReservation(id, processed int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update reservations set processed = $1, updated_at = $2 where id = $3`
+	result, err := m.DB.ExecContext(ctx, query, processed, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return oneRowAffected(result)
 }
 
 func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
@@ -375,7 +776,7 @@ func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
 
 	var rooms []models.Room
 
-	query := `select id, room_name, created_at, updated_at from rooms order by room_name`
+	query := `select id, room_name, ical_token, price, min_nights, created_at, updated_at from rooms order by room_name`
 
 	rows, err := m.DB.QueryContext(ctx, query)
 	defer rows.Close()
@@ -388,6 +789,9 @@ func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
 		err := rows.Scan(
 			&rm.ID,
 			&rm.RoomName,
+			&rm.ICalToken,
+			&rm.Price,
+			&rm.MinNights,
 			&rm.CreatedAt,
 			&rm.UpdatedAt,
 		)
@@ -410,10 +814,13 @@ func (m *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end tim
 
 	var restrictions []models.RoomRestriction
 
-	query := `select id, coalesce (reservation_id, 0), restriction_id, room_id, start_date,end_date 
-	from room_restrictions where $1 < end_date  and $2 >= start_date and room_id = $3`
+	query := `select rr.id, coalesce (rr.reservation_id, 0), rr.restriction_id, rr.room_id, rr.start_date,rr.end_date,
+	coalesce(r.first_name, ''), coalesce(r.last_name, '')
+	from room_restrictions rr left join reservations r on (rr.reservation_id = r.id)
+	where $1 < rr.end_date  and $2 >= rr.start_date and rr.room_id = $3 and rr.restriction_id <> $4`
 
-	rows, err := m.DB.QueryContext(ctx, query, start, end, roomID)
+	//holds are left out, they're gone in minutes and shouldn't show up as owner blocks
+	rows, err := m.DB.QueryContext(ctx, query, start, end, roomID, models.RestrictionHold)
 
 	if err != nil {
 		return nil, err
@@ -429,11 +836,14 @@ func (m *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end tim
 			&r.RoomID,
 			&r.StartDate,
 			&r.EndDate,
+			&r.Reservation.FirstName,
+			&r.Reservation.LastName,
 		)
 
 		if err != nil {
 			return nil, err
 		}
+		r.Reservation.ID = r.ReservationID
 		restrictions = append(restrictions, r)
 	}
 
@@ -444,31 +854,1915 @@ func (m *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end tim
 	return restrictions, nil
 }
 
-func (m *postgresDBRepo) InsertBlockForRoom(id int, startDate time.Time) error {
+// returns what is taking up any room between start and end, including live holds, for working out
+// which other dates are free
+func (m *postgresDBRepo) GetRestrictionsForAllRoomsByDate(start, end time.Time) ([]models.RoomRestriction, error) {
+	query := `select id, coalesce(reservation_id, 0), restriction_id, room_id, start_date, end_date
+	from room_restrictions
+	where $1 < end_date and $2 > start_date and (expires_at is null or expires_at > now())`
+
+	return m.takenRestrictions(query, start, end)
+}
+
+// returns what is taking up one room between start and end, including live holds
+func (m *postgresDBRepo) GetTakenRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+	query := `select id, coalesce(reservation_id, 0), restriction_id, room_id, start_date, end_date
+	from room_restrictions
+	where $1 < end_date and $2 > start_date and room_id = $3 and (expires_at is null or expires_at > now())`
+
+	return m.takenRestrictions(query, start, end, roomID)
+}
+
+// takenRestrictions runs a query for the room restrictions taking up rooms, scanning the ids, room and dates of each
+func (m *postgresDBRepo) takenRestrictions(query string, args ...interface{}) ([]models.RoomRestriction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `insert into room restrictions (start_date, end_date, room_id, restriction_id,created_at,updated_at) 
-	values ($1,$2,$3,$4,$5,$6)`
+	var restrictions []models.RoomRestriction
 
-	_, err := m.DB.ExecContext(ctx, query, startDate, startDate.AddDate(0, 0, 1), id, 2, time.Now(), time.Now())
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	for rows.Next() {
+		var r models.RoomRestriction
+		err := rows.Scan(
+			&r.ID,
+			&r.ReservationID,
+			&r.RestrictionID,
+			&r.RoomID,
+			&r.StartDate,
+			&r.EndDate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return restrictions, nil
 }
 
-func (m *postgresDBRepo) DeleteBlockByID(id int) error {
+// GetRestrictionsForCalendar returns the restrictions on every room between two dates, with the guest's
+// name for reservations, for the reservations calendar. Holds are left out, and so are reservations
+// nobody paid for in time, the way they're left out when rooms are booked
+func (m *postgresDBRepo) GetRestrictionsForCalendar(start, end time.Time) ([]models.RoomRestriction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `delete from room_restrictions where id = $1`
+	var restrictions []models.RoomRestriction
+
+	query := `select rr.id, coalesce(rr.reservation_id, 0), rr.restriction_id, rr.room_id, rr.start_date, rr.end_date,
+	rr.reason, coalesce(r.first_name, ''), coalesce(r.last_name, '')
+	from room_restrictions rr left join reservations r on (rr.reservation_id = r.id)
+	where $1 < rr.end_date and $2 > rr.start_date and rr.restriction_id <> $3
+	and (rr.expires_at is null or rr.expires_at > now())
+	order by rr.room_id, rr.start_date`
 
-	_, err := m.DB.ExecContext(ctx, query, id)
+	rows, err := m.DB.QueryContext(ctx, query, start, end, models.RestrictionHold)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	for rows.Next() {
+		var r models.RoomRestriction
+		err := rows.Scan(
+			&r.ID,
+			&r.ReservationID,
+			&r.RestrictionID,
+			&r.RoomID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.Reason,
+			&r.Reservation.FirstName,
+			&r.Reservation.LastName,
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.Reservation.ID = r.ReservationID
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return restrictions, nil
+}
+
+func (m *postgresDBRepo) InsertBlockForRoom(id int, startDate time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	query := `insert into room_restrictions (start_date, end_date, room_id, restriction_id,created_at,updated_at) 
+	values ($1,$2,$3,$4,$5,$6) returning id`
+
+	err := m.DB.QueryRowContext(ctx, query, startDate, startDate.AddDate(0, 0, 1), id, models.RestrictionOwnerBlock, time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+func (m *postgresDBRepo) DeleteBlockByID(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	//only ever an owner block, never a reservation's or an imported booking's restriction
+	query := `delete from room_restrictions where id = $1 and restriction_id = $2`
+
+	result, err := m.DB.ExecContext(ctx, query, id, models.RestrictionOwnerBlock)
+	if err != nil {
+		return err
+	}
+
+	return oneRowAffected(result)
+}
+
+// oneRowAffected is sql.ErrNoRows when a statement meant to change a row found none to change
+func oneRowAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+const blockQuery = `select rr.id, rr.room_id, rr.start_date, rr.end_date, rr.reason, rr.created_at, rr.updated_at,
+	rm.room_name
+	from room_restrictions rr left join rooms rm on (rr.room_id = rm.id)`
+
+func scanBlock(row scanner) (models.RoomRestriction, error) {
+	var r models.RoomRestriction
+
+	err := row.Scan(
+		&r.ID,
+		&r.RoomID,
+		&r.StartDate,
+		&r.EndDate,
+		&r.Reason,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+		&r.Room.RoomName,
+	)
+	if err != nil {
+		return r, err
+	}
+
+	r.RestrictionID = models.RestrictionOwnerBlock
+	r.Room.ID = r.RoomID
+
+	return r, nil
+}
+
+func (m *postgresDBRepo) GetBlockByID(id int) (models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanBlock(m.DB.QueryRowContext(ctx, blockQuery+` where rr.id = $1 and rr.restriction_id = $2`,
+		id, models.RestrictionOwnerBlock))
+}
+
+// returns the owner blocks that haven't finished yet, soonest first
+func (m *postgresDBRepo) UpcomingBlocks() ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var blocks []models.RoomRestriction
+
+	rows, err := m.DB.QueryContext(ctx, blockQuery+` where rr.restriction_id = $1 and rr.end_date > current_date
+		order by rr.start_date, rm.room_name`, models.RestrictionOwnerBlock)
+	if err != nil {
+		return blocks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanBlock(rows)
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return blocks, err
+	}
+
+	return blocks, nil
+}
+
+// blocks a room over a range of dates, unless some of them are already taken, in which case it
+// returns sql.ErrNoRows
+func (m *postgresDBRepo) InsertBlock(r models.RoomRestriction) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `insert into room_restrictions (start_date, end_date, room_id, restriction_id, reason,
+		created_at, updated_at)
+		select $1, $2, $3, $4, $5, $6, $7
+		where not exists (select 1 from room_restrictions rr where rr.room_id = $3
+			and $1 < rr.end_date and $2 > rr.start_date
+			and (rr.expires_at is null or rr.expires_at > now()))
+		returning id`
+
+	err := m.withRoomLocked(ctx, r.RoomID, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, stmt, r.StartDate, r.EndDate, r.RoomID, models.RestrictionOwnerBlock, r.Reason,
+			time.Now(), time.Now()).Scan(&newID)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// moves a block or changes its reason. It reports false when the new dates are taken by anything
+// other than the block itself
+func (m *postgresDBRepo) UpdateBlock(r models.RoomRestriction) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update room_restrictions set start_date = $1, end_date = $2, room_id = $3, reason = $4, updated_at = $5
+		where id = $6 and restriction_id = $7
+		and not exists (select 1 from room_restrictions rr where rr.room_id = $3 and rr.id <> $6
+			and $1 < rr.end_date and $2 > rr.start_date
+			and (rr.expires_at is null or rr.expires_at > now()))`
+
+	var n int64
+	err := m.withRoomLocked(ctx, r.RoomID, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, r.StartDate, r.EndDate, r.RoomID, r.Reason, time.Now(),
+			r.ID, models.RestrictionOwnerBlock)
+		if err != nil {
+			return err
+		}
+
+		n, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (m *postgresDBRepo) InsertAPIKey(k models.APIKey) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `insert into api_keys (name, key_prefix, key_hash, scopes, user_id, created_at, updated_at)
+		values ($1,$2,$3,$4,$5,$6,$7) returning id`
+
+	err := m.DB.QueryRowContext(ctx, stmt, k.Name, k.Prefix, k.Hash, strings.Join(k.Scopes, ","), k.UserID, time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+func (m *postgresDBRepo) AllAPIKeys() ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var keys []models.APIKey
+
+	query := `select id, name, key_prefix, key_hash, scopes, user_id, last_used_at, revoked_at, created_at, updated_at
+	 from api_keys order by created_at desc`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return keys, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return keys, err
+	}
+
+	return keys, nil
+}
+
+func (m *postgresDBRepo) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select id, name, key_prefix, key_hash, scopes, user_id, last_used_at, revoked_at, created_at, updated_at
+	 from api_keys where key_hash = $1`
+
+	return scanAPIKey(m.DB.QueryRowContext(ctx, query, hash))
+}
+
+func (m *postgresDBRepo) GetAPIKeyByID(id int) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select id, name, key_prefix, key_hash, scopes, user_id, last_used_at, revoked_at, created_at, updated_at
+	 from api_keys where id = $1`
+
+	return scanAPIKey(m.DB.QueryRowContext(ctx, query, id))
+}
+
+func (m *postgresDBRepo) RevokeAPIKey(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update api_keys set revoked_at = $1, updated_at = $1 where id = $2 and revoked_at is null`
+	_, err := m.DB.ExecContext(ctx, query, time.Now(), id)
+
+	return err
+}
+
+func (m *postgresDBRepo) UpdateAPIKeyLastUsed(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update api_keys set last_used_at = $1 where id = $2`
+	_, err := m.DB.ExecContext(ctx, query, time.Now(), id)
+
+	return err
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var k models.APIKey
+	var scopes string
+	var lastUsed, revoked sql.NullTime
+
+	err := row.Scan(
+		&k.ID,
+		&k.Name,
+		&k.Prefix,
+		&k.Hash,
+		&scopes,
+		&k.UserID,
+		&lastUsed,
+		&revoked,
+		&k.CreatedAt,
+		&k.UpdatedAt,
+	)
+	if err != nil {
+		return k, err
+	}
+
+	if scopes != "" {
+		k.Scopes = strings.Split(scopes, ",")
+	}
+	k.LastUsedAt = lastUsed.Time
+	k.RevokedAt = revoked.Time
+
+	return k, nil
+}
+
+func (m *postgresDBRepo) GetRoomByICalToken(token string) (models.Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var room models.Room
+
+	query := `select id, room_name, ical_token, created_at, updated_at from rooms where ical_token = $1 and ical_token <> ''`
+
+	row := m.DB.QueryRowContext(ctx, query, token)
+	err := row.Scan(
+		&room.ID,
+		&room.RoomName,
+		&room.ICalToken,
+		&room.CreatedAt,
+		&room.UpdatedAt,
+	)
+
+	if err != nil {
+		return room, err
+	}
+
+	return room, nil
+}
+
+func (m *postgresDBRepo) UpdateRoomICalToken(id int, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update rooms set ical_token = $1, updated_at = $2 where id = $3`
+	_, err := m.DB.ExecContext(ctx, query, token, time.Now(), id)
+
+	return err
+}
+
+// sets the shortest stay guests can book a room for
+func (m *postgresDBRepo) UpdateRoomMinNights(id, minNights int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update rooms set min_nights = $1, updated_at = $2 where id = $3`
+	result, err := m.DB.ExecContext(ctx, query, minNights, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return oneRowAffected(result)
+}
+
+func (m *postgresDBRepo) GetUserByICalToken(token string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select id, first_name, last_name, email, access_level, ical_token, created_at, updated_at
+	 from users where ical_token = $1 and ical_token <> ''`
+
+	var u models.User
+	err := m.DB.QueryRowContext(ctx, query, token).Scan(
+		&u.ID,
+		&u.FirstName,
+		&u.Lastname,
+		&u.Email,
+		&u.AccessLevel,
+		&u.ICalToken,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+
+	if err != nil {
+		return u, err
+	}
+
+	return u, nil
+}
+
+func (m *postgresDBRepo) UpdateUserICalToken(id int, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update users set ical_token = $1, updated_at = $2 where id = $3`
+	_, err := m.DB.ExecContext(ctx, query, token, time.Now(), id)
+
+	return err
+}
+
+// returns all the import feeds, with their room
+func (m *postgresDBRepo) AllICalFeeds() ([]models.ICalFeed, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var feeds []models.ICalFeed
+
+	query := `select f.id, f.room_id, f.name, f.url, f.last_synced_at, f.last_error, f.created_at, f.updated_at,
+	rm.id, rm.room_name
+	from ical_feeds f left join rooms rm on (f.room_id = rm.id)
+	order by rm.room_name, f.name`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return feeds, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f, err := scanICalFeed(rows)
+		if err != nil {
+			return feeds, err
+		}
+		feeds = append(feeds, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return feeds, err
+	}
+
+	return feeds, nil
+}
+
+func (m *postgresDBRepo) GetICalFeedByID(id int) (models.ICalFeed, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select f.id, f.room_id, f.name, f.url, f.last_synced_at, f.last_error, f.created_at, f.updated_at,
+	rm.id, rm.room_name
+	from ical_feeds f left join rooms rm on (f.room_id = rm.id)
+	where f.id = $1`
+
+	return scanICalFeed(m.DB.QueryRowContext(ctx, query, id))
+}
+
+func scanICalFeed(row scanner) (models.ICalFeed, error) {
+	var f models.ICalFeed
+	var synced sql.NullTime
+
+	err := row.Scan(
+		&f.ID,
+		&f.RoomID,
+		&f.Name,
+		&f.URL,
+		&synced,
+		&f.LastError,
+		&f.CreatedAt,
+		&f.UpdatedAt,
+		&f.Room.ID,
+		&f.Room.RoomName,
+	)
+	if err != nil {
+		return f, err
+	}
+
+	f.LastSyncedAt = synced.Time
+
+	return f, nil
+}
+
+func (m *postgresDBRepo) InsertICalFeed(f models.ICalFeed) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `insert into ical_feeds (room_id, name, url, created_at, updated_at)
+	values ($1, $2, $3, $4, $5) returning id`
+
+	err := m.DB.QueryRowContext(ctx, stmt, f.RoomID, f.Name, f.URL, time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// deletes a feed, its external bookings go with it
+func (m *postgresDBRepo) DeleteICalFeed(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `delete from ical_feeds where id = $1`
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+
+	return err
+}
+
+// records the outcome of a sync, syncErr is empty when it worked
+func (m *postgresDBRepo) UpdateICalFeedSynced(id int, syncErr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update ical_feeds set last_synced_at = $1, last_error = $2, updated_at = $1 where id = $3`
+
+	_, err := m.DB.ExecContext(ctx, query, time.Now(), syncErr, id)
+
+	return err
+}
+
+// returns the external bookings imported from a feed
+func (m *postgresDBRepo) GetRestrictionsForICalFeed(feedID int) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	query := `select id, room_id, restriction_id, start_date, end_date, ical_feed_id, external_uid
+	from room_restrictions where ical_feed_id = $1`
+
+	rows, err := m.DB.QueryContext(ctx, query, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.RoomRestriction
+		err := rows.Scan(
+			&r.ID,
+			&r.RoomID,
+			&r.RestrictionID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.ICalFeedID,
+			&r.ExternalUID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return restrictions, nil
+}
+
+// inserts a booking imported from a feed. It has no reservation, so reservation_id stays null
+func (m *postgresDBRepo) InsertExternalBooking(r models.RoomRestriction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into room_restrictions (start_date, end_date, room_id, restriction_id, ical_feed_id, external_uid,
+		created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := m.DB.ExecContext(ctx, stmt,
+		r.StartDate,
+		r.EndDate,
+		r.RoomID,
+		models.RestrictionExternal,
+		r.ICalFeedID,
+		r.ExternalUID,
+		time.Now(),
+		time.Now(),
+	)
+
+	return err
+}
+
+// moves an imported booking to new dates
+func (m *postgresDBRepo) UpdateExternalBooking(r models.RoomRestriction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `update room_restrictions set start_date = $1, end_date = $2, updated_at = $3
+	where id = $4 and restriction_id = $5`
+
+	_, err := m.DB.ExecContext(ctx, stmt, r.StartDate, r.EndDate, time.Now(), r.ID, models.RestrictionExternal)
+
+	return err
+}
+
+// removes a booking that has gone from its feed
+func (m *postgresDBRepo) DeleteExternalBooking(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `delete from room_restrictions where id = $1 and restriction_id = $2`
+
+	result, err := m.DB.ExecContext(ctx, query, id, models.RestrictionExternal)
+	if err != nil {
+		return err
+	}
+
+	return oneRowAffected(result)
+}
+
+// returns the current and future external bookings that overlap a reservation or block made here
+func (m *postgresDBRepo) GetBookingConflicts() ([]models.BookingConflict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var conflicts []models.BookingConflict
+
+	query := `select f.id, f.name, rm.id, rm.room_name,
+	ext.id, ext.start_date, ext.end_date, ext.external_uid,
+	loc.id, loc.restriction_id, loc.start_date, loc.end_date, coalesce(loc.reservation_id, 0),
+	coalesce(r.first_name, ''), coalesce(r.last_name, '')
+	from room_restrictions ext
+	join ical_feeds f on (f.id = ext.ical_feed_id)
+	join rooms rm on (rm.id = ext.room_id)
+	join room_restrictions loc on (loc.room_id = ext.room_id and loc.restriction_id <> $1
+		and loc.start_date < ext.end_date and ext.start_date < loc.end_date)
+	left join reservations r on (r.id = loc.reservation_id)
+	where ext.restriction_id = $1 and ext.end_date >= $2
+	order by ext.start_date, rm.room_name`
+
+	rows, err := m.DB.QueryContext(ctx, query, models.RestrictionExternal, time.Now())
+	if err != nil {
+		return conflicts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.BookingConflict
+		err := rows.Scan(
+			&c.Feed.ID,
+			&c.Feed.Name,
+			&c.Feed.Room.ID,
+			&c.Feed.Room.RoomName,
+			&c.External.ID,
+			&c.External.StartDate,
+			&c.External.EndDate,
+			&c.External.ExternalUID,
+			&c.Local.ID,
+			&c.Local.RestrictionID,
+			&c.Local.StartDate,
+			&c.Local.EndDate,
+			&c.Local.ReservationID,
+			&c.Local.Reservation.FirstName,
+			&c.Local.Reservation.LastName,
+		)
+		if err != nil {
+			return conflicts, err
+		}
+
+		c.Feed.RoomID = c.Feed.Room.ID
+		c.External.RoomID = c.Feed.Room.ID
+		c.External.ICalFeedID = c.Feed.ID
+		c.Local.RoomID = c.Feed.Room.ID
+		c.Local.Reservation.ID = c.Local.ReservationID
+		conflicts = append(conflicts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return conflicts, err
+	}
+
+	return conflicts, nil
+}
+
+func (m *postgresDBRepo) InsertPayment(p models.Payment) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `insert into payments (reservation_id, provider, provider_ref, kind, amount, currency, status,
+		created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id`
+
+	err := m.DB.QueryRowContext(ctx, stmt,
+		p.ReservationID,
+		p.Provider,
+		p.ProviderRef,
+		p.Kind,
+		p.Amount,
+		p.Currency,
+		models.PaymentPending,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+const paymentColumns = `id, reservation_id, provider, provider_ref, kind, amount, currency, status, paid_at,
+	created_at, updated_at`
+
+func scanPayment(row scanner) (models.Payment, error) {
+	var p models.Payment
+	var paid sql.NullTime
+
+	err := row.Scan(
+		&p.ID,
+		&p.ReservationID,
+		&p.Provider,
+		&p.ProviderRef,
+		&p.Kind,
+		&p.Amount,
+		&p.Currency,
+		&p.Status,
+		&paid,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return p, err
+	}
+
+	p.PaidAt = paid.Time
+
+	return p, nil
+}
+
+func (m *postgresDBRepo) GetPaymentByID(id int) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select ` + paymentColumns + ` from payments where id = $1`
+
+	return scanPayment(m.DB.QueryRowContext(ctx, query, id))
+}
+
+// finds a payment by the reference its provider gave it
+func (m *postgresDBRepo) GetPaymentByProviderRef(provider, ref string) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select ` + paymentColumns + ` from payments where provider = $1 and provider_ref = $2`
+
+	return scanPayment(m.DB.QueryRowContext(ctx, query, provider, ref))
+}
+
+func (m *postgresDBRepo) GetPaymentsForReservation(reservationID int) ([]models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var payments []models.Payment
+
+	query := `select ` + paymentColumns + ` from payments where reservation_id = $1 order by created_at`
+
+	rows, err := m.DB.QueryContext(ctx, query, reservationID)
+	if err != nil {
+		return payments, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return payments, err
+		}
+		payments = append(payments, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return payments, err
+	}
+
+	return payments, nil
+}
+
+func (m *postgresDBRepo) UpdatePaymentProviderRef(id int, ref string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update payments set provider_ref = $1, updated_at = $2 where id = $3`
+	_, err := m.DB.ExecContext(ctx, query, ref, time.Now(), id)
+
+	return err
+}
+
+// sets the status of a payment, stamping paid_at when it is paid
+func (m *postgresDBRepo) UpdatePaymentStatus(id int, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update payments set status = $1, updated_at = $2,
+	paid_at = case when $1 = 'paid' then $2 else paid_at end
+	where id = $3`
+	_, err := m.DB.ExecContext(ctx, query, status, time.Now(), id)
+
+	return err
+}
+
+// confirms a pending reservation and keeps its room for good, reporting whether it was still pending
+// with the room still kept for it. The restriction is taken first, the way the sweep of abandoned
+// reservations takes it, so the two can't each do half
+func (m *postgresDBRepo) ConfirmReservation(id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `update room_restrictions set expires_at = null, updated_at = $1
+		where reservation_id = $2 and restriction_id = $3 and expires_at > now()`
+	_, err = tx.ExecContext(ctx, query, time.Now(), id, models.RestrictionReservation)
+	if err != nil {
+		return false, err
+	}
+
+	query = `update reservations set status = $1, updated_at = $2 where id = $3 and status = $4
+		and exists (select 1 from room_restrictions rr where rr.reservation_id = $3 and rr.expires_at is null)`
+	result, err := tx.ExecContext(ctx, query, models.ReservationConfirmed, time.Now(), id, models.ReservationPending)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, tx.Commit()
+}
+
+// issues an invoice with its lines, unless the reservation already has one
+func (m *postgresDBRepo) InsertInvoice(inv models.Invoice) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var newID int
+
+	stmt := `insert into invoices (reservation_id, total, currency, created_at, updated_at)
+		values ($1, $2, $3, $4, $5)
+		on conflict (reservation_id) do nothing returning id`
+
+	err = tx.QueryRowContext(ctx, stmt,
+		inv.ReservationID,
+		inv.Total,
+		inv.Currency,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err = insertInvoiceLines(ctx, tx, newID, inv.Lines)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertInvoiceLines(ctx context.Context, tx *sql.Tx, invoiceID int, lines []models.InvoiceLine) error {
+	stmt := `insert into invoice_lines (invoice_id, description, quantity, unit_amount, amount, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)`
+
+	for _, l := range lines {
+		_, err := tx.ExecContext(ctx, stmt, invoiceID, l.Description, l.Quantity, l.UnitAmount, l.Amount, time.Now(), time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gets the invoice for a reservation with its lines
+func (m *postgresDBRepo) GetInvoiceForReservation(reservationID int) (models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var inv models.Invoice
+
+	query := `select id, reservation_id, total, currency, created_at, updated_at from invoices where reservation_id = $1`
+
+	err := m.DB.QueryRowContext(ctx, query, reservationID).Scan(
+		&inv.ID,
+		&inv.ReservationID,
+		&inv.Total,
+		&inv.Currency,
+		&inv.CreatedAt,
+		&inv.UpdatedAt,
+	)
+	if err != nil {
+		return inv, err
+	}
+
+	query = `select id, invoice_id, description, quantity, unit_amount, amount from invoice_lines
+	where invoice_id = $1 order by id`
+
+	rows, err := m.DB.QueryContext(ctx, query, inv.ID)
+	if err != nil {
+		return inv, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.InvoiceLine
+		err := rows.Scan(&l.ID, &l.InvoiceID, &l.Description, &l.Quantity, &l.UnitAmount, &l.Amount)
+		if err != nil {
+			return inv, err
+		}
+		inv.Lines = append(inv.Lines, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return inv, err
+	}
+
+	return inv, nil
+}
+
+// gets every tax and fee rule, fees first, in the order they were added. Rules for all rooms have no room
+func (m *postgresDBRepo) AllChargeRules() ([]models.ChargeRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var rules []models.ChargeRule
+
+	query := `select c.id, c.name, c.kind, c.method, c.per, c.amount, c.room_id, c.created_at, c.updated_at,
+	coalesce(rm.room_name, '')
+	from charge_rules c left join rooms rm on (c.room_id = rm.id)
+	order by c.kind, c.id`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return rules, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.ChargeRule
+		var roomID sql.NullInt64
+
+		err := rows.Scan(
+			&r.ID,
+			&r.Name,
+			&r.Kind,
+			&r.Method,
+			&r.Per,
+			&r.Amount,
+			&roomID,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+			&r.Room.RoomName,
+		)
+		if err != nil {
+			return rules, err
+		}
+
+		r.RoomID = int(roomID.Int64)
+		r.Room.ID = r.RoomID
+		rules = append(rules, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rules, err
+	}
+
+	return rules, nil
+}
+
+func (m *postgresDBRepo) InsertChargeRule(r models.ChargeRule) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `insert into charge_rules (name, kind, method, per, amount, room_id, created_at, updated_at)
+	values ($1, $2, $3, $4, $5, nullif($6, 0), $7, $8) returning id`
+
+	err := m.DB.QueryRowContext(ctx, stmt,
+		r.Name,
+		r.Kind,
+		r.Method,
+		r.Per,
+		r.Amount,
+		r.RoomID,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+func (m *postgresDBRepo) DeleteChargeRule(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `delete from charge_rules where id = $1`
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+
+	return err
+}
+
+// promoUses counts the confirmed reservations that used promo code p. Ones still waiting to be paid for
+// don't use the code up, as most of them never are
+const promoUses = `(select count(*) from reservations r where r.promo_code_id = p.id
+	and r.status in ('confirmed', 'checked-in', 'checked-out'))`
+
+// promo codes come with the number of reservations that used them
+const promoCodeQuery = `select p.id, p.code, p.description, p.method, p.amount, p.valid_from, p.valid_to,
+	p.room_id, p.max_uses, p.min_nights, p.created_at, p.updated_at, coalesce(rm.room_name, ''),
+	` + promoUses + `
+	from promo_codes p left join rooms rm on (p.room_id = rm.id)`
+
+func scanPromoCode(row scanner) (models.PromoCode, error) {
+	var p models.PromoCode
+	var from, to sql.NullTime
+	var roomID sql.NullInt64
+
+	err := row.Scan(
+		&p.ID,
+		&p.Code,
+		&p.Description,
+		&p.Method,
+		&p.Amount,
+		&from,
+		&to,
+		&roomID,
+		&p.MaxUses,
+		&p.MinNights,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.Room.RoomName,
+		&p.Uses,
+	)
+	if err != nil {
+		return p, err
+	}
+
+	p.ValidFrom = from.Time
+	p.ValidTo = to.Time
+	p.RoomID = int(roomID.Int64)
+	p.Room.ID = p.RoomID
+
+	return p, nil
+}
+
+func (m *postgresDBRepo) AllPromoCodes() ([]models.PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var codes []models.PromoCode
+
+	rows, err := m.DB.QueryContext(ctx, promoCodeQuery+` order by p.code`)
+	if err != nil {
+		return codes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanPromoCode(rows)
+		if err != nil {
+			return codes, err
+		}
+		codes = append(codes, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return codes, err
+	}
+
+	return codes, nil
+}
+
+func (m *postgresDBRepo) GetPromoCodeByID(id int) (models.PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanPromoCode(m.DB.QueryRowContext(ctx, promoCodeQuery+` where p.id = $1`, id))
+}
+
+// finds a promo code the way a guest typed it, codes are stored in upper case
+func (m *postgresDBRepo) GetPromoCodeByCode(code string) (models.PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanPromoCode(m.DB.QueryRowContext(ctx, promoCodeQuery+` where p.code = upper($1)`, strings.TrimSpace(code)))
+}
+
+func (m *postgresDBRepo) InsertPromoCode(p models.PromoCode) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	from := sql.NullTime{Time: p.ValidFrom, Valid: !p.ValidFrom.IsZero()}
+	to := sql.NullTime{Time: p.ValidTo, Valid: !p.ValidTo.IsZero()}
+
+	stmt := `insert into promo_codes (code, description, method, amount, valid_from, valid_to, room_id,
+		max_uses, min_nights, created_at, updated_at)
+		values (upper($1), $2, $3, $4, $5, $6, nullif($7, 0), $8, $9, $10, $11) returning id`
+
+	err := m.DB.QueryRowContext(ctx, stmt,
+		p.Code,
+		p.Description,
+		p.Method,
+		p.Amount,
+		from,
+		to,
+		p.RoomID,
+		p.MaxUses,
+		p.MinNights,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// deletes a promo code. Reservations made with it keep their price, which is on their invoice
+func (m *postgresDBRepo) DeletePromoCode(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `delete from promo_codes where id = $1`
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+
+	return err
+}
+
+// holds a room for a guest who is filling in their details, unless the dates are already taken,
+// in which case it returns sql.ErrNoRows
+func (m *postgresDBRepo) InsertHold(r models.RoomRestriction) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `insert into room_restrictions (start_date, end_date, room_id, restriction_id, expires_at,
+		created_at, updated_at)
+		select $1, $2, $3, $4, $5, $6, $7
+		where not exists (select 1 from room_restrictions rr where rr.room_id = $3
+			and $1 < rr.end_date and $2 > rr.start_date
+			and (rr.expires_at is null or rr.expires_at > now()))
+		returning id`
+
+	err := m.withRoomLocked(ctx, r.RoomID, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, stmt, r.StartDate, r.EndDate, r.RoomID, models.RestrictionHold, r.ExpiresAt,
+			time.Now(), time.Now()).Scan(&newID)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// turns a hold into the restriction for the reservation made from it, running out at r.ExpiresAt if the
+// reservation is still to be paid for. It reports false when the hold has run out or was for a
+// different room or dates
+func (m *postgresDBRepo) ConvertHold(holdID int, r models.RoomRestriction) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update room_restrictions set restriction_id = $1, reservation_id = $2, expires_at = $3,
+		updated_at = $4
+		where id = $5 and restriction_id = $6 and room_id = $7 and start_date = $8 and end_date = $9
+		and expires_at > now()`
+
+	expires := sql.NullTime{Time: r.ExpiresAt, Valid: !r.ExpiresAt.IsZero()}
+	result, err := m.DB.ExecContext(ctx, query, r.RestrictionID, r.ReservationID, expires, time.Now(),
+		holdID, models.RestrictionHold, r.RoomID, r.StartDate, r.EndDate)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// lets go of a hold before it runs out
+func (m *postgresDBRepo) ReleaseHold(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `delete from room_restrictions where id = $1 and restriction_id = $2`
+
+	_, err := m.DB.ExecContext(ctx, query, id, models.RestrictionHold)
+
+	return err
+}
+
+// clears out holds that have run out, returning how many there were
+func (m *postgresDBRepo) DeleteExpiredHolds() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `delete from room_restrictions where restriction_id = $1 and expires_at <= now()`
+
+	result, err := m.DB.ExecContext(ctx, query, models.RestrictionHold)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// cancels the reservations nobody paid for in time and lets their rooms go, returning how many there were
+func (m *postgresDBRepo) CancelAbandonedReservations() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `with abandoned as (
+			delete from room_restrictions where restriction_id = $1 and expires_at <= now()
+			returning reservation_id)
+		update reservations set status = $2, updated_at = $3
+		where id in (select reservation_id from abandoned) and status = $4`
+
+	result, err := m.DB.ExecContext(ctx, query, models.RestrictionReservation, models.ReservationCancelled, time.Now(),
+		models.ReservationPending)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// puts a guest on the waitlist for their dates
+func (m *postgresDBRepo) InsertWaitlistEntry(e models.WaitlistEntry) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `insert into waitlist_entries (first_name, last_name, email, start_date, end_date, room_id,
+		created_at, updated_at)
+		values ($1, $2, $3, $4, $5, nullif($6, 0), $7, $8) returning id`
+
+	err := m.DB.QueryRowContext(ctx, stmt,
+		e.FirstName,
+		e.LastName,
+		e.Email,
+		e.StartDate,
+		e.EndDate,
+		e.RoomID,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+const waitlistEntryQuery = `select w.id, w.first_name, w.last_name, w.email, w.start_date, w.end_date,
+	coalesce(w.room_id, 0), w.notified_at, coalesce(w.offered_room_id, 0), w.created_at, w.updated_at,
+	coalesce(rm.room_name, '')
+	from waitlist_entries w left join rooms rm on (w.room_id = rm.id)`
+
+func scanWaitlistEntry(row scanner) (models.WaitlistEntry, error) {
+	var e models.WaitlistEntry
+	var notifiedAt sql.NullTime
+
+	err := row.Scan(
+		&e.ID,
+		&e.FirstName,
+		&e.LastName,
+		&e.Email,
+		&e.StartDate,
+		&e.EndDate,
+		&e.RoomID,
+		&notifiedAt,
+		&e.OfferedRoomID,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+		&e.Room.RoomName,
+	)
+	if err != nil {
+		return e, err
+	}
+
+	e.NotifiedAt = notifiedAt.Time
+	e.Room.ID = e.RoomID
+
+	return e, nil
+}
+
+func (m *postgresDBRepo) GetWaitlistEntryByID(id int) (models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := m.DB.QueryRowContext(ctx, waitlistEntryQuery+` where w.id = $1`, id)
+
+	return scanWaitlistEntry(row)
+}
+
+// returns the guests still waiting for stays that haven't started, longest waiting first
+func (m *postgresDBRepo) WaitingWaitlistEntries() ([]models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var entries []models.WaitlistEntry
+
+	query := waitlistEntryQuery + ` where w.notified_at is null and w.start_date >= current_date
+		order by w.created_at, w.id`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+// returns the guests sent a link to book since a time, for stays that haven't ended
+func (m *postgresDBRepo) OpenWaitlistOffers(since time.Time) ([]models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var entries []models.WaitlistEntry
+
+	query := waitlistEntryQuery + ` where w.notified_at > $1 and w.offered_room_id is not null
+		and w.end_date > current_date order by w.notified_at, w.id`
+
+	rows, err := m.DB.QueryContext(ctx, query, since)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+// records that a guest on the waitlist is being sent a link to book, and the room that was free for them.
+// It reports false when they've been sent one already, so two runs at once can't both email them
+func (m *postgresDBRepo) UpdateWaitlistEntryNotified(id, roomID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update waitlist_entries set notified_at = $1, offered_room_id = $2, updated_at = $1
+		where id = $3 and notified_at is null`
+
+	result, err := m.DB.ExecContext(ctx, query, time.Now(), roomID, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// how many nights each room was booked and blocked from start up to end. Reservations here and bookings
+// on other sites both count as booked, holds don't
+func (m *postgresDBRepo) RoomOccupancy(start, end time.Time) ([]models.RoomOccupancy, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var occupancy []models.RoomOccupancy
+
+	//each restriction counts for the nights of it inside the range
+	query := `select rm.id, rm.room_name,
+		coalesce(sum(least(rr.end_date, $2::date) - greatest(rr.start_date, $1::date))
+			filter (where rr.restriction_id in ($3, $4)), 0),
+		coalesce(sum(least(rr.end_date, $2::date) - greatest(rr.start_date, $1::date))
+			filter (where rr.restriction_id = $5), 0)
+		from rooms rm
+		left join room_restrictions rr on (rr.room_id = rm.id and rr.start_date < $2 and rr.end_date > $1)
+		group by rm.id, rm.room_name
+		order by rm.room_name`
+
+	rows, err := m.DB.QueryContext(ctx, query, start, end, models.RestrictionReservation, models.RestrictionExternal,
+		models.RestrictionOwnerBlock)
+	if err != nil {
+		return occupancy, err
+	}
+	defer rows.Close()
+
+	nights := int(end.Sub(start).Hours()+12) / 24
+
+	for rows.Next() {
+		o := models.RoomOccupancy{Nights: nights}
+		err := rows.Scan(&o.Room.ID, &o.Room.RoomName, &o.Booked, &o.Blocked)
+		if err != nil {
+			return occupancy, err
+		}
+		occupancy = append(occupancy, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return occupancy, err
+	}
+
+	return occupancy, nil
+}
+
+// sums up the reservations arriving from start up to end
+func (m *postgresDBRepo) StayStats(start, end time.Time) (models.StayStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var s models.StayStats
+
+	query := `select count(*) filter (where status <> $3), count(*) filter (where status = $3),
+		coalesce(avg(end_date - start_date) filter (where status <> $3), 0)::float8,
+		coalesce(avg(start_date - created_at::date) filter (where status <> $3), 0)::float8
+		from reservations where start_date >= $1 and start_date < $2`
+
+	err := m.DB.QueryRowContext(ctx, query, start, end, models.ReservationCancelled).Scan(
+		&s.Reservations,
+		&s.Cancellations,
+		&s.AverageNights,
+		&s.AverageLead,
+	)
+
+	return s, err
+}
+
+// what the reservations arriving from start up to end were invoiced and paid, by the month they arrive
+// in. Cancelled reservations are left out, and months without any arrivals aren't returned
+func (m *postgresDBRepo) RevenueByMonth(start, end time.Time) ([]models.MonthRevenue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var months []models.MonthRevenue
+
+	query := `select date_trunc('month', r.start_date)::date, count(*), coalesce(sum(i.total), 0),
+		coalesce(sum((select sum(p.amount) from payments p where p.reservation_id = r.id and p.status = $4)), 0)
+		from reservations r
+		left join invoices i on (i.reservation_id = r.id)
+		where r.start_date >= $1 and r.start_date < $2 and r.status <> $3
+		group by 1
+		order by 1`
+
+	rows, err := m.DB.QueryContext(ctx, query, start, end, models.ReservationCancelled, models.PaymentPaid)
+	if err != nil {
+		return months, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mr models.MonthRevenue
+		err := rows.Scan(&mr.Month, &mr.Reservations, &mr.Invoiced, &mr.Paid)
+		if err != nil {
+			return months, err
+		}
+		months = append(months, mr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return months, err
+	}
+
+	return months, nil
+}
+
+// how many guests arrive on day, leave on it and are staying the night of it
+func (m *postgresDBRepo) CountDay(day time.Time) (models.DayCounts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var c models.DayCounts
+
+	query := `select count(*) filter (where start_date = $1), count(*) filter (where end_date = $1),
+		count(*) filter (where start_date <= $1 and end_date > $1)
+		from reservations where status <> $2 and start_date <= $1 and end_date >= $1`
+
+	err := m.DB.QueryRowContext(ctx, query, day, models.ReservationCancelled).Scan(&c.Arrivals, &c.Departures, &c.InHouse)
+
+	return c, err
+}
+
+// the reservations that arrive on day, leave on it or are staying the night of it, by room and then
+// guest. Cancelled reservations are left out
+func (m *postgresDBRepo) ReservationsForDay(day time.Time) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id,
+		r.status, r.source, r.checked_in_at, r.checked_out_at, r.special_requests, rm.id, rm.room_name
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		where r.status <> $2 and r.start_date <= $1 and r.end_date >= $1
+		order by rm.room_name, r.last_name, r.first_name`
+
+	rows, err := m.DB.QueryContext(ctx, query, day, models.ReservationCancelled)
+	if err != nil {
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Reservation
+		var checkedIn, checkedOut sql.NullTime
+		err := rows.Scan(
+			&r.ID,
+			&r.FirstName,
+			&r.LastName,
+			&r.Email,
+			&r.Phone,
+			&r.StartDate,
+			&r.EndDate,
+			&r.RoomID,
+			&r.Status,
+			&r.Source,
+			&checkedIn,
+			&checkedOut,
+			&r.SpecialRequests,
+			&r.Room.ID,
+			&r.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+		r.CheckedInAt = checkedIn.Time
+		r.CheckedOutAt = checkedOut.Time
+		reservations = append(reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// checks the guest of a confirmed reservation in, reporting whether it was confirmed and not yet
+// checked in
+func (m *postgresDBRepo) CheckInReservation(id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update reservations set status = $1, checked_in_at = $2, updated_at = $2 where id = $3 and status = $4`
+	result, err := m.DB.ExecContext(ctx, query, models.ReservationCheckedIn, time.Now(), id, models.ReservationConfirmed)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// checks the guest of a reservation out, reporting whether they were checked in
+func (m *postgresDBRepo) CheckOutReservation(id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update reservations set status = $1, checked_out_at = $2, updated_at = $2 where id = $3 and status = $4`
+	result, err := m.DB.ExecContext(ctx, query, models.ReservationCheckedOut, time.Now(), id, models.ReservationCheckedIn)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// adds an entry to the audit log. Entries are never changed or removed once they're in
+func (m *postgresDBRepo) InsertAuditEntry(e models.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	userID := sql.NullInt64{Int64: int64(e.UserID), Valid: e.UserID > 0}
+	before := sql.NullString{String: e.Before, Valid: e.Before != ""}
+	after := sql.NullString{String: e.After, Valid: e.After != ""}
+
+	stmt := `insert into audit_log (user_id, action, entity, entity_id, before, after, ip, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $8)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, e.Action, e.Entity, e.EntityID, before, after, e.IP, time.Now())
+
+	return err
+}
+
+// SearchAuditLog returns a page of the audit entries the filter matches, newest first, with who made
+// them, and how many match altogether
+func (m *postgresDBRepo) SearchAuditLog(f models.AuditFilter) ([]models.AuditEntry, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var entries []models.AuditEntry
+
+	where, args := auditWhere(f)
+
+	var total int
+	err := m.DB.QueryRowContext(ctx, `select count(*) from audit_log a `+where, args...).Scan(&total)
+	if err != nil {
+		return entries, 0, err
+	}
+
+	query := `select a.id, coalesce(a.user_id, 0), coalesce(u.first_name, ''), coalesce(u.last_name, ''),
+		a.action, a.entity, a.entity_id, coalesce(a.before, ''), coalesce(a.after, ''), a.ip, a.created_at
+		from audit_log a left join users u on (a.user_id = u.id) ` + where + `
+		order by a.created_at desc, a.id desc`
+
+	if f.Limit > 0 {
+		args = append(args, f.Limit, f.Offset)
+		query += fmt.Sprintf(" limit $%d offset $%d", len(args)-1, len(args))
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return entries, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.UserID,
+			&e.User.FirstName,
+			&e.User.Lastname,
+			&e.Action,
+			&e.Entity,
+			&e.EntityID,
+			&e.Before,
+			&e.After,
+			&e.IP,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return entries, 0, err
+		}
+		e.User.ID = e.UserID
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// auditWhere builds the where clause for an audit log filter, with the arguments for it
+func auditWhere(f models.AuditFilter) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if f.UserID > 0 {
+		add("a.user_id = ?", f.UserID)
+	}
+	if f.Action != "" {
+		add("a.action = ?", f.Action)
+	}
+	if f.Entity != "" {
+		add("a.entity = ?", f.Entity)
+	}
+	if f.EntityID > 0 {
+		add("a.entity_id = ?", f.EntityID)
+	}
+	if !f.From.IsZero() {
+		add("a.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		add("a.created_at < ?", f.To)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "where " + strings.Join(conds, " and "), args
+}
+
+// the users that have made changes in the audit log, by name
+func (m *postgresDBRepo) AuditLogUsers() ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var users []models.User
+
+	query := `select u.id, u.first_name, u.last_name from users u
+		where exists (select 1 from audit_log a where a.user_id = u.id)
+		order by u.first_name, u.last_name`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(&u.ID, &u.FirstName, &u.Lastname)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return users, err
+	}
+
+	return users, nil
+}
+
+// adds a staff note to a reservation, returning its id
+func (m *postgresDBRepo) InsertReservationNote(n models.ReservationNote) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into reservation_notes (reservation_id, parent_id, user_id, body, created_at, updated_at)
+		values ($1, nullif($2, 0), nullif($3, 0), $4, $5, $5) returning id`
+
+	var id int
+	err := m.DB.QueryRowContext(ctx, stmt, n.ReservationID, n.ParentID, n.UserID, n.Body, time.Now()).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// the staff notes on a reservation, oldest first, with who wrote them. Replies aren't threaded under
+// the notes they answer
+func (m *postgresDBRepo) NotesForReservation(reservationID int) ([]models.ReservationNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var notes []models.ReservationNote
+
+	query := `select n.id, n.reservation_id, coalesce(n.parent_id, 0), coalesce(n.user_id, 0),
+		coalesce(u.first_name, ''), coalesce(u.last_name, ''), n.body, n.created_at, n.updated_at
+		from reservation_notes n left join users u on (n.user_id = u.id)
+		where n.reservation_id = $1
+		order by n.created_at, n.id`
+
+	rows, err := m.DB.QueryContext(ctx, query, reservationID)
+	if err != nil {
+		return notes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.ReservationNote
+		err := rows.Scan(
+			&n.ID,
+			&n.ReservationID,
+			&n.ParentID,
+			&n.UserID,
+			&n.Author.FirstName,
+			&n.Author.Lastname,
+			&n.Body,
+			&n.CreatedAt,
+			&n.UpdatedAt,
+		)
+		if err != nil {
+			return notes, err
+		}
+		n.Author.ID = n.UserID
+		notes = append(notes, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return notes, err
+	}
+
+	return notes, nil
 }
@@ -1,8 +1,12 @@
 package dbrepo
 
 import (
+	"BookingProject/pkg/helpers"
 	"BookingProject/pkg/models"
+	"BookingProject/pkg/repository"
+	"database/sql"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -17,6 +21,10 @@ func (m *testDBRepo) InsertReservation(res models.Reservation) (int, error) {
 	if res.RoomID == 2 {
 		return 0, errors.New("some error")
 	}
+	//promo code 3 is used up by someone else in the meantime
+	if res.PromoCodeID == 3 {
+		return 0, repository.ErrPromoUsedUp
+	}
 	return 1, nil
 }
 
@@ -28,14 +36,41 @@ func (m *testDBRepo) InsertRoomRestriction(r models.RoomRestriction) error {
 	return nil
 }
 
+func (m *testDBRepo) InsertReservationRestriction(r models.RoomRestriction) error {
+
+	//room 1000 and 2060 make the query fail, and 2070 is already taken
+	if r.RoomID == 1000 || r.StartDate.Year() == 2060 {
+		return errors.New("some error")
+	}
+	if r.StartDate.Year() == 2070 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func (m *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error) {
+
+	//2040 is free, 2060 makes the query fail, everything else is booked
+	switch start.Year() {
+	case 2040:
+		return true, nil
+	case 2060:
+		return false, errors.New("some error")
+	}
 	return false, nil
 
 }
 
 func (m *testDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error) {
 
+	//2040 is free, 2060 makes the query fail, everything else is booked
 	var rooms []models.Room
+	switch start.Year() {
+	case 2040:
+		rooms = append(rooms, models.Room{ID: 1, RoomName: "General's Quarters"})
+	case 2060:
+		return rooms, errors.New("some error")
+	}
 	return rooms, nil
 }
 
@@ -43,9 +78,22 @@ func (m *testDBRepo) GetRoomByID(id int) (models.Room, error) {
 
 	var room models.Room
 
-	if id > 2 {
+	//rooms 1 and 2 exist, and so do 4, which takes stays of 3 nights or more, and 5, which costs
+	//$89.50 a night. Room 1000 makes the query fail
+	if id == 1000 {
 		return room, errors.New("some error")
 	}
+	if id == 4 {
+		room.MinNights = 3
+		return room, nil
+	}
+	if id == 5 {
+		room.Price = 8950
+		return room, nil
+	}
+	if id > 2 {
+		return room, sql.ErrNoRows
+	}
 
 	return room, nil
 }
@@ -65,18 +113,84 @@ func (m *testDBRepo) Authenticate(email, testPassword string) (int, string, erro
 	return 1, "", nil
 }
 
-func (m *testDBRepo) AllReservations() ([]models.Reservation, error) {
+func (m *testDBRepo) SearchReservations(f models.ReservationFilter) ([]models.Reservation, int, error) {
 	var reservations []models.Reservation
-	return reservations, nil
+
+	if f.Search == "error" {
+		return reservations, 0, errors.New("some error")
+	}
+
+	return reservations, 0, nil
 }
 
-func (m *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
-	var reservations []models.Reservation
-	return reservations, nil
+func (m *testDBRepo) ImportReservations(rs []models.Reservation) (int, error) {
+	for i, res := range rs {
+		switch res.StartDate.Year() {
+		case 2060:
+			return i, errors.New("some error")
+		case 2070:
+			//room taken since the preview
+			return i, sql.ErrNoRows
+		}
+	}
+
+	return len(rs), nil
+}
+
+func (m *testDBRepo) EachReservation(f models.ReservationFilter, fn func(models.ReservationAmounts) error) error {
+	if f.Search == "error" {
+		return errors.New("some error")
+	}
+
+	res := models.ReservationAmounts{
+		Reservation: models.Reservation{
+			ID:        1,
+			FirstName: "John",
+			LastName:  "Smith",
+			Email:     "john@smith.com",
+			StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+			RoomID:    1,
+			Room:      models.Room{ID: 1, RoomName: "General's Quarters"},
+			Status:    models.ReservationConfirmed,
+			Source:    models.SourcePhone,
+		},
+		Invoiced: 30000,
+		Paid:     7500,
+		Currency: "USD",
+	}
+
+	return fn(res)
+}
+
+func (m *testDBRepo) InsertReservationWithRestriction(res models.Reservation) (int, error) {
+	switch res.StartDate.Year() {
+	case 2060:
+		return 0, errors.New("some error")
+	case 2070:
+		//room already taken
+		return 0, sql.ErrNoRows
+	}
+
+	return 1, nil
 }
 
 func (m *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
 	var res models.Reservation
+
+	if id > 100 {
+		return res, sql.ErrNoRows
+	}
+
+	//8 is checked in and 9 checked out
+	res.ID = id
+	res.Status = models.ReservationConfirmed
+	switch id {
+	case 8:
+		res.Status = models.ReservationCheckedIn
+	case 9:
+		res.Status = models.ReservationCheckedOut
+	}
 	return res, nil
 }
 
@@ -89,6 +203,22 @@ func (m *testDBRepo) DeleteReservation(id int) error {
 	return nil
 }
 
+func (m *testDBRepo) CancelReservation(id int) error {
+	return nil
+}
+
+func (m *testDBRepo) MoveReservation(res models.Reservation, inv models.Invoice) (bool, error) {
+	switch res.StartDate.Year() {
+	case 2060:
+		return false, errors.New("some error")
+	case 2070:
+		//room already taken
+		return false, nil
+	}
+
+	return true, nil
+}
+
 func (m *testDBRepo) UpdateProcessedForReservation(id, processed int) error {
 	return nil
 }
@@ -105,11 +235,502 @@ func (m *testDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Ti
 	return restrictions, nil
 }
 
-func (m *testDBRepo) InsertBlockForRoom(id int, startDate time.Time) error {
-	return nil
+func (m *testDBRepo) GetRestrictionsForAllRoomsByDate(start, end time.Time) ([]models.RoomRestriction, error) {
+
+	//room 1 is taken for the first week of 2050
+	restrictions := []models.RoomRestriction{
+		{RoomID: 1, StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2050, 1, 8, 0, 0, 0, 0, time.UTC)},
+	}
+
+	return restrictions, nil
+}
+
+func (m *testDBRepo) GetTakenRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+	var restrictions []models.RoomRestriction
+
+	//room 1 is taken for the first week of 2050
+	if roomID == 1 {
+		restrictions = append(restrictions, models.RoomRestriction{RoomID: 1,
+			StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2050, 1, 8, 0, 0, 0, 0, time.UTC)})
+	}
+
+	return restrictions, nil
+}
+
+func (m *testDBRepo) GetRestrictionsForCalendar(start, end time.Time) ([]models.RoomRestriction, error) {
+	var restrictions []models.RoomRestriction
+	return restrictions, nil
+}
+
+func (m *testDBRepo) InsertBlockForRoom(id int, startDate time.Time) (int, error) {
+	return 1, nil
 }
 
 func (m *testDBRepo) DeleteBlockByID(id int) error {
+	//blocks are the ids up to 100, anything else isn't one
+	if id > 100 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (m *testDBRepo) GetBlockByID(id int) (models.RoomRestriction, error) {
+	if id > 100 {
+		return models.RoomRestriction{}, sql.ErrNoRows
+	}
+
+	return models.RoomRestriction{
+		ID:            id,
+		RoomID:        1,
+		RestrictionID: models.RestrictionOwnerBlock,
+		StartDate:     time.Date(2050, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2050, 1, 13, 0, 0, 0, 0, time.UTC),
+		Reason:        "Maintenance",
+	}, nil
+}
+
+func (m *testDBRepo) UpcomingBlocks() ([]models.RoomRestriction, error) {
+	var blocks []models.RoomRestriction
+	return blocks, nil
+}
+
+func (m *testDBRepo) InsertBlock(r models.RoomRestriction) (int, error) {
+	switch r.StartDate.Year() {
+	case 2060:
+		return 0, errors.New("some error")
+	case 2070:
+		//already taken
+		return 0, sql.ErrNoRows
+	}
+
+	return 1, nil
+}
+
+func (m *testDBRepo) UpdateBlock(r models.RoomRestriction) (bool, error) {
+	switch r.StartDate.Year() {
+	case 2060:
+		return false, errors.New("some error")
+	case 2070:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (m *testDBRepo) InsertAPIKey(k models.APIKey) (int, error) {
+	return 1, nil
+}
+
+func (m *testDBRepo) AllAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	return keys, nil
+}
+
+func (m *testDBRepo) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+	//"read-key" may only read rooms, "revoked-key" has been revoked, anything else is unknown
+	switch hash {
+	case helpers.HashAPIKey("read-key"):
+		return models.APIKey{ID: 1, Hash: hash, Scopes: []string{models.ScopeRoomsRead}}, nil
+	case helpers.HashAPIKey("revoked-key"):
+		return models.APIKey{ID: 2, Hash: hash, Scopes: models.APIScopes, RevokedAt: time.Now()}, nil
+	}
+
+	return models.APIKey{}, sql.ErrNoRows
+}
+
+func (m *testDBRepo) GetAPIKeyByID(id int) (models.APIKey, error) {
+	if id > 100 {
+		return models.APIKey{}, sql.ErrNoRows
+	}
+
+	return models.APIKey{ID: id, Name: "Channel manager", Prefix: "bk_read", Scopes: []string{models.ScopeRoomsRead}}, nil
+}
+
+func (m *testDBRepo) RevokeAPIKey(id int) error {
+	return nil
+}
+
+func (m *testDBRepo) UpdateAPIKeyLastUsed(id int) error {
+	return nil
+}
+
+func (m *testDBRepo) GetRoomByICalToken(token string) (models.Room, error) {
+	if token != "room-token" {
+		return models.Room{}, sql.ErrNoRows
+	}
+
+	return models.Room{ID: 1, RoomName: "General's Quarters", ICalToken: token}, nil
+}
+
+func (m *testDBRepo) UpdateRoomICalToken(id int, token string) error {
+	return nil
+}
+
+func (m *testDBRepo) UpdateRoomMinNights(id, minNights int) error {
+	return nil
+}
+
+func (m *testDBRepo) GetUserByICalToken(token string) (models.User, error) {
+	if token != "staff-token" {
+		return models.User{}, sql.ErrNoRows
+	}
+
+	return models.User{ID: 1, ICalToken: token}, nil
+}
+
+func (m *testDBRepo) UpdateUserICalToken(id int, token string) error {
+	return nil
+}
+
+func (m *testDBRepo) AllICalFeeds() ([]models.ICalFeed, error) {
+	var feeds []models.ICalFeed
+	return feeds, nil
+}
+
+func (m *testDBRepo) GetICalFeedByID(id int) (models.ICalFeed, error) {
+	if id > 100 {
+		return models.ICalFeed{}, sql.ErrNoRows
+	}
+
+	return models.ICalFeed{ID: id, RoomID: 1, Name: "Other Site", URL: "http://localhost/other.ics"}, nil
+}
+
+func (m *testDBRepo) InsertICalFeed(f models.ICalFeed) (int, error) {
+	return 1, nil
+}
+
+func (m *testDBRepo) DeleteICalFeed(id int) error {
+	return nil
+}
+
+func (m *testDBRepo) UpdateICalFeedSynced(id int, syncErr string) error {
+	return nil
+}
+
+func (m *testDBRepo) GetRestrictionsForICalFeed(feedID int) ([]models.RoomRestriction, error) {
+	var restrictions []models.RoomRestriction
+	if feedID == 2 {
+		restrictions = append(restrictions, models.RoomRestriction{
+			ID:            201,
+			RoomID:        1,
+			RestrictionID: models.RestrictionExternal,
+			ICalFeedID:    feedID,
+			ExternalUID:   "gone@other",
+			StartDate:     time.Date(2050, 2, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:       time.Date(2050, 2, 3, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	return restrictions, nil
+}
 
+func (m *testDBRepo) InsertExternalBooking(r models.RoomRestriction) error {
 	return nil
 }
+
+func (m *testDBRepo) UpdateExternalBooking(r models.RoomRestriction) error {
+	return nil
+}
+
+func (m *testDBRepo) DeleteExternalBooking(id int) error {
+	//external bookings are the ids above 200
+	if id <= 200 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (m *testDBRepo) GetBookingConflicts() ([]models.BookingConflict, error) {
+	var conflicts []models.BookingConflict
+	return conflicts, nil
+}
+
+func (m *testDBRepo) InsertPayment(p models.Payment) (int, error) {
+	return 1, nil
+}
+
+func (m *testDBRepo) GetPaymentByID(id int) (models.Payment, error) {
+	if id > 100 {
+		return models.Payment{}, sql.ErrNoRows
+	}
+
+	return models.Payment{ID: id, ReservationID: 1, Provider: "fake", Amount: 1790, Currency: "USD", Status: models.PaymentPending}, nil
+}
+
+func (m *testDBRepo) GetPaymentByProviderRef(provider, ref string) (models.Payment, error) {
+	if ref != "fake_1" {
+		return models.Payment{}, sql.ErrNoRows
+	}
+
+	return models.Payment{ID: 1, ReservationID: 1, Provider: provider, ProviderRef: ref, Amount: 1790, Currency: "USD",
+		Status: models.PaymentPending}, nil
+}
+
+func (m *testDBRepo) GetPaymentsForReservation(reservationID int) ([]models.Payment, error) {
+	var payments []models.Payment
+
+	//reservation 7 has been paid for
+	if reservationID == 7 {
+		payments = append(payments, models.Payment{ID: 7, ReservationID: 7, Provider: "fake", Kind: models.PaymentFull,
+			Amount: 1790, Currency: "USD", Status: models.PaymentPaid})
+	}
+	return payments, nil
+}
+
+func (m *testDBRepo) UpdatePaymentProviderRef(id int, ref string) error {
+	return nil
+}
+
+func (m *testDBRepo) UpdatePaymentStatus(id int, status string) error {
+	return nil
+}
+
+func (m *testDBRepo) ConfirmReservation(id int) (bool, error) {
+	return true, nil
+}
+
+func (m *testDBRepo) InsertInvoice(inv models.Invoice) error {
+	return nil
+}
+
+func (m *testDBRepo) GetInvoiceForReservation(reservationID int) (models.Invoice, error) {
+	if reservationID > 100 {
+		return models.Invoice{}, sql.ErrNoRows
+	}
+
+	return models.Invoice{
+		ID:            1,
+		ReservationID: reservationID,
+		Total:         17800,
+		Currency:      "USD",
+		Lines:         []models.InvoiceLine{{ID: 1, InvoiceID: 1, Description: "General's Quarters", Quantity: 2, UnitAmount: 8900, Amount: 17800}},
+	}, nil
+}
+
+func (m *testDBRepo) AllChargeRules() ([]models.ChargeRule, error) {
+	var rules []models.ChargeRule
+	return rules, nil
+}
+
+func (m *testDBRepo) InsertChargeRule(r models.ChargeRule) (int, error) {
+	return 1, nil
+}
+
+func (m *testDBRepo) DeleteChargeRule(id int) error {
+	return nil
+}
+
+func (m *testDBRepo) AllPromoCodes() ([]models.PromoCode, error) {
+	var codes []models.PromoCode
+	return codes, nil
+}
+
+func (m *testDBRepo) GetPromoCodeByID(id int) (models.PromoCode, error) {
+	if id > 100 {
+		return models.PromoCode{}, sql.ErrNoRows
+	}
+
+	return models.PromoCode{ID: id, Code: "SPRING", Method: models.ChargePercent, Amount: 1500}, nil
+}
+
+// SPRING can be used for anything, USEDUP has no uses left, and no other code exists
+func (m *testDBRepo) GetPromoCodeByCode(code string) (models.PromoCode, error) {
+	switch strings.ToUpper(code) {
+	case "SPRING":
+		return models.PromoCode{ID: 1, Code: "SPRING", Method: models.ChargePercent, Amount: 1500}, nil
+	case "USEDUP":
+		return models.PromoCode{ID: 2, Code: "USEDUP", Method: models.ChargeFlat, Amount: 1000, MaxUses: 1, Uses: 1}, nil
+	case "LASTONE":
+		return models.PromoCode{ID: 3, Code: "LASTONE", Method: models.ChargeFlat, Amount: 1000, MaxUses: 1}, nil
+	}
+
+	return models.PromoCode{}, sql.ErrNoRows
+}
+
+func (m *testDBRepo) InsertPromoCode(p models.PromoCode) (int, error) {
+	return 1, nil
+}
+
+func (m *testDBRepo) DeletePromoCode(id int) error {
+	return nil
+}
+
+func (m *testDBRepo) InsertHold(r models.RoomRestriction) (int, error) {
+
+	//room 1000 makes the query fail, and 2070 is already taken
+	if r.RoomID == 1000 {
+		return 0, errors.New("some error")
+	}
+	if r.StartDate.Year() == 2070 {
+		return 0, sql.ErrNoRows
+	}
+	return 1, nil
+}
+
+func (m *testDBRepo) ConvertHold(holdID int, r models.RoomRestriction) (bool, error) {
+
+	//the hold has run out for stays in 2040, 2060 and 2070, so they fall back on searching
+	switch r.StartDate.Year() {
+	case 2040, 2060, 2070:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (m *testDBRepo) ReleaseHold(id int) error {
+	return nil
+}
+
+func (m *testDBRepo) DeleteExpiredHolds() (int, error) {
+	return 0, nil
+}
+
+func (m *testDBRepo) CancelAbandonedReservations() (int, error) {
+	return 0, nil
+}
+
+func (m *testDBRepo) InsertWaitlistEntry(e models.WaitlistEntry) (int, error) {
+	return 1, nil
+}
+
+func (m *testDBRepo) GetWaitlistEntryByID(id int) (models.WaitlistEntry, error) {
+
+	//ids over 100 don't exist, and 2 is waiting for room 1 in 2050, which is booked
+	if id > 100 {
+		return models.WaitlistEntry{}, sql.ErrNoRows
+	}
+
+	e := models.WaitlistEntry{
+		ID:        id,
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     "john@smith.com",
+		StartDate: time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2040, 1, 2, 0, 0, 0, 0, time.UTC),
+		RoomID:    1,
+	}
+	if id == 2 {
+		e.StartDate = time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+		e.EndDate = time.Date(2050, 1, 2, 0, 0, 0, 0, time.UTC)
+	}
+
+	return e, nil
+}
+
+func (m *testDBRepo) WaitingWaitlistEntries() ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+	for _, id := range []int{1, 2} {
+		e, _ := m.GetWaitlistEntryByID(id)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (m *testDBRepo) OpenWaitlistOffers(since time.Time) ([]models.WaitlistEntry, error) {
+	return nil, nil
+}
+
+func (m *testDBRepo) UpdateWaitlistEntryNotified(id, roomID int) (bool, error) {
+	return true, nil
+}
+
+func (m *testDBRepo) RoomOccupancy(start, end time.Time) ([]models.RoomOccupancy, error) {
+	if start.Year() == 2060 {
+		return nil, errors.New("some error")
+	}
+
+	nights := int(end.Sub(start).Hours()+12) / 24
+	return []models.RoomOccupancy{
+		{Room: models.Room{ID: 1, RoomName: "General's Quarters"}, Nights: nights, Booked: nights / 2},
+		{Room: models.Room{ID: 2, RoomName: "Major's Suite"}, Nights: nights, Booked: nights / 4, Blocked: nights / 2},
+	}, nil
+}
+
+func (m *testDBRepo) StayStats(start, end time.Time) (models.StayStats, error) {
+	return models.StayStats{Reservations: 8, Cancellations: 2, AverageNights: 2.5, AverageLead: 14}, nil
+}
+
+func (m *testDBRepo) RevenueByMonth(start, end time.Time) ([]models.MonthRevenue, error) {
+	return []models.MonthRevenue{
+		{Month: time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC), Reservations: 8, Invoiced: 240000, Paid: 180000},
+	}, nil
+}
+
+func (m *testDBRepo) CountDay(day time.Time) (models.DayCounts, error) {
+	return models.DayCounts{Arrivals: 1, Departures: 2, InHouse: 3}, nil
+}
+
+func (m *testDBRepo) ReservationsForDay(day time.Time) ([]models.Reservation, error) {
+	if day.Year() == 2060 {
+		return nil, errors.New("some error")
+	}
+
+	room := models.Room{ID: 1, RoomName: "General's Quarters"}
+	return []models.Reservation{
+		{ID: 1, FirstName: "John", LastName: "Smith", StartDate: day, EndDate: day.AddDate(0, 0, 2), RoomID: 1, Room: room,
+			Status: models.ReservationConfirmed},
+		{ID: 2, FirstName: "Jane", LastName: "Doe", StartDate: day.AddDate(0, 0, -2), EndDate: day, RoomID: 1, Room: room,
+			Status: models.ReservationCheckedIn},
+		{ID: 3, FirstName: "Ann", LastName: "Lee", StartDate: day.AddDate(0, 0, -1), EndDate: day.AddDate(0, 0, 1), RoomID: 2,
+			Room: models.Room{ID: 2, RoomName: "Major's Suite"}, Status: models.ReservationCheckedIn},
+	}, nil
+}
+
+func (m *testDBRepo) CheckInReservation(id int) (bool, error) {
+	if id > 100 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (m *testDBRepo) CheckOutReservation(id int) (bool, error) {
+	if id > 100 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (m *testDBRepo) InsertAuditEntry(e models.AuditEntry) error {
+	return nil
+}
+
+func (m *testDBRepo) SearchAuditLog(f models.AuditFilter) ([]models.AuditEntry, int, error) {
+	if f.Action == "error" {
+		return nil, 0, errors.New("some error")
+	}
+
+	return []models.AuditEntry{
+		{ID: 2, UserID: 1, User: models.User{ID: 1, FirstName: "Admin", Lastname: "User"}, Action: models.AuditUpdate,
+			Entity: models.EntityReservation, EntityID: 1, Before: `{"FirstName":"Jon","Phone":""}`,
+			After: `{"FirstName":"John","Phone":"555"}`, IP: "127.0.0.1"},
+		{ID: 1, UserID: 1, User: models.User{ID: 1, FirstName: "Admin", Lastname: "User"}, Action: models.AuditCreate,
+			Entity: models.EntityBlock, After: `{"RoomID":1}`, IP: "127.0.0.1"},
+	}, 2, nil
+}
+
+func (m *testDBRepo) AuditLogUsers() ([]models.User, error) {
+	return []models.User{{ID: 1, FirstName: "Admin", Lastname: "User"}}, nil
+}
+
+func (m *testDBRepo) InsertReservationNote(n models.ReservationNote) (int, error) {
+	if n.Body == "error" {
+		return 0, errors.New("some error")
+	}
+
+	return 3, nil
+}
+
+func (m *testDBRepo) NotesForReservation(reservationID int) ([]models.ReservationNote, error) {
+	if reservationID > 100 {
+		return nil, nil
+	}
+
+	author := models.User{ID: 1, FirstName: "Admin", Lastname: "User"}
+	return []models.ReservationNote{
+		{ID: 1, ReservationID: reservationID, UserID: 1, Author: author, Body: "Late arrival, after 10pm"},
+		{ID: 2, ReservationID: reservationID, ParentID: 1, UserID: 1, Author: author, Body: "Key left at the bar"},
+	}, nil
+}
@@ -2,16 +2,26 @@ package repository
 
 import (
 	"BookingProject/pkg/models"
+	"errors"
 	"time"
 )
 
+// ErrPromoUsedUp is returned when a reservation is added with a promo code that has no uses left
+var ErrPromoUsedUp = errors.New("promo code used up")
+
 type DatabaseRepo interface {
 	AllUsers() bool
 
 	InsertReservation(res models.Reservation) (int, error)
 
+	InsertReservationWithRestriction(res models.Reservation) (int, error)
+
+	ImportReservations(rs []models.Reservation) (int, error)
+
 	InsertRoomRestriction(r models.RoomRestriction) error
 
+	InsertReservationRestriction(r models.RoomRestriction) error
+
 	SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error)
 
 	SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error)
@@ -24,9 +34,9 @@ type DatabaseRepo interface {
 
 	Authenticate(email, testPassword string) (int, string, error)
 
-	AllReservations() ([]models.Reservation, error)
+	SearchReservations(f models.ReservationFilter) ([]models.Reservation, int, error)
 
-	AllNewReservations() ([]models.Reservation, error)
+	EachReservation(f models.ReservationFilter, fn func(models.ReservationAmounts) error) error
 
 	GetReservationByID(id int) (models.Reservation, error)
 
@@ -34,13 +44,151 @@ type DatabaseRepo interface {
 
 	DeleteReservation(id int) error
 
+	CancelReservation(id int) error
+
 	UpdateProcessedForReservation(id, processed int) error
 
+	MoveReservation(res models.Reservation, inv models.Invoice) (bool, error)
+
 	AllRooms() ([]models.Room, error)
 
 	GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
 
-	InsertBlockForRoom(id int, startDate time.Time) error
+	GetRestrictionsForAllRoomsByDate(start, end time.Time) ([]models.RoomRestriction, error)
+
+	GetTakenRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
+
+	GetRestrictionsForCalendar(start, end time.Time) ([]models.RoomRestriction, error)
+
+	InsertBlockForRoom(id int, startDate time.Time) (int, error)
 
 	DeleteBlockByID(id int) error
+
+	GetBlockByID(id int) (models.RoomRestriction, error)
+
+	UpcomingBlocks() ([]models.RoomRestriction, error)
+
+	InsertBlock(r models.RoomRestriction) (int, error)
+
+	UpdateBlock(r models.RoomRestriction) (bool, error)
+
+	InsertAPIKey(k models.APIKey) (int, error)
+
+	AllAPIKeys() ([]models.APIKey, error)
+
+	GetAPIKeyByHash(hash string) (models.APIKey, error)
+
+	GetAPIKeyByID(id int) (models.APIKey, error)
+
+	RevokeAPIKey(id int) error
+
+	UpdateAPIKeyLastUsed(id int) error
+
+	GetRoomByICalToken(token string) (models.Room, error)
+
+	UpdateRoomICalToken(id int, token string) error
+
+	UpdateRoomMinNights(id, minNights int) error
+
+	GetUserByICalToken(token string) (models.User, error)
+
+	UpdateUserICalToken(id int, token string) error
+
+	AllICalFeeds() ([]models.ICalFeed, error)
+
+	GetICalFeedByID(id int) (models.ICalFeed, error)
+
+	InsertICalFeed(f models.ICalFeed) (int, error)
+
+	DeleteICalFeed(id int) error
+
+	UpdateICalFeedSynced(id int, syncErr string) error
+
+	GetRestrictionsForICalFeed(feedID int) ([]models.RoomRestriction, error)
+
+	InsertExternalBooking(r models.RoomRestriction) error
+
+	UpdateExternalBooking(r models.RoomRestriction) error
+
+	DeleteExternalBooking(id int) error
+
+	GetBookingConflicts() ([]models.BookingConflict, error)
+
+	InsertPayment(p models.Payment) (int, error)
+
+	GetPaymentByID(id int) (models.Payment, error)
+
+	GetPaymentByProviderRef(provider, ref string) (models.Payment, error)
+
+	GetPaymentsForReservation(reservationID int) ([]models.Payment, error)
+
+	UpdatePaymentProviderRef(id int, ref string) error
+
+	UpdatePaymentStatus(id int, status string) error
+
+	ConfirmReservation(id int) (bool, error)
+
+	InsertInvoice(inv models.Invoice) error
+
+	GetInvoiceForReservation(reservationID int) (models.Invoice, error)
+
+	AllChargeRules() ([]models.ChargeRule, error)
+
+	InsertChargeRule(r models.ChargeRule) (int, error)
+
+	DeleteChargeRule(id int) error
+
+	AllPromoCodes() ([]models.PromoCode, error)
+
+	GetPromoCodeByID(id int) (models.PromoCode, error)
+
+	GetPromoCodeByCode(code string) (models.PromoCode, error)
+
+	InsertPromoCode(p models.PromoCode) (int, error)
+
+	DeletePromoCode(id int) error
+
+	InsertHold(r models.RoomRestriction) (int, error)
+
+	ConvertHold(holdID int, r models.RoomRestriction) (bool, error)
+
+	ReleaseHold(id int) error
+
+	DeleteExpiredHolds() (int, error)
+
+	CancelAbandonedReservations() (int, error)
+
+	InsertWaitlistEntry(e models.WaitlistEntry) (int, error)
+
+	GetWaitlistEntryByID(id int) (models.WaitlistEntry, error)
+
+	WaitingWaitlistEntries() ([]models.WaitlistEntry, error)
+
+	OpenWaitlistOffers(since time.Time) ([]models.WaitlistEntry, error)
+
+	UpdateWaitlistEntryNotified(id, roomID int) (bool, error)
+
+	RoomOccupancy(start, end time.Time) ([]models.RoomOccupancy, error)
+
+	StayStats(start, end time.Time) (models.StayStats, error)
+
+	RevenueByMonth(start, end time.Time) ([]models.MonthRevenue, error)
+
+	CountDay(day time.Time) (models.DayCounts, error)
+
+	ReservationsForDay(day time.Time) ([]models.Reservation, error)
+
+	CheckInReservation(id int) (bool, error)
+
+	CheckOutReservation(id int) (bool, error)
+
+	InsertAuditEntry(e models.AuditEntry) error
+
+	SearchAuditLog(f models.AuditFilter) ([]models.AuditEntry, int, error)
+
+	AuditLogUsers() ([]models.User, error)
+
+	InsertReservationNote(n models.ReservationNote) (int, error)
+
+	NotesForReservation(reservationID int) ([]models.ReservationNote, error)
 }
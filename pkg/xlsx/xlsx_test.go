@@ -0,0 +1,106 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+
+	x, err := New(&out, "Reservations: March/April")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = x.Row("Guest", "Nights", "Amount"); err != nil {
+		t.Fatal(err)
+	}
+	if err = x.Row("Smith & <Jones>", 3, 150.5); err != nil {
+		t.Fatal(err)
+	}
+	if err = x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/workbook.xml", "xl/worksheets/sheet1.xml"} {
+		body, ok := parts[name]
+		if !ok {
+			t.Errorf("missing %s", name)
+			continue
+		}
+		//every part has to be well formed
+		d := xml.NewDecoder(strings.NewReader(body))
+		for {
+			_, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s isn't well formed: %s", name, err)
+				break
+			}
+		}
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Reservations MarchApril"`) {
+		t.Error("sheet name wasn't cleaned up")
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">Smith &amp; &lt;Jones&gt;</t></is></c>`,
+		`<c r="B2"><v>3</v></c>`,
+		`<c r="C2"><v>150.5</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("expected the sheet to have %s", want)
+		}
+	}
+}
+
+func TestColumn(t *testing.T) {
+	var tests = []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+
+	for _, e := range tests {
+		if got := column(e.i); got != e.want {
+			t.Errorf("column(%d) = %s, wanted %s", e.i, got, e.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if got := escape("a\x00b\x1fc\td"); got != "abc&#x9;d" {
+		t.Errorf("unexpected %q", got)
+	}
+}